@@ -12,6 +12,8 @@
 package application
 
 import (
+	"fry.org/cmo/cli/internal/application/artifacts"
+	"fry.org/cmo/cli/internal/application/credentials"
 	"fry.org/cmo/cli/internal/application/healthchecker"
 	"fry.org/cmo/cli/internal/application/logger"
 	"fry.org/cmo/cli/internal/application/printer"
@@ -37,7 +39,9 @@ type Queries struct {
 
 // Commands operations that accept data to make a change or trigger an action
 type Commands struct {
-	PrintVersion PrintVersionRequestHandler
+	PrintVersion    PrintVersionRequestHandler
+	ApproveBaseline ApproveBaselineRequestHandler
+	SetCredential   SetCredentialRequestHandler
 }
 
 // Applications contains all exposed services of the application layer
@@ -107,3 +111,23 @@ func WithPrintVersionCommand(v version.Version, p printer.Printer) ApplicationOp
 		return nil
 	})
 }
+
+func WithApproveBaselineCommand(s artifacts.ArtifactStore) ApplicationOption {
+
+	return ApplicationOptionFunc(func(a *Applications) error {
+
+		a.Commands.ApproveBaseline = NewApproveBaselineRequestHandler(s)
+
+		return nil
+	})
+}
+
+func WithSetCredentialCommand(s credentials.CredentialStore) ApplicationOption {
+
+	return ApplicationOptionFunc(func(a *Applications) error {
+
+		a.Commands.SetCredential = NewSetCredentialRequestHandler(s)
+
+		return nil
+	})
+}
@@ -0,0 +1,77 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"fry.org/cmo/cli/internal/application/artifacts"
+	"github.com/speijnik/go-errortree"
+)
+
+type ApproveBaselineRequest struct {
+	Module string
+	// Target is the URL or the host of the probed target
+	Target  string
+	Feature string
+	Names   []string
+}
+
+type ApproveBaselineRequestHandler interface {
+	Handle(command ApproveBaselineRequest) error
+}
+
+type approveBaselineRequestHandler struct {
+	s artifacts.ArtifactStore
+}
+
+// NewApproveBaselineRequestHandler Constructor
+func NewApproveBaselineRequestHandler(store artifacts.ArtifactStore) ApproveBaselineRequestHandler {
+
+	return approveBaselineRequestHandler{
+		s: store,
+	}
+}
+
+// Handle promotes the last candidate screenshots to baselines
+func (h approveBaselineRequestHandler) Handle(command ApproveBaselineRequest) error {
+	var rcerror error
+
+	if h.s == nil {
+		return errortree.Add(rcerror, "Handle", errors.New("artifact store not configured"))
+	}
+	if command.Module == "" {
+		return errortree.Add(rcerror, "Handle", errors.New("missing module"))
+	}
+	host, err := artifacts.TargetHost(command.Target)
+	if err != nil {
+		return errortree.Add(rcerror, "Handle", err)
+	}
+	ctx := context.Background()
+	for _, name := range command.Names {
+		if err := h.approve(ctx, command.Module, host, command.Feature, name); err != nil {
+			rcerror = errortree.Add(rcerror, name, err)
+		}
+	}
+
+	return rcerror
+}
+
+func (h approveBaselineRequestHandler) approve(ctx context.Context, module string, host string, feature string, name string) error {
+
+	r, err := h.s.Get(ctx, artifacts.CandidateKey(module, host, feature, name))
+	if err != nil {
+		if errors.Is(err, artifacts.ErrNotFound) {
+			return fmt.Errorf("no candidate screenshot, run the scenario comparing against %q first", name)
+		}
+		return err
+	}
+	defer r.Close()
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	return h.s.Put(ctx, artifacts.BaselineKey(module, host, feature, name), "image/png", content)
+}
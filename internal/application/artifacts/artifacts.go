@@ -0,0 +1,63 @@
+package artifacts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+)
+
+const (
+	// BaselinesPrefix is the folder of the approved visual regression baselines
+	BaselinesPrefix = "baselines"
+	// CandidatesPrefix is the folder of the last screenshots compared against a baseline
+	CandidatesPrefix = "candidates"
+)
+
+// ErrNotFound is returned when there is no artifact stored under a key
+var ErrNotFound = errors.New("artifact not found")
+
+// BaselineKey returns the key of the approved baseline of a feature probed by a module against a
+// target host, as the pages differ between the environments
+func BaselineKey(module string, host string, feature string, name string) string {
+
+	return path.Join(BaselinesPrefix, module, host, feature, name+".png")
+}
+
+// CandidateKey returns the key of the last screenshot compared against a baseline of a feature
+// probed by a module against a target host
+func CandidateKey(module string, host string, feature string, name string) string {
+
+	return path.Join(CandidatesPrefix, module, host, feature, name+".png")
+}
+
+// TargetHost returns the host of the target scoping the baselines, the target is an URL or a bare
+// host. The port separator is replaced as it is not allowed in the file names of every store.
+func TargetHost(target string) (string, error) {
+
+	if !strings.Contains(target, "://") {
+		target = "//" + target
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", err
+	}
+	if u.Host == "" || strings.ContainsAny(u.Host, `/\`) || u.Host == "." || u.Host == ".." {
+		return "", fmt.Errorf("invalid target host %q", u.Host)
+	}
+
+	return strings.ReplaceAll(strings.ToLower(u.Host), ":", "_"), nil
+}
+
+// ArtifactStore is responsible for persisting the files produced by the probes, e.g. snapshots
+type ArtifactStore interface {
+	// Put stores the content under the key, replacing any previous content
+	Put(ctx context.Context, key string, contentType string, content []byte) error
+	// Get returns the content stored under the key. The caller must close it.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the content stored under the key
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,29 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotFound is returned when there is no credential with the requested name
+var ErrNotFound = errors.New("credential not found")
+
+// Credential holds the secrets used by the features to log in
+type Credential struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// TOTP is the secret of the one-time codes, base32 or otpauth:// URI, empty when the user has no MFA
+	TOTP string `json:"totp,omitempty"`
+}
+
+// CredentialStore is responsible for providing the named credentials
+type CredentialStore interface {
+	// Get returns the credential with the given name
+	Get(ctx context.Context, name string) (Credential, error)
+}
+
+// CredentialWriter is implemented by the stores the credentials can be saved to
+type CredentialWriter interface {
+	// Set saves the credential with the given name, replacing any previous one
+	Set(ctx context.Context, name string, c Credential) error
+}
@@ -0,0 +1,40 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned when there is no session stored under a key
+var ErrNotFound = errors.New("session not found")
+
+// Cookie is a browser cookie
+type Cookie struct {
+	Name     string  `json:"name"`
+	Value    string  `json:"value"`
+	Domain   string  `json:"domain"`
+	Path     string  `json:"path"`
+	Expires  float64 `json:"expires,omitempty"`
+	HTTPOnly bool    `json:"http_only,omitempty"`
+	Secure   bool    `json:"secure,omitempty"`
+	SameSite string  `json:"same_site,omitempty"`
+}
+
+// Session is the browser state of an authenticated user
+type Session struct {
+	Created time.Time `json:"created"`
+	Cookies []Cookie  `json:"cookies"`
+	// LocalStorage holds the local storage items by origin
+	LocalStorage map[string]map[string]string `json:"local_storage,omitempty"`
+}
+
+// SessionStore is responsible for persisting the browser sessions between runs
+type SessionStore interface {
+	// Load returns the session stored under the key
+	Load(ctx context.Context, key string) (Session, error)
+	// Save stores the session under the key, replacing any previous session
+	Save(ctx context.Context, key string, s Session) error
+	// Delete removes the session stored under the key
+	Delete(ctx context.Context, key string) error
+}
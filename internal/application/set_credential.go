@@ -0,0 +1,54 @@
+package application
+
+import (
+	"context"
+	"errors"
+
+	"fry.org/cmo/cli/internal/application/credentials"
+	"github.com/speijnik/go-errortree"
+)
+
+type SetCredentialRequest struct {
+	Name       string
+	Credential credentials.Credential
+}
+
+type SetCredentialRequestHandler interface {
+	Handle(command SetCredentialRequest) error
+}
+
+type setCredentialRequestHandler struct {
+	s credentials.CredentialStore
+}
+
+// NewSetCredentialRequestHandler Constructor
+func NewSetCredentialRequestHandler(store credentials.CredentialStore) SetCredentialRequestHandler {
+
+	return setCredentialRequestHandler{
+		s: store,
+	}
+}
+
+// Handle saves the credential in the store, when the store can be written
+func (h setCredentialRequestHandler) Handle(command SetCredentialRequest) error {
+	var rcerror error
+
+	if h.s == nil {
+		return errortree.Add(rcerror, "Handle", errors.New("credential store not configured"))
+	}
+	w, ok := h.s.(credentials.CredentialWriter)
+	if !ok {
+		return errortree.Add(rcerror, "Handle", errors.New("the credential store is read only"))
+	}
+	if command.Name == "" {
+		return errortree.Add(rcerror, "Handle", errors.New("missing credential name"))
+	}
+	if command.Credential.Username == "" || command.Credential.Password == "" {
+		return errortree.Add(rcerror, "Handle", errors.New("missing username or password"))
+	}
+	if err := w.Set(context.Background(), command.Name, command.Credential); err != nil {
+		return errortree.Add(rcerror, "Handle", err)
+	}
+
+	return nil
+}
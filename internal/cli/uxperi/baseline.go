@@ -0,0 +1,150 @@
+package uxperi
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"fry.org/cmo/cli/internal/application"
+	"fry.org/cmo/cli/internal/cli/common"
+	"fry.org/cmo/cli/internal/infrastructure"
+	"github.com/speijnik/go-errortree"
+	"github.com/workanator/go-floc/v3"
+	"github.com/workanator/go-floc/v3/guard"
+	"github.com/workanator/go-floc/v3/run"
+)
+
+type BaselineCmd struct {
+	Approve BaselineApproveCmd `cmd:"" help:"Promote the last screenshots compared against a baseline to approved baselines"`
+}
+
+type BaselineApproveCmd struct {
+	Flags BaselineFlags `embed:""`
+	Names []string      `arg:"" help:"names of the baselines to approve"`
+}
+
+type BaselineFlags struct {
+	Feature         string `help:"feature the baselines belong to" prefix:"baseline." default:"loginPage" env:"SC_BASELINE_FEATURE"`
+	Module          string `help:"module the feature was probed with" prefix:"baseline." default:"default" env:"SC_BASELINE_MODULE"`
+	Target          string `help:"URL or host of the probed target" prefix:"baseline." env:"SC_BASELINE_TARGET" required:""`
+	SnapshotsFolder string `help:"path to chromedp snapshots folder" prefix:"baseline." hidden:"" default:"./snapshots" env:"SC_TEST_SNAPSHOTS_FOLDER"`
+	ArtifactsStore  string `help:"URI of the store for snapshots and other artifacts, defaults to the snapshots folder" prefix:"baseline." env:"SC_TEST_ARTIFACTS_STORE" optional:""`
+}
+
+func initializeBaselineCmd(ctx floc.Context, ctrl floc.Control) error {
+	var c *common.Cmdctx
+	var cli CLI
+	var err, rcerror error
+
+	if c, err = UxperiCmdCtx(ctx); err != nil {
+		if e := UxperiSetRCErrorTree(ctx, "initializeBaselineCmd", err); e != nil {
+			return errortree.Add(rcerror, "initializeBaselineCmd", e)
+		}
+		return err
+	}
+	if cli, err = UxperiFlags(ctx); err != nil {
+		if e := UxperiSetRCErrorTree(ctx, "initializeBaselineCmd", err); e != nil {
+			return errortree.Add(rcerror, "initializeBaselineCmd", e)
+		}
+		return err
+	}
+
+	storeURI := cli.Baseline.Approve.Flags.ArtifactsStore
+	if storeURI == "" {
+		storeURI = fmt.Sprintf("artifacts:local?path=%s", url.QueryEscape(cli.Baseline.Approve.Flags.SnapshotsFolder))
+	}
+	if err = infrastructure.AdapterWithOptions(&c.Adapters, infrastructure.WithArtifactStore(storeURI)); err != nil {
+		if e := UxperiSetRCErrorTree(ctx, "initializeBaselineCmd", err); e != nil {
+			return errortree.Add(rcerror, "initializeBaselineCmd", e)
+		}
+		return err
+	}
+	if err = application.WithOptions(&c.Apps,
+		application.WithApproveBaselineCommand(c.Adapters.ArtifactStore),
+	); err != nil {
+		if e := UxperiSetRCErrorTree(ctx, "initializeBaselineCmd", err); e != nil {
+			return errortree.Add(rcerror, "initializeBaselineCmd", e)
+		}
+		return err
+	}
+	if err = UxperiSetCmdCtx(ctx, common.Cmdctx{
+		Cmd:      c.Cmd,
+		InitSeq:  c.InitSeq,
+		Apps:     c.Apps,
+		Adapters: c.Adapters,
+		Ports:    c.Ports,
+	}); err != nil {
+		if e := UxperiSetRCErrorTree(ctx, "initializeBaselineCmd", err); e != nil {
+			return errortree.Add(rcerror, "initializeBaselineCmd", e)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func baselineApproveJob(ctx floc.Context, ctrl floc.Control) error {
+	var c *common.Cmdctx
+	var cli CLI
+	var err error
+
+	if c, err = UxperiCmdCtx(ctx); err != nil {
+		UxperiSetRCErrorTree(ctx, "baselineApproveJob", err)
+		return err
+	}
+	if cli, err = UxperiFlags(ctx); err != nil {
+		UxperiSetRCErrorTree(ctx, "baselineApproveJob", err)
+		return err
+	}
+	req := application.ApproveBaselineRequest{
+		Module:  cli.Baseline.Approve.Flags.Module,
+		Target:  cli.Baseline.Approve.Flags.Target,
+		Feature: cli.Baseline.Approve.Flags.Feature,
+		Names:   cli.Baseline.Approve.Names,
+	}
+	if err = c.Apps.Commands.ApproveBaseline.Handle(req); err != nil {
+		UxperiSetRCErrorTree(ctx, "baselineApproveJob", err)
+		return err
+	}
+
+	return nil
+}
+
+func (cmd *BaselineApproveCmd) Run(cli *CLI, c *common.Cmdctx, rcerror *error) error {
+
+	c.InitSeq = append(c.InitSeq, initializeBaselineCmd)
+
+	c.RunSeq = guard.OnTimeout(
+		guard.ConstTimeout(5*time.Minute),
+		nil, // No need for timeout data
+		run.Sequence(
+			baselineApproveJob,
+			func(ctx floc.Context, ctrl floc.Control) error {
+
+				if rcerror, err := UxperiRCErrorTree(ctx); err != nil {
+					ctrl.Fail(fmt.Sprintf("Command '%s' internal error", c.Cmd), err)
+					return err
+				} else if *rcerror != nil {
+					ctrl.Fail(fmt.Sprintf("Command '%s' failed", c.Cmd), *rcerror)
+					return *rcerror
+				}
+				ctrl.Complete(fmt.Sprintf("Command '%s' completed", c.Cmd))
+
+				return nil
+			},
+		),
+		func(ctx floc.Context, ctrl floc.Control, id interface{}) {
+			// Fail the flow on timeout
+			msg := fmt.Sprintf("Command '%s' timeout expired", c.Cmd)
+			UxperiSetRCErrorTree(ctx, "timeout", errors.New(msg))
+			if rcerror, err := UxperiRCErrorTree(ctx); err != nil {
+				ctrl.Fail(fmt.Sprintf("Command '%s' internal error", c.Cmd), err)
+			} else {
+				ctrl.Fail(msg, *rcerror)
+			}
+		},
+	)
+
+	return nil
+}
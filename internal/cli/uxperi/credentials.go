@@ -0,0 +1,150 @@
+package uxperi
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"fry.org/cmo/cli/internal/application"
+	"fry.org/cmo/cli/internal/application/credentials"
+	"fry.org/cmo/cli/internal/cli/common"
+	"fry.org/cmo/cli/internal/infrastructure"
+	"github.com/speijnik/go-errortree"
+	"github.com/workanator/go-floc/v3"
+	"github.com/workanator/go-floc/v3/guard"
+	"github.com/workanator/go-floc/v3/run"
+)
+
+type CredentialsCmd struct {
+	Set CredentialsSetCmd `cmd:"" help:"Save a named credential in the keystore"`
+}
+
+type CredentialsSetCmd struct {
+	Flags CredentialsFlags `embed:""`
+	Name  string           `arg:"" help:"name the features refer to the credential by"`
+}
+
+type CredentialsFlags struct {
+	Store    string `help:"URI of the credential store (credentials:keystore?path=<file>)" prefix:"credentials." env:"SC_TEST_CREDENTIALS_STORE" required:""`
+	Key      string `help:"passphrase used to encrypt the keystore" prefix:"credentials." env:"SC_TEST_CREDENTIALS_KEY" hidden:"" optional:""`
+	Username string `help:"name used for authentication" prefix:"credentials." env:"SC_CREDENTIALS_USERNAME" required:""`
+	Password string `help:"password used for authentication" prefix:"credentials." env:"SC_CREDENTIALS_PASSWORD" required:""`
+	TOTP     string `help:"secret, base32 or otpauth:// URI, of the one-time codes asked by the MFA prompt" prefix:"credentials." env:"SC_CREDENTIALS_TOTP_SECRET" optional:""`
+}
+
+func initializeCredentialsCmd(ctx floc.Context, ctrl floc.Control) error {
+	var c *common.Cmdctx
+	var cli CLI
+	var err, rcerror error
+
+	if c, err = UxperiCmdCtx(ctx); err != nil {
+		if e := UxperiSetRCErrorTree(ctx, "initializeCredentialsCmd", err); e != nil {
+			return errortree.Add(rcerror, "initializeCredentialsCmd", e)
+		}
+		return err
+	}
+	if cli, err = UxperiFlags(ctx); err != nil {
+		if e := UxperiSetRCErrorTree(ctx, "initializeCredentialsCmd", err); e != nil {
+			return errortree.Add(rcerror, "initializeCredentialsCmd", e)
+		}
+		return err
+	}
+
+	flags := cli.Credentials.Set.Flags
+	if err = infrastructure.AdapterWithOptions(&c.Adapters, infrastructure.WithCredentialStore(flags.Store, flags.Key)); err != nil {
+		if e := UxperiSetRCErrorTree(ctx, "initializeCredentialsCmd", err); e != nil {
+			return errortree.Add(rcerror, "initializeCredentialsCmd", e)
+		}
+		return err
+	}
+	if err = application.WithOptions(&c.Apps,
+		application.WithSetCredentialCommand(c.Adapters.CredentialStore),
+	); err != nil {
+		if e := UxperiSetRCErrorTree(ctx, "initializeCredentialsCmd", err); e != nil {
+			return errortree.Add(rcerror, "initializeCredentialsCmd", e)
+		}
+		return err
+	}
+	if err = UxperiSetCmdCtx(ctx, common.Cmdctx{
+		Cmd:      c.Cmd,
+		InitSeq:  c.InitSeq,
+		Apps:     c.Apps,
+		Adapters: c.Adapters,
+		Ports:    c.Ports,
+	}); err != nil {
+		if e := UxperiSetRCErrorTree(ctx, "initializeCredentialsCmd", err); e != nil {
+			return errortree.Add(rcerror, "initializeCredentialsCmd", e)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func credentialsSetJob(ctx floc.Context, ctrl floc.Control) error {
+	var c *common.Cmdctx
+	var cli CLI
+	var err error
+
+	if c, err = UxperiCmdCtx(ctx); err != nil {
+		UxperiSetRCErrorTree(ctx, "credentialsSetJob", err)
+		return err
+	}
+	if cli, err = UxperiFlags(ctx); err != nil {
+		UxperiSetRCErrorTree(ctx, "credentialsSetJob", err)
+		return err
+	}
+	flags := cli.Credentials.Set.Flags
+	req := application.SetCredentialRequest{
+		Name: cli.Credentials.Set.Name,
+		Credential: credentials.Credential{
+			Username: flags.Username,
+			Password: flags.Password,
+			TOTP:     flags.TOTP,
+		},
+	}
+	if err = c.Apps.Commands.SetCredential.Handle(req); err != nil {
+		UxperiSetRCErrorTree(ctx, "credentialsSetJob", err)
+		return err
+	}
+
+	return nil
+}
+
+func (cmd *CredentialsSetCmd) Run(cli *CLI, c *common.Cmdctx, rcerror *error) error {
+
+	c.InitSeq = append(c.InitSeq, initializeCredentialsCmd)
+
+	c.RunSeq = guard.OnTimeout(
+		guard.ConstTimeout(5*time.Minute),
+		nil, // No need for timeout data
+		run.Sequence(
+			credentialsSetJob,
+			func(ctx floc.Context, ctrl floc.Control) error {
+
+				if rcerror, err := UxperiRCErrorTree(ctx); err != nil {
+					ctrl.Fail(fmt.Sprintf("Command '%s' internal error", c.Cmd), err)
+					return err
+				} else if *rcerror != nil {
+					ctrl.Fail(fmt.Sprintf("Command '%s' failed", c.Cmd), *rcerror)
+					return *rcerror
+				}
+				ctrl.Complete(fmt.Sprintf("Command '%s' completed", c.Cmd))
+
+				return nil
+			},
+		),
+		func(ctx floc.Context, ctrl floc.Control, id interface{}) {
+			// Fail the flow on timeout
+			msg := fmt.Sprintf("Command '%s' timeout expired", c.Cmd)
+			UxperiSetRCErrorTree(ctx, "timeout", errors.New(msg))
+			if rcerror, err := UxperiRCErrorTree(ctx); err != nil {
+				ctrl.Fail(fmt.Sprintf("Command '%s' internal error", c.Cmd), err)
+			} else {
+				ctrl.Fail(msg, *rcerror)
+			}
+		},
+	)
+
+	return nil
+}
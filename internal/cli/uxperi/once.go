@@ -0,0 +1,134 @@
+package uxperi
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+
+	"fry.org/cmo/cli/internal/application/logger"
+	"fry.org/cmo/cli/internal/cli/common"
+	iexporters "fry.org/cmo/cli/internal/infrastructure/exporters"
+	"fry.org/cmo/cli/internal/infrastructure/push"
+	"fry.org/cmo/cli/internal/infrastructure/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/speijnik/go-errortree"
+	"github.com/workanator/go-floc/v3"
+)
+
+// exporterRunOnce probes the target through the exporter handler, prints the metrics of the run
+// and pushes them. It fails when any scenario failed.
+func exporterRunOnce(ctx floc.Context, ctrl floc.Control) error {
+	var c *common.Cmdctx
+	var cli CLI
+	var err, rcerror error
+
+	if c, err = UxperiCmdCtx(ctx); err != nil {
+		UxperiSetRCErrorTree(ctx, "exporterRunOnce", err)
+		return err
+	}
+	if cli, err = UxperiFlags(ctx); err != nil {
+		UxperiSetRCErrorTree(ctx, "exporterRunOnce", err)
+		return err
+	}
+	once := cli.Test.Flags.Once
+
+	q := url.Values{}
+	q.Set("target", once.Target)
+	q.Set("feature", once.Feature)
+	if once.Module != "" {
+		q.Set("module", once.Module)
+	}
+	u := url.URL{
+		Scheme:   "http",
+		Host:     "localhost",
+		Path:     path.Join("/", cli.Test.Flags.Metrics.RootPrefix, "/probes"),
+		RawQuery: q.Encode(),
+	}
+	req, err := http.NewRequestWithContext(ctx.Ctx(), http.MethodGet, u.String(), nil)
+	if err != nil {
+		UxperiSetRCErrorTree(ctx, "exporterRunOnce", err)
+		return err
+	}
+	rec := httptest.NewRecorder()
+	c.Adapters.CucumberExporter.ServeHTTP(rec, req)
+	// The command may fail below, before the spans are exported on exit
+	if err = tracing.Flush(ctx.Ctx()); err != nil {
+		c.Apps.Logger.WithFields(logger.Fields{
+			"error": err.Error(),
+		}).Warn("Can not export the trace of the run")
+	}
+	if rec.Code != http.StatusOK {
+		err = errortree.Add(rcerror, "probe", fmt.Errorf("status code %d: %s", rec.Code, bytes.TrimSpace(rec.Body.Bytes())))
+		UxperiSetRCErrorTree(ctx, "exporterRunOnce", err)
+		return err
+	}
+	c.Apps.Logger.WithFields(logger.Fields{
+		"run":     rec.Header().Get("X-Run-Id"),
+		"feature": once.Feature,
+	}).Debug("Probe run finished")
+	os.Stdout.Write(rec.Body.Bytes())
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(bytes.NewReader(rec.Body.Bytes()))
+	if err != nil {
+		UxperiSetRCErrorTree(ctx, "exporterRunOnce", err)
+		return err
+	}
+	if cli.Test.Flags.Push.Endpoint != "" {
+		var pusher push.Pusher
+		if pusher, err = push.ParsePusher(cli.Test.Flags.Push.Endpoint); err != nil {
+			UxperiSetRCErrorTree(ctx, "exporterRunOnce", err)
+			return err
+		}
+		gatherer := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+			names := make([]string, 0, len(families))
+			for name := range families {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			list := make([]*dto.MetricFamily, 0, len(names))
+			for _, name := range names {
+				list = append(list, families[name])
+			}
+			return list, nil
+		})
+		module := once.Module
+		if module == "" {
+			module = iexporters.DefaultModuleName
+		}
+		grouping := map[string]string{
+			"feature": once.Feature,
+			"module":  module,
+			"target":  once.Target,
+		}
+		if err = pusher.Push(ctx.Ctx(), gatherer, grouping); err != nil {
+			UxperiSetRCErrorTree(ctx, "exporterRunOnce", err)
+			return err
+		}
+	}
+
+	if f, ok := families["scenario_success"]; ok {
+		for _, m := range f.GetMetric() {
+			if m.GetGauge().GetValue() != 1 {
+				for _, l := range m.GetLabel() {
+					if l.GetName() == "scenario_name" {
+						rcerror = errortree.Add(rcerror, l.GetValue(), fmt.Errorf("scenario failed"))
+					}
+				}
+			}
+		}
+	}
+	if rcerror != nil {
+		UxperiSetRCErrorTree(ctx, "exporterRunOnce", rcerror)
+		return rcerror
+	}
+
+	return nil
+}
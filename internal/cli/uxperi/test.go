@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/url"
+	"os"
 	"time"
 
 	"fry.org/cmo/cli/internal/application"
@@ -11,8 +13,13 @@ import (
 	"fry.org/cmo/cli/internal/application/logger"
 	"fry.org/cmo/cli/internal/cli/common"
 	"fry.org/cmo/cli/internal/infrastructure"
+	"fry.org/cmo/cli/internal/infrastructure/endpoints/web"
 	iexporters "fry.org/cmo/cli/internal/infrastructure/exporters"
 	ifeatures "fry.org/cmo/cli/internal/infrastructure/exporters/features"
+	"fry.org/cmo/cli/internal/infrastructure/notify"
+	"fry.org/cmo/cli/internal/infrastructure/push"
+	"fry.org/cmo/cli/internal/infrastructure/redact"
+	"fry.org/cmo/cli/internal/infrastructure/tracing"
 	"github.com/speijnik/go-errortree"
 	"github.com/workanator/go-floc/v3"
 	"github.com/workanator/go-floc/v3/run"
@@ -23,31 +30,63 @@ type TestCmd struct {
 }
 
 type TestFlags struct {
-	FeaturesFolder  string        `help:"path to gherkin features folder" prefix:"test." hidden:"" default:"./features" env:"SC_TEST_FEATURES_FOLDER"`
-	SnapshotsFolder string        `help:"path to chromedp snapshots folder" prefix:"test." hidden:"" default:"./snapshots" env:"SC_TEST_SNAPSHOTS_FOLDER"`
-	Timeout         time.Duration `help:"maximum amount of time that we should wait for a step or scenario to complete before timing out and marking the test as failed" prefix:"test." default:"1m" env:"SC_TEST_TIMEOUT"`
+	FeaturesFolder   string        `help:"path to gherkin features folder" prefix:"test." hidden:"" default:"./features" env:"SC_TEST_FEATURES_FOLDER"`
+	SnapshotsFolder  string        `help:"path to chromedp snapshots folder" prefix:"test." hidden:"" default:"./snapshots" env:"SC_TEST_SNAPSHOTS_FOLDER"`
+	ArtifactsStore   string        `help:"URI of the store for snapshots and other artifacts (artifacts:local?path=<folder>&max-size=<bytes>|artifacts:s3?endpoint=<url>&bucket=<name>), defaults to the snapshots folder" prefix:"test." env:"SC_TEST_ARTIFACTS_STORE" optional:""`
+	SessionsStore    string        `help:"URI of the store for the browser sessions reused between runs (sessions:file?path=<folder>), sessions are not reused when missing" prefix:"test." env:"SC_TEST_SESSIONS_STORE" optional:""`
+	SessionsKey      string        `help:"passphrase used to encrypt the stored browser sessions" prefix:"test." env:"SC_TEST_SESSIONS_KEY" hidden:"" optional:""`
+	SessionsMaxAge   time.Duration `help:"maximum age of a stored browser session before logging in again" prefix:"test." default:"1h" env:"SC_TEST_SESSIONS_MAX_AGE"`
+	CredentialsStore string        `help:"URI of the store of the named credentials (credentials:file?path=<folder>|credentials:keystore?path=<file>|credentials:vault?prefix=<path>)" prefix:"test." env:"SC_TEST_CREDENTIALS_STORE" optional:""`
+	CredentialsKey   string        `help:"passphrase used to encrypt the keystore" prefix:"test." env:"SC_TEST_CREDENTIALS_KEY" hidden:"" optional:""`
+	ModulesFile      string        `help:"path to the JSON file with the probe modules" prefix:"test." env:"SC_TEST_MODULES_FILE" optional:""`
+	Timeout          time.Duration `help:"maximum amount of time that we should wait for a step or scenario to complete before timing out and marking the test as failed" prefix:"test." default:"1m" env:"SC_TEST_TIMEOUT"`
 	// TargetURL      string        `help:"URL to check against" prefix:"test." env:"SC_TEST_TARGET_URL"`
 	Auth struct {
 		Id       string `help:"name used for authentication" prefix:"test." env:"SC_TEST_AZURE_USERNAME" hidden:""`
 		Password string `help:"password used for authentication" prefix:"test." env:"SC_TEST_AZURE_PASSWORD" hidden:""`
+		TOTP     string `help:"secret, base32 or otpauth:// URI, of the one-time codes asked by the MFA prompt" prefix:"test." env:"SC_TEST_AZURE_TOTP_SECRET" hidden:"" optional:""`
 	} `embed:"" group:"auth"`
 	Probes struct {
 		Enable     bool   `help:"enable actuator?." default:"true" prefix:"probes." env:"SC_TEST_PROBES_ENABLE" negatable:""`
 		Address    string `help:"actuator adress with port" prefix:"probes." default:":8081" env:"SC_TEST_PROBES_ADDRESS" optional:""`
 		RootPrefix string `help:"Prefix for the internal routes of web endpoints." prefix:"probes." env:"SC_TEST_PROBES_ROOT_PREFIX" default:"/actuator" optional:""`
+		WebConfig  string `help:"path to the web config file with the TLS and authentication settings of the actuator" prefix:"probes." name:"web-config-file" env:"SC_TEST_PROBES_WEB_CONFIG_FILE" optional:""`
 		// Root           string  `help:"endpoint root" default:"/health" env:"SC_TEST_PROBES_ROOT" optional:"" group:"probes"`
 	} `embed:"" group:"probes"`
 	Metrics struct {
 		Address    string `help:"actuator adress with port" prefix:"metrics." default:":8082" env:"SC_TEST_METRICS_ADDRESS" optional:"" `
 		RootPrefix string `help:"Prefix for the internal routes of web endpoints." prefix:"metrics." env:"SC_TEST_METRICS_ROUTE_PREFIX" default:"/" optional:""`
+		WebConfig  string `help:"path to the web config file with the TLS and authentication settings of the metrics, probes and history server" prefix:"metrics." name:"web-config-file" env:"SC_TEST_METRICS_WEB_CONFIG_FILE" optional:""`
+		History    struct {
+			Size   uint          `help:"number of runs kept in the history" prefix:"metrics.history." default:"25" env:"SC_TEST_METRICS_HISTORY_SIZE"`
+			MaxAge time.Duration `help:"maximum age of the runs kept in the history, 0 keeps them until they are replaced by newer runs" prefix:"metrics.history." default:"0s" env:"SC_TEST_METRICS_HISTORY_MAX_AGE"`
+		} `embed:""`
 	} `embed:"" group:"metrics"`
+	Push struct {
+		Endpoint string `help:"URI of the endpoint the metrics of every run are pushed to (push:pushgateway?url=<url>[&job=<name>]|push:remote-write?url=<url>[&job=<name>])" prefix:"push." env:"SC_TEST_PUSH_ENDPOINT" optional:""`
+	} `embed:"" group:"push"`
+	Notify struct {
+		Endpoints   []string `help:"URIs of the notifiers called when a scenario starts failing or recovers (notify:webhook?url=<url>|notify:slack?url=<url>|notify:smtp?url=smtp[s]://[<user>:<password>@]<host>:<port>&from=<address>&to=<address>[,<address>])" prefix:"notify." sep:";" env:"SC_TEST_NOTIFY_ENDPOINTS" optional:""`
+		Threshold   int      `help:"consecutive failed runs of a scenario before it is notified as failing" prefix:"notify." default:"1" env:"SC_TEST_NOTIFY_THRESHOLD"`
+		ExternalURL string   `help:"URL the metrics server is reachable at, used for the links to the history in the notifications" prefix:"notify." name:"external-url" env:"SC_TEST_NOTIFY_EXTERNAL_URL" optional:""`
+	} `embed:"" group:"notify"`
+	Once struct {
+		Target  string `help:"URL probed once, the command prints and pushes the metrics of the run and exits instead of serving the probes" prefix:"once." env:"SC_TEST_ONCE_TARGET" optional:""`
+		Feature string `help:"feature probed once" prefix:"once." default:"loginPage" env:"SC_TEST_ONCE_FEATURE"`
+		Module  string `help:"module used to probe once, the default module when missing" prefix:"once." env:"SC_TEST_ONCE_MODULE" optional:""`
+	} `embed:"" group:"once"`
+	Redact struct {
+		Patterns []string `help:"regular expressions of the text masked in the output, logs and history, only the groups are masked when the expression has any" prefix:"redact." sep:";" env:"SC_TEST_REDACT_PATTERNS" optional:""`
+	} `embed:"" group:"redact"`
 }
 
 func initializeTestCmd(ctx floc.Context, ctrl floc.Control) error {
 	var c *common.Cmdctx
 	var err, rcerror error
 	var cli CLI
-	var login iexporters.CucumberPlugin
+	var login, session iexporters.CucumberPlugin
+	var modules map[string]iexporters.Module
+	var pusher push.Pusher
 
 	if c, err = UxperiCmdCtx(ctx); err != nil {
 		if e := UxperiSetRCErrorTree(ctx, "initializeExporterCmd", err); e != nil {
@@ -62,24 +101,127 @@ func initializeTestCmd(ctx floc.Context, ctrl floc.Control) error {
 		return err
 	}
 
-	if login, err = ifeatures.NewLoginPageFeature(cli.Test.Flags.FeaturesFolder,
+	// Mask the secrets before anything can print them
+	redact.AddSecret(cli.Test.Flags.Auth.Password, cli.Test.Flags.Auth.TOTP, cli.Test.Flags.SessionsKey, cli.Test.Flags.CredentialsKey,
+		os.Getenv("VAULT_TOKEN"), os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_SESSION_TOKEN"))
+	for _, p := range cli.Test.Flags.Redact.Patterns {
+		if err = redact.AddPattern(p); err != nil {
+			err = errortree.Add(rcerror, "redact.patterns", fmt.Errorf("invalid pattern %q: %w", p, err))
+			if e := UxperiSetRCErrorTree(ctx, "initializeExporterCmd", err); e != nil {
+				return errortree.Add(rcerror, "initializeTestCmd", e)
+			}
+			return err
+		}
+	}
+	// Fail at start up rather than in the background servers
+	for _, file := range []string{cli.Test.Flags.Probes.WebConfig, cli.Test.Flags.Metrics.WebConfig} {
+		if _, err = web.New(file); err != nil {
+			if e := UxperiSetRCErrorTree(ctx, "initializeExporterCmd", err); e != nil {
+				return errortree.Add(rcerror, "initializeTestCmd", e)
+			}
+			return err
+		}
+	}
+	if err = tracing.Setup(ctx.Ctx()); err != nil {
+		if e := UxperiSetRCErrorTree(ctx, "initializeExporterCmd", err); e != nil {
+			return errortree.Add(rcerror, "initializeTestCmd", e)
+		}
+		return err
+	}
+	if cli.Test.Flags.ModulesFile != "" {
+		if modules, err = iexporters.LoadModules(cli.Test.Flags.ModulesFile); err != nil {
+			if e := UxperiSetRCErrorTree(ctx, "initializeExporterCmd", err); e != nil {
+				return errortree.Add(rcerror, "initializeTestCmd", e)
+			}
+			return err
+		}
+	}
+	storeURI := cli.Test.Flags.ArtifactsStore
+	if storeURI == "" {
+		storeURI = fmt.Sprintf("artifacts:local?path=%s", url.QueryEscape(cli.Test.Flags.SnapshotsFolder))
+	}
+	if err = infrastructure.AdapterWithOptions(&c.Adapters, infrastructure.WithArtifactStore(storeURI)); err != nil {
+		if e := UxperiSetRCErrorTree(ctx, "initializeExporterCmd", err); e != nil {
+			return errortree.Add(rcerror, "initializeTestCmd", e)
+		}
+		return err
+	}
+	if cli.Test.Flags.SessionsStore != "" {
+		if err = infrastructure.AdapterWithOptions(&c.Adapters, infrastructure.WithSessionStore(cli.Test.Flags.SessionsStore, cli.Test.Flags.SessionsKey)); err != nil {
+			if e := UxperiSetRCErrorTree(ctx, "initializeExporterCmd", err); e != nil {
+				return errortree.Add(rcerror, "initializeTestCmd", e)
+			}
+			return err
+		}
+	}
+	if cli.Test.Flags.CredentialsStore != "" {
+		if err = infrastructure.AdapterWithOptions(&c.Adapters, infrastructure.WithCredentialStore(cli.Test.Flags.CredentialsStore, cli.Test.Flags.CredentialsKey)); err != nil {
+			if e := UxperiSetRCErrorTree(ctx, "initializeExporterCmd", err); e != nil {
+				return errortree.Add(rcerror, "initializeTestCmd", e)
+			}
+			return err
+		}
+	}
+	loginOptions := []iexporters.ExporterOption{
 		ifeatures.WithLoginPageAuth(cli.Test.Flags.Auth.Id, cli.Test.Flags.Auth.Password),
+		ifeatures.WithLoginPageTOTP(cli.Test.Flags.Auth.TOTP),
+		ifeatures.WithLoginPageCredentialStore(c.Adapters.CredentialStore),
 		ifeatures.WithLoginPageLogger(c.Apps.Logger),
-		ifeatures.WithLoginPageSnapshotFolder(cli.Test.Flags.SnapshotsFolder),
-	); err != nil {
+		ifeatures.WithLoginPageArtifactStore(c.Adapters.ArtifactStore),
+		ifeatures.WithLoginPageSessionStore(c.Adapters.SessionStore, cli.Test.Flags.SessionsMaxAge),
+	}
+	if login, err = ifeatures.NewLoginPageFeature(cli.Test.Flags.FeaturesFolder, loginOptions...); err != nil {
 		if e := UxperiSetRCErrorTree(ctx, "initializeExporterCmd", err); e != nil {
 			return errortree.Add(rcerror, "initializeTestCmd", e)
 		}
 		return err
 	}
+	if session, err = ifeatures.NewAuthenticatedSessionFeature(cli.Test.Flags.FeaturesFolder, loginOptions...); err != nil {
+		if e := UxperiSetRCErrorTree(ctx, "initializeExporterCmd", err); e != nil {
+			return errortree.Add(rcerror, "initializeTestCmd", e)
+		}
+		return err
+	}
+	exporterOptions := []iexporters.ExporterOption{
+		iexporters.WithCucumberRootPrefix(cli.Test.Flags.Metrics.RootPrefix),
+		iexporters.WithCucumberHistoryEndpoint(cli.Test.Flags.Metrics.RootPrefix, cli.Test.Flags.Metrics.History.Size, cli.Test.Flags.Metrics.History.MaxAge),
+		iexporters.WithCucumberServiceDiscovery(cli.Test.Flags.Metrics.RootPrefix),
+		iexporters.WithCucumberTimeout(cli.Test.Flags.Timeout),
+		iexporters.WithCucumberArtifactStore(c.Adapters.ArtifactStore),
+		iexporters.WithCucumberModules(modules),
+		iexporters.WithCucumberPlugin("loginPage", login),
+		iexporters.WithCucumberPlugin("authenticatedSession", session),
+	}
+	// The runs of the one-shot mode are pushed by exporterRunOnce, once they are done
+	if cli.Test.Flags.Push.Endpoint != "" {
+		if pusher, err = push.ParsePusher(cli.Test.Flags.Push.Endpoint); err != nil {
+			if e := UxperiSetRCErrorTree(ctx, "initializeExporterCmd", err); e != nil {
+				return errortree.Add(rcerror, "initializeTestCmd", e)
+			}
+			return err
+		}
+		if cli.Test.Flags.Once.Target == "" {
+			exporterOptions = append(exporterOptions, iexporters.WithCucumberPusher(pusher))
+		}
+	}
+	// The one-shot mode exits before the transitions could be tracked
+	if len(cli.Test.Flags.Notify.Endpoints) > 0 && cli.Test.Flags.Once.Target == "" {
+		notifiers := make([]notify.Notifier, 0, len(cli.Test.Flags.Notify.Endpoints))
+		for _, endpoint := range cli.Test.Flags.Notify.Endpoints {
+			var notifier notify.Notifier
+			if notifier, err = notify.ParseNotifier(endpoint); err != nil {
+				if e := UxperiSetRCErrorTree(ctx, "initializeExporterCmd", err); e != nil {
+					return errortree.Add(rcerror, "initializeTestCmd", e)
+				}
+				return err
+			}
+			notifiers = append(notifiers, notifier)
+		}
+		exporterOptions = append(exporterOptions, iexporters.WithCucumberNotifiers(cli.Test.Flags.Notify.Threshold, cli.Test.Flags.Notify.ExternalURL, notifiers...))
+	}
 	infraOptions := []infrastructure.AdapterOption{
 		infrastructure.WithHealthchecker(cli.Test.Flags.Probes.RootPrefix),
-		infrastructure.WithCucumberExporter(
-			iexporters.WithCucumberRootPrefix(cli.Test.Flags.Metrics.RootPrefix),
-			iexporters.WithCucumberHistoryEndpoint(cli.Test.Flags.Metrics.RootPrefix),
-			iexporters.WithCucumberTimeout(cli.Test.Flags.Timeout),
-			iexporters.WithCucumberPlugin("loginPage", login),
-		),
+		infrastructure.WithCucumberExporter(exporterOptions...),
 	}
 	if err = infrastructure.AdapterWithOptions(&c.Adapters, infraOptions...); err != nil {
 		if e := UxperiSetRCErrorTree(ctx, "initializeExporterCmd", err); e != nil {
@@ -121,6 +263,7 @@ func initializeTestCmd(ctx floc.Context, ctrl floc.Control) error {
 func exporterRunHealthServer(ctx floc.Context, ctrl floc.Control) error {
 	var c *common.Cmdctx
 	var cli CLI
+	var wc *web.Web
 	var err error
 
 	if c, err = UxperiCmdCtx(ctx); err != nil {
@@ -132,6 +275,11 @@ func exporterRunHealthServer(ctx floc.Context, ctrl floc.Control) error {
 		return err
 	}
 
+	if wc, err = web.New(cli.Test.Flags.Probes.WebConfig); err != nil {
+		UxperiSetRCErrorTree(ctx, "exporterRunHealthServer", err)
+		return err
+	}
+
 	// Start the server in a separate goroutine
 	srv := &http.Server{
 		Addr:    cli.Test.Flags.Probes.Address,
@@ -141,7 +289,7 @@ func exporterRunHealthServer(ctx floc.Context, ctrl floc.Control) error {
 		c.Apps.Logger.WithFields(logger.Fields{
 			"address": cli.Test.Flags.Probes.Address,
 		}).Debug("Starting health server")
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := web.ListenAndServe(srv, wc); err != nil && err != http.ErrServerClosed {
 			UxperiSetRCErrorTree(ctx, "exporterRunHealthServer", err)
 		}
 	}()
@@ -161,6 +309,7 @@ func exporterRunHealthServer(ctx floc.Context, ctrl floc.Control) error {
 func exporterRunMetricsServer(ctx floc.Context, ctrl floc.Control) error {
 	var c *common.Cmdctx
 	var cli CLI
+	var wc *web.Web
 	var err error
 
 	if c, err = UxperiCmdCtx(ctx); err != nil {
@@ -172,6 +321,11 @@ func exporterRunMetricsServer(ctx floc.Context, ctrl floc.Control) error {
 		return err
 	}
 
+	if wc, err = web.New(cli.Test.Flags.Metrics.WebConfig); err != nil {
+		UxperiSetRCErrorTree(ctx, "exporterRunMetricsServer", err)
+		return err
+	}
+
 	// Start the server in a separate goroutine
 	srv := &http.Server{
 		Addr:    cli.Test.Flags.Metrics.Address,
@@ -181,7 +335,7 @@ func exporterRunMetricsServer(ctx floc.Context, ctrl floc.Control) error {
 		c.Apps.Logger.WithFields(logger.Fields{
 			"address": cli.Test.Flags.Metrics.Address,
 		}).Debug("Starting metrics server")
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := web.ListenAndServe(srv, wc); err != nil && err != http.ErrServerClosed {
 			UxperiSetRCErrorTree(ctx, "exporterRunMetricsServer", err)
 		}
 	}()
@@ -213,13 +367,29 @@ func (cmd *TestCmd) Run(cli *CLI, c *common.Cmdctx, rcerror *error) error {
 		return nil
 	}
 
+	isOneShot := func(ctx floc.Context) bool {
+
+		return cli.Test.Flags.Once.Target != ""
+	}
+
 	c.InitSeq = append(c.InitSeq, initializeTestCmd)
 
 	c.RunSeq = run.Sequence(
-		run.Background(exporterRunMetricsServer),
-		run.If(isActuatorEnabled, run.Background(exporterRunHealthServer)),
-		waitForCancel,
+		run.If(isOneShot,
+			exporterRunOnce,
+			run.Sequence(
+				run.Background(exporterRunMetricsServer),
+				run.If(isActuatorEnabled, run.Background(exporterRunHealthServer)),
+				waitForCancel,
+			),
+		),
 		func(ctx floc.Context, ctrl floc.Control) error {
+			// Export the spans of the last runs
+			ct, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := tracing.Shutdown(ct); err != nil {
+				UxperiSetRCErrorTree(ctx, "tracing", err)
+			}
 			if rcerror, err := UxperiRCErrorTree(ctx); err != nil {
 				ctrl.Fail(fmt.Sprintf("Command '%s' internal error", c.Cmd), err)
 				return err
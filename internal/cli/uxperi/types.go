@@ -3,7 +3,9 @@ package uxperi
 import "fry.org/cmo/cli/internal/cli/common"
 
 type CLI struct {
-	Logging common.Log `embed:"" prefix:"logging."`
-	Version VersionCmd `cmd:"" help:"Show version information"`
-	Test    TestCmd    `cmd:"" help:"Enter Prometheus mode"`
+	Logging     common.Log     `embed:"" prefix:"logging."`
+	Version     VersionCmd     `cmd:"" help:"Show version information"`
+	Test        TestCmd        `cmd:"" help:"Enter Prometheus mode"`
+	Baseline    BaselineCmd    `cmd:"" help:"Manage visual regression baselines"`
+	Credentials CredentialsCmd `cmd:"" help:"Manage the named credentials used to log in"`
 }
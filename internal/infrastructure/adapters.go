@@ -21,10 +21,13 @@
 package infrastructure
 
 import (
+	"fry.org/cmo/cli/internal/application/artifacts"
+	"fry.org/cmo/cli/internal/application/credentials"
 	"fry.org/cmo/cli/internal/application/exporters"
 	"fry.org/cmo/cli/internal/application/healthchecker"
 	"fry.org/cmo/cli/internal/application/logger"
 	"fry.org/cmo/cli/internal/application/printer"
+	"fry.org/cmo/cli/internal/application/sessions"
 	"fry.org/cmo/cli/internal/application/version"
 
 	ihealthchecker "fry.org/cmo/cli/internal/infrastructure/endpoints/healthchecker"
@@ -54,6 +57,9 @@ type Adapters struct {
 	printer.Printer
 	healthchecker.Healthchecker
 	exporters.CucumberExporter
+	artifacts.ArtifactStore
+	sessions.SessionStore
+	credentials.CredentialStore
 }
 
 // NewAdapters
@@ -113,6 +119,45 @@ func WithVersion(URI string) AdapterOption {
 	})
 }
 
+func WithArtifactStore(URI string) AdapterOption {
+
+	return AdapterOptionFunc(func(a *Adapters) error {
+		var err, rcerror error
+
+		if a.ArtifactStore, err = istorage.ParseArtifactStore(URI); err != nil {
+			return errortree.Add(rcerror, "WithArtifactStore", err)
+		}
+
+		return nil
+	})
+}
+
+func WithSessionStore(URI string, passphrase string) AdapterOption {
+
+	return AdapterOptionFunc(func(a *Adapters) error {
+		var err, rcerror error
+
+		if a.SessionStore, err = istorage.ParseSessionStore(URI, passphrase); err != nil {
+			return errortree.Add(rcerror, "WithSessionStore", err)
+		}
+
+		return nil
+	})
+}
+
+func WithCredentialStore(URI string, passphrase string) AdapterOption {
+
+	return AdapterOptionFunc(func(a *Adapters) error {
+		var err, rcerror error
+
+		if a.CredentialStore, err = istorage.ParseCredentialStore(URI, passphrase); err != nil {
+			return errortree.Add(rcerror, "WithCredentialStore", err)
+		}
+
+		return nil
+	})
+}
+
 func WithTablePrinter() AdapterOption {
 
 	return AdapterOptionFunc(func(a *Adapters) error {
@@ -0,0 +1,386 @@
+// Package web secures the HTTP servers with a web config file in the format of the Prometheus
+// exporter-toolkit: TLS with optional client certificates, basic auth with bcrypt hashes and bearer tokens.
+package web
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"fry.org/cmo/cli/internal/infrastructure/redact"
+	"github.com/speijnik/go-errortree"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the content of the web config file
+type Config struct {
+	TLSConfig    *TLSConfig        `yaml:"tls_server_config"`
+	Users        map[string]string `yaml:"basic_auth_users"`
+	BearerTokens []string          `yaml:"bearer_tokens"`
+}
+
+// TLSConfig enables TLS, the certificates and the client CAs are read again when their files change
+type TLSConfig struct {
+	CertFile   string     `yaml:"cert_file"`
+	KeyFile    string     `yaml:"key_file"`
+	ClientAuth string     `yaml:"client_auth_type"`
+	ClientCAs  string     `yaml:"client_ca_file"`
+	MinVersion TLSVersion `yaml:"min_version"`
+	MaxVersion TLSVersion `yaml:"max_version"`
+}
+
+// setDirectory resolves the relative paths of the files against the directory of the web config file
+func (c *TLSConfig) setDirectory(dir string) {
+
+	for _, name := range []*string{&c.CertFile, &c.KeyFile, &c.ClientCAs} {
+		if *name != "" && !filepath.IsAbs(*name) {
+			*name = filepath.Join(dir, *name)
+		}
+	}
+}
+
+// TLSVersion is written TLS10, TLS11, TLS12 or TLS13 in the web config file
+type TLSVersion uint16
+
+var tlsVersions = map[string]TLSVersion{
+	"TLS10": tls.VersionTLS10,
+	"TLS11": tls.VersionTLS11,
+	"TLS12": tls.VersionTLS12,
+	"TLS13": tls.VersionTLS13,
+}
+
+func (v *TLSVersion) UnmarshalYAML(node *yaml.Node) error {
+	var s string
+
+	if err := node.Decode(&s); err != nil {
+		return err
+	}
+	version, ok := tlsVersions[s]
+	if !ok {
+		return fmt.Errorf("unknown TLS version %q", s)
+	}
+	*v = version
+
+	return nil
+}
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"NoClientCert":               tls.NoClientCert,
+	"RequestClientCert":          tls.RequestClientCert,
+	"RequireAnyClientCert":       tls.RequireAnyClientCert,
+	"VerifyClientCertIfGiven":    tls.VerifyClientCertIfGiven,
+	"RequireAndVerifyClientCert": tls.RequireAndVerifyClientCert,
+}
+
+// maxCachedAuth bounds the cache of the bcrypt comparisons
+const maxCachedAuth = 256
+
+// dummyHash is compared against the password of the unknown users, so they take as long as the known ones
+var (
+	dummyHash     []byte
+	dummyHashOnce sync.Once
+)
+
+// Web applies a web config file to an HTTP server
+type Web struct {
+	path      string
+	mutex     sync.Mutex
+	current   *state
+	authMutex sync.Mutex
+	auth      map[[sha256.Size]byte]bool
+}
+
+// state is the web config with the content of the files it refers to, as read at a given time
+type state struct {
+	config Config
+	tls    *tls.Config
+	stamps map[string]time.Time
+}
+
+// New reads the web config file. An empty path gives a server without TLS nor authentication.
+func New(path string) (*Web, error) {
+	var rcerror error
+
+	w := &Web{
+		path: path,
+		auth: make(map[[sha256.Size]byte]bool),
+	}
+	if path == "" {
+		return w, nil
+	}
+	if _, err := w.load(); err != nil {
+		return nil, errortree.Add(rcerror, "New", err)
+	}
+
+	return w, nil
+}
+
+// TLSConfig is nil when the web config file does not enable TLS. Whether TLS is enabled is decided
+// at start up, the certificates and the rest of the settings are reloaded on each handshake.
+func (w *Web) TLSConfig() *tls.Config {
+
+	w.mutex.Lock()
+	enabled := w.current != nil && w.current.tls != nil
+	w.mutex.Unlock()
+	if !enabled {
+		return nil
+	}
+
+	return &tls.Config{
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			s, err := w.load()
+			if err != nil {
+				return nil, err
+			}
+			if s.tls == nil {
+				return nil, errors.New("TLS is no longer enabled in the web config file")
+			}
+
+			return &s.tls.Certificates[0], nil
+		},
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			s, err := w.load()
+			if err != nil {
+				return nil, err
+			}
+			if s.tls == nil {
+				return nil, errors.New("TLS is no longer enabled in the web config file")
+			}
+
+			return s.tls, nil
+		},
+	}
+}
+
+// Handler asks for the credentials of the web config file before calling the handler
+func (w *Web) Handler(h http.Handler) http.Handler {
+
+	if w.path == "" {
+		return h
+	}
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		s, err := w.load()
+		if err != nil {
+			http.Error(rw, "invalid web config", http.StatusInternalServerError)
+			return
+		}
+		if !w.authorized(s, r) {
+			if len(s.config.Users) > 0 {
+				rw.Header().Set("WWW-Authenticate", `Basic realm="uxperi", charset="UTF-8"`)
+			}
+			http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(rw, r)
+	})
+}
+
+// ListenAndServe starts the server with the TLS and the authentication of the web config file
+func ListenAndServe(srv *http.Server, w *Web) error {
+
+	srv.Handler = w.Handler(srv.Handler)
+	if srv.TLSConfig = w.TLSConfig(); srv.TLSConfig != nil {
+		return srv.ListenAndServeTLS("", "")
+	}
+
+	return srv.ListenAndServe()
+}
+
+// authorized accepts any of the bearer tokens and users, or anything when there is none
+func (w *Web) authorized(s *state, r *http.Request) bool {
+
+	if len(s.config.Users) == 0 && len(s.config.BearerTokens) == 0 {
+		return true
+	}
+	header := r.Header.Get("Authorization")
+	if len(s.config.BearerTokens) > 0 && len(header) > 7 && strings.EqualFold(header[:7], "bearer ") {
+		sent := sha256.Sum256([]byte(strings.TrimSpace(header[7:])))
+		found := 0
+		for _, t := range s.config.BearerTokens {
+			token := sha256.Sum256([]byte(t))
+			found |= subtle.ConstantTimeCompare(sent[:], token[:])
+		}
+		return found == 1
+	}
+	user, password, ok := r.BasicAuth()
+	if !ok || len(s.config.Users) == 0 {
+		return false
+	}
+	hash, known := s.config.Users[user]
+	if !known {
+		dummyHashOnce.Do(func() {
+			dummyHash, _ = bcrypt.GenerateFromPassword([]byte("uxperi"), bcrypt.DefaultCost)
+		})
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+
+	// bcrypt is slow on purpose, the outcome is kept for the scrapes that follow
+	key := sha256.Sum256([]byte(user + "\x00" + hash + "\x00" + password))
+	w.authMutex.Lock()
+	authorized, cached := w.auth[key]
+	w.authMutex.Unlock()
+	if cached {
+		return authorized
+	}
+	authorized = bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	w.authMutex.Lock()
+	if len(w.auth) >= maxCachedAuth {
+		w.auth = make(map[[sha256.Size]byte]bool)
+	}
+	w.auth[key] = authorized
+	w.authMutex.Unlock()
+
+	return authorized
+}
+
+// load reads the web config file again when it, or any of the files it refers to, changed
+func (w *Web) load() (*state, error) {
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.current != nil && !w.current.changed() {
+		return w.current, nil
+	}
+	s, err := readState(w.path)
+	if err != nil {
+		return nil, err
+	}
+	w.current = s
+
+	return s, nil
+}
+
+func (s *state) changed() bool {
+
+	for name, stamp := range s.stamps {
+		info, err := os.Stat(name)
+		if err != nil || !info.ModTime().Equal(stamp) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func readState(path string) (*state, error) {
+	var rcerror error
+
+	s := &state{
+		stamps: make(map[string]time.Time),
+	}
+	content, err := s.read(path)
+	if err != nil {
+		return nil, errortree.Add(rcerror, "web config", err)
+	}
+	if err = yaml.Unmarshal(content, &s.config); err != nil {
+		return nil, errortree.Add(rcerror, "web config", fmt.Errorf("%s: %w", path, err))
+	}
+	if s.config.TLSConfig != nil {
+		s.config.TLSConfig.setDirectory(filepath.Dir(path))
+	}
+	for user, hash := range s.config.Users {
+		if _, err = bcrypt.Cost([]byte(hash)); err != nil {
+			rcerror = errortree.Add(rcerror, "basic_auth_users", fmt.Errorf("%s: %w", user, err))
+		}
+	}
+	for i, t := range s.config.BearerTokens {
+		if strings.TrimSpace(t) == "" {
+			rcerror = errortree.Add(rcerror, "bearer_tokens", fmt.Errorf("token %d is empty", i))
+		}
+	}
+	if rcerror != nil {
+		return nil, rcerror
+	}
+	redact.AddSecret(s.config.BearerTokens...)
+	if s.config.TLSConfig != nil {
+		if s.tls, err = s.tlsConfig(s.config.TLSConfig); err != nil {
+			return nil, errortree.Add(rcerror, "tls_server_config", err)
+		}
+	}
+
+	return s, nil
+}
+
+func (s *state) tlsConfig(c *TLSConfig) (*tls.Config, error) {
+	var err error
+
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, errors.New("cert_file and key_file are required")
+	}
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+	if c.MinVersion != 0 {
+		config.MinVersion = uint16(c.MinVersion)
+	}
+	if c.MaxVersion != 0 {
+		config.MaxVersion = uint16(c.MaxVersion)
+	}
+	certPEM, err := s.read(c.CertFile)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := s.read(c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", c.CertFile, err)
+	}
+	config.Certificates = []tls.Certificate{cert}
+
+	if c.ClientCAs != "" {
+		caPEM, err := s.read(c.ClientCAs)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("%s: no certificate found", c.ClientCAs)
+		}
+		// The client CAs alone mean the client certificates are required
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if c.ClientAuth != "" {
+		var ok bool
+		if config.ClientAuth, ok = clientAuthTypes[c.ClientAuth]; !ok {
+			return nil, fmt.Errorf("unknown client_auth_type %q", c.ClientAuth)
+		}
+	}
+	if (config.ClientAuth == tls.VerifyClientCertIfGiven || config.ClientAuth == tls.RequireAndVerifyClientCert) && config.ClientCAs == nil {
+		return nil, fmt.Errorf("client_auth_type %s needs a client_ca_file", c.ClientAuth)
+	}
+
+	return config, nil
+}
+
+// read keeps the modification time of the file, to know when to read it again
+func (s *state) read(name string) ([]byte, error) {
+
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	s.stamps[name] = info.ModTime()
+
+	return content, nil
+}
@@ -0,0 +1,312 @@
+package web
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// authority signs the certificates of the tests
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newAuthority(t *testing.T) *authority {
+
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "uxperi test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &authority{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns the PEM certificate and key of a server on 127.0.0.1, or of a client
+func (a *authority) issue(t *testing.T, serial int64, server bool) ([]byte, []byte) {
+
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "uxperi"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if server {
+		tpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		tpl.IPAddresses = []net.IP{net.IPv4(127, 0, 0, 1)}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, a.cert, &key.PublicKey, a.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, name string, content []byte) {
+
+	t.Helper()
+	if err := os.WriteFile(name, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// serve starts a server with the web config, the handler answers 200
+func serve(t *testing.T, config string) *httptest.Server {
+
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "web.yml")
+	writeFile(t, name, []byte(config))
+	w, err := New(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(w.Handler(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {})))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func status(t *testing.T, srv *httptest.Server, setup func(r *http.Request)) int {
+
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/probes", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setup(req)
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode
+}
+
+func TestBearerTokens(t *testing.T) {
+
+	srv := serve(t, "bearer_tokens:\n  - s3cr3t\n  - other\n")
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"missing", "", http.StatusUnauthorized},
+		{"wrong", "Bearer wrong", http.StatusUnauthorized},
+		{"prefix of a token", "Bearer s3cr3", http.StatusUnauthorized},
+		{"basic auth", "Basic czNjcjN0Og==", http.StatusUnauthorized},
+		{"token", "Bearer s3cr3t", http.StatusOK},
+		{"second token", "bearer other", http.StatusOK},
+	}
+	for _, tt := range tests {
+		got := status(t, srv, func(r *http.Request) {
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+		})
+		if got != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBasicAuthUsers(t *testing.T) {
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := serve(t, "basic_auth_users:\n  prometheus: "+string(hash)+"\n")
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		want     int
+	}{
+		{"missing", "", "", http.StatusUnauthorized},
+		{"wrong password", "prometheus", "wrong", http.StatusUnauthorized},
+		{"unknown user", "grafana", "secret", http.StatusUnauthorized},
+		{"user", "prometheus", "secret", http.StatusOK},
+		// The outcome of the comparison is cached
+		{"user again", "prometheus", "secret", http.StatusOK},
+		{"wrong password again", "prometheus", "wrong", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		got := status(t, srv, func(r *http.Request) {
+			if tt.user != "" {
+				r.SetBasicAuth(tt.user, tt.password)
+			}
+		})
+		if got != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+
+	for name, config := range map[string]string{
+		"bad hash":          "basic_auth_users:\n  prometheus: secret\n",
+		"empty token":       "bearer_tokens:\n  - ' '\n",
+		"unknown version":   "tls_server_config:\n  cert_file: tls.crt\n  key_file: tls.key\n  min_version: TLS9\n",
+		"missing key":       "tls_server_config:\n  cert_file: tls.crt\n",
+		"missing cert":      "tls_server_config:\n  cert_file: missing.crt\n  key_file: missing.key\n",
+		"invalid yaml":      "basic_auth_users: [",
+		"client auth no ca": "tls_server_config:\n  cert_file: tls.crt\n  key_file: tls.key\n  client_auth_type: RequireAndVerifyClientCert\n",
+	} {
+		dir := t.TempDir()
+		ca := newAuthority(t)
+		cert, key := ca.issue(t, 2, true)
+		writeFile(t, filepath.Join(dir, "tls.crt"), cert)
+		writeFile(t, filepath.Join(dir, "tls.key"), key)
+		writeFile(t, filepath.Join(dir, "web.yml"), []byte(config))
+		if _, err := New(filepath.Join(dir, "web.yml")); err == nil {
+			t.Errorf("%s: New succeeded", name)
+		}
+	}
+}
+
+// tlsServer starts a TLS server with the certificate files next to the web config file, referred to
+// by relative paths
+func tlsServer(t *testing.T, dir string, config string) *httptest.Server {
+
+	t.Helper()
+	name := filepath.Join(dir, "web.yml")
+	writeFile(t, name, []byte(config))
+	w, err := New(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(w.Handler(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {})))
+	srv.TLS = w.TLSConfig()
+	if srv.TLS == nil {
+		t.Fatal("TLS is not enabled")
+	}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// handshake connects with the client certificate, if any, and returns the serial number of the server certificate
+func handshake(srv *httptest.Server, ca *authority, cert []byte, key []byte) (int64, error) {
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	config := &tls.Config{RootCAs: roots}
+	if cert != nil {
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return 0, err
+		}
+		config.Certificates = []tls.Certificate{pair}
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: config, DisableKeepAlives: true}}
+	resp, err := client.Get(srv.URL + "/probes")
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return resp.TLS.PeerCertificates[0].SerialNumber.Int64(), nil
+}
+
+func TestClientCertificates(t *testing.T) {
+
+	dir := t.TempDir()
+	ca := newAuthority(t)
+	cert, key := ca.issue(t, 2, true)
+	writeFile(t, filepath.Join(dir, "tls.crt"), cert)
+	writeFile(t, filepath.Join(dir, "tls.key"), key)
+	writeFile(t, filepath.Join(dir, "ca.crt"), ca.pem)
+	srv := tlsServer(t, dir, "tls_server_config:\n  cert_file: tls.crt\n  key_file: tls.key\n  client_ca_file: ca.crt\n  client_auth_type: RequireAndVerifyClientCert\n")
+
+	if _, err := handshake(srv, ca, nil, nil); err == nil {
+		t.Error("a client without certificate was accepted")
+	}
+	other := newAuthority(t)
+	otherCert, otherKey := other.issue(t, 3, false)
+	if _, err := handshake(srv, ca, otherCert, otherKey); err == nil {
+		t.Error("a client certificate of another CA was accepted")
+	}
+	clientCert, clientKey := ca.issue(t, 4, false)
+	if _, err := handshake(srv, ca, clientCert, clientKey); err != nil {
+		t.Errorf("the client certificate was refused: %v", err)
+	}
+}
+
+func TestCertificateReload(t *testing.T) {
+
+	dir := t.TempDir()
+	ca := newAuthority(t)
+	cert, key := ca.issue(t, 2, true)
+	writeFile(t, filepath.Join(dir, "tls.crt"), cert)
+	writeFile(t, filepath.Join(dir, "tls.key"), key)
+	srv := tlsServer(t, dir, "tls_server_config:\n  cert_file: tls.crt\n  key_file: tls.key\n")
+
+	if serial, err := handshake(srv, ca, nil, nil); err != nil || serial != 2 {
+		t.Fatalf("serial = %d, %v, want 2", serial, err)
+	}
+	// The renewed certificate is written in place, its modification time differs
+	cert, key = ca.issue(t, 5, true)
+	writeFile(t, filepath.Join(dir, "tls.crt"), cert)
+	writeFile(t, filepath.Join(dir, "tls.key"), key)
+	later := time.Now().Add(time.Minute)
+	for _, name := range []string{"tls.crt", "tls.key"} {
+		if err := os.Chtimes(filepath.Join(dir, name), later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if serial, err := handshake(srv, ca, nil, nil); err != nil || serial != 5 {
+		t.Errorf("serial after the renewal = %d, %v, want 5", serial, err)
+	}
+}
@@ -0,0 +1,174 @@
+package exporters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"time"
+
+	"fry.org/cmo/cli/internal/application/artifacts"
+	"fry.org/cmo/cli/internal/infrastructure/redact"
+	"github.com/speijnik/go-errortree"
+)
+
+// Artifact is a file produced by a run, e.g. a snapshot of the browser
+type Artifact struct {
+	Name        string
+	Scenario    string
+	Step        string
+	ContentType string
+	Key         string
+	Size        int64
+	Created     time.Time
+}
+
+// WithCucumberArtifactStore sets the store holding the artifacts of the runs
+func WithCucumberArtifactStore(s artifacts.ArtifactStore) ExporterOption {
+
+	return ExportOptionFn(func(i interface{}) error {
+		var rcerror error
+		var c *cucumberHandler
+		var ok bool
+
+		if c, ok = i.(*cucumberHandler); ok {
+			c.store = s
+			return nil
+		}
+
+		return errortree.Add(rcerror, "WithCucumberArtifactStore", errors.New("type mismatch, cucumberHandler expected"))
+	})
+}
+
+// IsImage reports whether the artifact can be rendered inline as an image
+func (a Artifact) IsImage() bool {
+
+	return len(a.ContentType) > 6 && a.ContentType[:6] == "image/"
+}
+
+// AddArtifact attaches the artifact to the run
+func (r *ProbeRun) AddArtifact(a Artifact) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if a.Created.IsZero() {
+		a.Created = time.Now()
+	}
+	a.Name = redact.String(a.Name)
+	a.Scenario = redact.String(a.Scenario)
+	a.Step = redact.String(a.Step)
+	r.Artifacts = append(r.Artifacts, a)
+}
+
+func (r *ProbeRun) findArtifact(name string) (Artifact, bool) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, a := range r.Artifacts {
+		if a.Name == name {
+			return a, true
+		}
+	}
+
+	return Artifact{}, false
+}
+
+// removeArtifacts deletes the artifacts attached to the run from the store
+func (r *ProbeRun) removeArtifacts(ctx context.Context, store artifacts.ArtifactStore) error {
+	var rcerror error
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, a := range r.Artifacts {
+		if err := store.Delete(ctx, a.Key); err != nil {
+			rcerror = errortree.Add(rcerror, a.Key, err)
+		}
+	}
+	r.Artifacts = nil
+
+	return rcerror
+}
+
+// RecordArtifact attaches the artifact to the run stored in the context, if any
+func RecordArtifact(ctx context.Context, a Artifact) {
+
+	if run, err := RunFromContext(ctx); err == nil {
+		if a.Scenario == "" {
+			a.Scenario, _ = StringFromContext(ctx, ContextKeyScenarioName)
+		}
+		run.AddArtifact(a)
+	}
+}
+
+// SaveArtifact puts the content in the store, under the folder of the run stored in the context, and attaches it to the run
+func SaveArtifact(ctx context.Context, store artifacts.ArtifactStore, a Artifact, content []byte) error {
+	var rcerror error
+
+	if store == nil {
+		return errortree.Add(rcerror, "SaveArtifact", errors.New("artifact store not configured"))
+	}
+	// The name ends up in the key of the store too
+	a.Name = redact.String(a.Name)
+	a.Key = a.Name
+	if run, err := RunFromContext(ctx); err == nil {
+		// The retries take the same snapshots as the first attempt
+		if n := run.CurrentAttempt(); n > 1 {
+			a.Name = fmt.Sprintf("attempt%d-%s", n, a.Name)
+		}
+		// artifacts of a run are kept together so they can be garbage-collected with it
+		a.Key = path.Join(run.Id, a.Name)
+	}
+	a.Size = int64(len(content))
+	if err := store.Put(ctx, a.Key, a.ContentType, content); err != nil {
+		return errortree.Add(rcerror, "SaveArtifact", err)
+	}
+	RecordArtifact(ctx, a)
+
+	return nil
+}
+
+// ArtifactsEndpoint serves the content of an artifact attached to a run
+func (c *cucumberHandler) ArtifactsEndpoint(w http.ResponseWriter, r *http.Request) {
+
+	params := r.URL.Query()
+	id := params.Get("id")
+	name := params.Get("name")
+	if id == "" || name == "" {
+		http.Error(w, "missing id or name param", http.StatusBadRequest)
+		return
+	}
+	run, found := c.findHistory(id)
+	if !found {
+		if run, found = c.liveRun(id); !found {
+			http.Error(w, fmt.Sprintf("unknown run %q", id), http.StatusNotFound)
+			return
+		}
+	}
+	a, found := run.findArtifact(name)
+	if !found {
+		http.Error(w, fmt.Sprintf("unknown artifact %q", name), http.StatusNotFound)
+		return
+	}
+	if c.store == nil {
+		http.Error(w, "artifact store not configured", http.StatusNotFound)
+		return
+	}
+	content, err := c.store.Get(r.Context(), a.Key)
+	if errors.Is(err, artifacts.ErrNotFound) {
+		http.Error(w, fmt.Sprintf("artifact %q not available", name), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("artifact %q error: %s", name, err.Error()), http.StatusInternalServerError)
+		return
+	}
+	defer content.Close()
+	if a.ContentType != "" {
+		w.Header().Set("Content-Type", a.ContentType)
+	}
+	io.Copy(w, content)
+}
@@ -0,0 +1,378 @@
+package exporters
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"github.com/cucumber/gherkin-go/v19"
+	"github.com/cucumber/godog"
+	"github.com/cucumber/messages-go/v16"
+	"github.com/iancoleman/strcase"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/speijnik/go-errortree"
+)
+
+var (
+	// ContextKeyScenarioSkip holds the scenarios that passed in a previous attempt of the run, the
+	// next attempts leave them out
+	ContextKeyScenarioSkip = ContextKey("scenarioSkip")
+)
+
+const (
+	ScenarioPassed        = "passed"
+	ScenarioPassedOnRetry = "passed_on_retry"
+	ScenarioFailed        = "failed"
+)
+
+// RetryConfig sets how many times a feature with a failed scenario is run again before reporting the failure
+type RetryConfig struct {
+	// Attempts is the number of runs of the feature, 1 means no retry
+	Attempts int `json:"attempts"`
+	// BackoffMs is the wait before the second attempt
+	BackoffMs float64 `json:"backoff_ms,omitempty"`
+	// Multiplier grows the wait before every following attempt, 1 keeps it constant
+	Multiplier float64 `json:"multiplier,omitempty"`
+}
+
+func (r RetryConfig) validate() error {
+	var rcerror error
+
+	if r.Attempts < 1 {
+		rcerror = errortree.Add(rcerror, "attempts", fmt.Errorf("%d attempts, at least one expected", r.Attempts))
+	}
+	if r.BackoffMs < 0 {
+		rcerror = errortree.Add(rcerror, "backoff_ms", fmt.Errorf("negative backoff %v", r.BackoffMs))
+	}
+	if r.Multiplier < 1 {
+		rcerror = errortree.Add(rcerror, "multiplier", fmt.Errorf("multiplier %v lower than 1", r.Multiplier))
+	}
+
+	return rcerror
+}
+
+// RunAttempt is a run of the feature, a probe run has several when the failed scenarios are retried
+type RunAttempt struct {
+	Number   int
+	Start    time.Time
+	Duration time.Duration
+	Set      CucumberStatsSet
+	Error    string
+}
+
+// Failed reports whether any scenario of the attempt failed
+func (a RunAttempt) Failed() bool {
+
+	return a.Error != "" || failedSet(a.Set)
+}
+
+var (
+	scenarioRunsCounterVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "scenario_runs_total",
+		Help: "Scenarios run by the probes, by result: passed at the first attempt, passed on retry or failed",
+	}, []string{"feature_name", "scenario_name", "result"})
+
+	scenarioFlakyCounterVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "scenario_flaky_total",
+		Help: "Scenarios that failed and then passed on retry in the same probe run",
+	}, []string{"feature_name", "scenario_name"})
+)
+
+func init() {
+	prometheus.MustRegister(scenarioRunsCounterVec)
+	prometheus.MustRegister(scenarioFlakyCounterVec)
+}
+
+func failedSet(set CucumberStatsSet) bool {
+
+	for _, item := range set {
+		for _, st := range item.Stats {
+			if st.Result == CucumberFailure {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// scenarioPassed reports whether every step of the scenario ran and passed
+func scenarioPassed(item CucumberStatsItem) bool {
+
+	if len(item.Stats) == 0 {
+		return false
+	}
+	for _, st := range item.Stats {
+		if st.Result != CucumberSuccess {
+			return false
+		}
+	}
+
+	return true
+}
+
+// scenarioFailed reports whether the scenario failed. The error of the run comes from its last attempt,
+// it does not fail a scenario that passed, in that attempt or in a previous one.
+func scenarioFailed(item CucumberStatsItem, runError string) bool {
+
+	for _, st := range item.Stats {
+		if st.Result == CucumberFailure {
+			return true
+		}
+	}
+
+	return runError != "" && !scenarioPassed(item)
+}
+
+// FilterScenarios removes from the features the scenarios that passed in a previous attempt of the run.
+// The features are returned unchanged for the first attempt.
+func FilterScenarios(ctx context.Context, features []godog.Feature) ([]godog.Feature, error) {
+	var rcerror error
+
+	skip, _ := ctx.Value(ContextKeyScenarioSkip).(map[string]bool)
+	if len(skip) == 0 {
+		return features, nil
+	}
+	filtered := make([]godog.Feature, 0, len(features))
+	for _, f := range features {
+		contents, err := removeScenarios(f.Contents, skip)
+		if err != nil {
+			return features, errortree.Add(rcerror, "FilterScenarios", fmt.Errorf("%s: %w", f.Name, err))
+		}
+		filtered = append(filtered, godog.Feature{Name: f.Name, Contents: contents})
+	}
+
+	return filtered, nil
+}
+
+// removeScenarios cuts the lines of the skipped scenarios, from their tags to the start of the next
+// child of the feature or of the rule, since godog only filters the feature contents by tags
+func removeScenarios(contents []byte, skip map[string]bool) ([]byte, error) {
+
+	doc, err := gherkin.ParseGherkinDocument(bytes.NewReader(contents), (&messages.Incrementing{}).NewId)
+	if err != nil {
+		return nil, err
+	}
+	if doc.Feature == nil {
+		return contents, nil
+	}
+	start := func(l *messages.Location, tags []*messages.Tag) int {
+		line := int(l.Line)
+		for _, t := range tags {
+			if int(t.Location.Line) < line {
+				line = int(t.Location.Line)
+			}
+		}
+		return line
+	}
+	// The first line of every child, and whether it is a skipped scenario
+	var starts []int
+	removed := make(map[int]bool)
+	scenario := func(sc *messages.Scenario) {
+		line := start(sc.Location, sc.Tags)
+		starts = append(starts, line)
+		if skip[strcase.ToCamel(sc.Name)] {
+			removed[line] = true
+		}
+	}
+	for _, child := range doc.Feature.Children {
+		switch {
+		case child.Background != nil:
+			starts = append(starts, int(child.Background.Location.Line))
+		case child.Scenario != nil:
+			scenario(child.Scenario)
+		case child.Rule != nil:
+			starts = append(starts, start(child.Rule.Location, child.Rule.Tags))
+			for _, rc := range child.Rule.Children {
+				if rc.Background != nil {
+					starts = append(starts, int(rc.Background.Location.Line))
+				} else if rc.Scenario != nil {
+					scenario(rc.Scenario)
+				}
+			}
+		}
+	}
+	sort.Ints(starts)
+
+	lines := strings.SplitAfter(string(contents), "\n")
+	drop := make([]bool, len(lines)+1)
+	for i, line := range starts {
+		if !removed[line] {
+			continue
+		}
+		end := len(lines)
+		if i+1 < len(starts) {
+			end = starts[i+1] - 1
+		}
+		for n := line; n <= end; n++ {
+			drop[n-1] = true
+		}
+	}
+	var b strings.Builder
+	for i, line := range lines {
+		if !drop[i] {
+			b.WriteString(line)
+		}
+	}
+
+	return []byte(b.String()), nil
+}
+
+// passedScenarios returns the scenarios of the set that passed
+func passedScenarios(set CucumberStatsSet) map[string]bool {
+
+	passed := make(map[string]bool)
+	for name, item := range set {
+		if scenarioPassed(item) {
+			passed[name] = true
+		}
+	}
+
+	return passed
+}
+
+// runAttempts runs the feature until its scenarios pass or the attempts of the policy are exhausted.
+// Every attempt after the first one leaves out the scenarios that already passed, so the set of the
+// response merges the last result of every scenario and its error is the one of the last attempt.
+// The attempts share the timeout of the probe.
+func runAttempts(ctx context.Context, plugin CucumberPlugin, run *ProbeRun, policy RetryConfig) <-chan PluginResponse {
+
+	respChan := make(chan PluginResponse, 1)
+	go func() {
+		var resp PluginResponse
+
+		resp.set = make(CucumberStatsSet)
+		attemptCtx := ctx
+		backoff := time.Duration(policy.BackoffMs * float64(time.Millisecond))
+		for n := 1; ; n++ {
+			start := run.startAttempt(n)
+			set, err := plugin.Do(attemptCtx)
+			for name, item := range set {
+				resp.set[name] = item
+			}
+			resp.err = err
+			a := run.endAttempt(n, start, set, err)
+			if !a.Failed() || n >= policy.Attempts || ctx.Err() != nil {
+				break
+			}
+			attemptCtx = context.WithValue(ctx, ContextKeyScenarioSkip, passedScenarios(resp.set))
+			run.Publish(RunEvent{
+				Kind: RunEventOutput,
+				Line: fmt.Sprintf("attempt %d of %d failed, retrying in %s", n, policy.Attempts, backoff),
+			})
+			select {
+			case <-ctx.Done():
+				respChan <- resp
+				return
+			case <-time.After(backoff):
+			}
+			backoff = time.Duration(float64(backoff) * policy.Multiplier)
+			// The requests left in flight by the previous attempt do not keep the network busy
+			if n, ok := ctx.Value(ContextKeyNetwork).(*networkRecorder); ok {
+				n.abandon()
+			}
+			// Every attempt starts from a clean browser, without the session of the previous one
+			if err := chromedp.Run(ctx, network.ClearBrowserCookies(), chromedp.Navigate("about:blank")); err != nil {
+				run.Publish(RunEvent{
+					Kind: RunEventOutput,
+					Line: fmt.Sprintf("failed to reset the browser before the attempt %d: %s", n+1, err.Error()),
+				})
+			}
+		}
+		respChan <- resp
+	}()
+
+	return respChan
+}
+
+func (r *ProbeRun) startAttempt(n int) time.Time {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.attempt = n
+
+	return time.Now()
+}
+
+// endAttempt records the results of the attempt in the run
+func (r *ProbeRun) endAttempt(n int, start time.Time, set CucumberStatsSet, err error) RunAttempt {
+
+	a := RunAttempt{
+		Number:   n,
+		Start:    start,
+		Duration: time.Since(start),
+		Set:      redactSet(set),
+	}
+	if err != nil {
+		a.Error = redactError(err)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.done {
+		r.Attempts = append(r.Attempts, a)
+	}
+
+	return a
+}
+
+// CurrentAttempt returns the number of the attempt in progress, 1 for the first one
+func (r *ProbeRun) CurrentAttempt() int {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.attempt == 0 {
+		return 1
+	}
+
+	return r.attempt
+}
+
+// ScenarioAttempts returns the number of attempts that ran the scenario
+func (r *ProbeRun) ScenarioAttempts(scenario string) int {
+
+	n := 0
+	for _, a := range r.Attempts {
+		if _, ok := a.Set[scenario]; ok {
+			n++
+		}
+	}
+
+	return n
+}
+
+// ScenarioResult tells whether the scenario passed at the first attempt, passed on retry or failed
+func (r *ProbeRun) ScenarioResult(scenario string) string {
+
+	if scenarioFailed(r.Set[scenario], r.Error) {
+		return ScenarioFailed
+	}
+	for _, a := range r.Attempts {
+		for _, st := range a.Set[scenario].Stats {
+			if st.Result == CucumberFailure {
+				return ScenarioPassedOnRetry
+			}
+		}
+	}
+
+	return ScenarioPassed
+}
+
+// observeAttempts counts the results of the scenarios of the finished run
+func observeAttempts(feature string, run *ProbeRun) {
+
+	for scenario := range run.Set {
+		result := run.ScenarioResult(scenario)
+		incWithExemplar(scenarioRunsCounterVec.WithLabelValues(feature, scenario, result), run)
+		if result == ScenarioPassedOnRetry {
+			incWithExemplar(scenarioFlakyCounterVec.WithLabelValues(feature, scenario), run)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package exporters
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/cucumber/godog"
+	"github.com/iancoleman/strcase"
+)
+
+const retryFeature = `Feature: Retry
+
+  Background:
+    Given I am on the login page
+
+  Scenario: Stable login
+    Then I should be redirected to the dashboard page
+
+  @flaky
+  Scenario: Flaky login
+    Then I should be redirected to the dashboard page
+
+  Rule: Sessions
+
+    Scenario: Broken session
+      Given I have an authenticated session
+`
+
+var scenarioLine = regexp.MustCompile(`(?m)^\s*Scenario: (.*)$`)
+
+// retryPlugin runs the scenarios left by FilterScenarios, the ones of failures fail in their first attempts
+type retryPlugin struct {
+	failures map[string]int
+	runs     [][]string
+}
+
+func (p *retryPlugin) Do(ctx context.Context) (CucumberStatsSet, error) {
+
+	features, err := FilterScenarios(ctx, []godog.Feature{{Name: "retry.feature", Contents: []byte(retryFeature)}})
+	if err != nil {
+		return nil, err
+	}
+	set := make(CucumberStatsSet)
+	var ran []string
+	failed := false
+	for _, m := range scenarioLine.FindAllStringSubmatch(string(features[0].Contents), -1) {
+		name := strcase.ToCamel(m[1])
+		ran = append(ran, name)
+		result := CucumberSuccess
+		if p.failures[name] > 0 {
+			p.failures[name]--
+			result = CucumberFailure
+			failed = true
+		}
+		set[name] = CucumberStatsItem{Stats: []CucumberStats{{Id: "Step", Result: result}}}
+	}
+	p.runs = append(p.runs, ran)
+	if failed {
+		return set, errors.New("failed test suite")
+	}
+
+	return set, nil
+}
+
+func (p *retryPlugin) GetScenarioName() (string, error) {
+
+	return "", nil
+}
+
+func TestRunAttempts(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		failures map[string]int
+		runs     []string
+		results  map[string]string
+		attempts map[string]int
+		wantErr  bool
+	}{
+		{
+			name:     "passed",
+			runs:     []string{"StableLogin,FlakyLogin,BrokenSession"},
+			results:  map[string]string{"StableLogin": ScenarioPassed, "FlakyLogin": ScenarioPassed, "BrokenSession": ScenarioPassed},
+			attempts: map[string]int{"StableLogin": 1, "FlakyLogin": 1, "BrokenSession": 1},
+		},
+		{
+			name:     "passed on retry",
+			failures: map[string]int{"FlakyLogin": 1},
+			runs:     []string{"StableLogin,FlakyLogin,BrokenSession", "FlakyLogin"},
+			results:  map[string]string{"StableLogin": ScenarioPassed, "FlakyLogin": ScenarioPassedOnRetry, "BrokenSession": ScenarioPassed},
+			attempts: map[string]int{"StableLogin": 1, "FlakyLogin": 2, "BrokenSession": 1},
+		},
+		{
+			name:     "failed",
+			failures: map[string]int{"FlakyLogin": 1, "BrokenSession": 3},
+			runs:     []string{"StableLogin,FlakyLogin,BrokenSession", "FlakyLogin,BrokenSession", "BrokenSession"},
+			results:  map[string]string{"StableLogin": ScenarioPassed, "FlakyLogin": ScenarioPassedOnRetry, "BrokenSession": ScenarioFailed},
+			attempts: map[string]int{"StableLogin": 1, "FlakyLogin": 2, "BrokenSession": 3},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &retryPlugin{failures: tt.failures}
+			run := newProbeRun("retry", "", "https://example.com")
+			resp := <-runAttempts(context.Background(), p, run, RetryConfig{Attempts: 3, Multiplier: 1})
+			if (resp.err != nil) != tt.wantErr {
+				t.Errorf("error = %v, want an error %v", resp.err, tt.wantErr)
+			}
+			var runs []string
+			for _, r := range p.runs {
+				runs = append(runs, strings.Join(r, ","))
+			}
+			if strings.Join(runs, " ") != strings.Join(tt.runs, " ") {
+				t.Errorf("runs = %v, want %v", runs, tt.runs)
+			}
+			run.complete(resp.set, resp.err)
+			for name, want := range tt.results {
+				if got := run.ScenarioResult(name); got != want {
+					t.Errorf("result of %s = %s, want %s", name, got, want)
+				}
+				if got := run.ScenarioAttempts(name); got != tt.attempts[name] {
+					t.Errorf("attempts of %s = %d, want %d", name, got, tt.attempts[name])
+				}
+				if got := outcomes(run)[name].failed; got != (want == ScenarioFailed) {
+					t.Errorf("outcome of %s failed = %v, want %v", name, got, want == ScenarioFailed)
+				}
+			}
+		})
+	}
+}
+
+func TestFilterScenarios(t *testing.T) {
+
+	features := []godog.Feature{{Name: "retry.feature", Contents: []byte(retryFeature)}}
+	got, err := FilterScenarios(context.Background(), features)
+	if err != nil || string(got[0].Contents) != retryFeature {
+		t.Errorf("FilterScenarios changed the features of the first attempt: %v", err)
+	}
+
+	ctx := context.WithValue(context.Background(), ContextKeyScenarioSkip, map[string]bool{"StableLogin": true, "FlakyLogin": true})
+	if got, err = FilterScenarios(ctx, features); err != nil {
+		t.Fatal(err)
+	}
+	want := `Feature: Retry
+
+  Background:
+    Given I am on the login page
+
+  Rule: Sessions
+
+    Scenario: Broken session
+      Given I have an authenticated session
+`
+	if string(got[0].Contents) != want {
+		t.Errorf("filtered feature:\n%s\nwant:\n%s", got[0].Contents, want)
+	}
+	// The filtered feature is still valid gherkin
+	if _, err = removeScenarios(got[0].Contents, nil); err != nil {
+		t.Error(err)
+	}
+
+	ctx = context.WithValue(context.Background(), ContextKeyScenarioSkip, map[string]bool{"BrokenSession": true})
+	if got, err = FilterScenarios(ctx, features); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(got[0].Contents), "Broken session") || !strings.Contains(string(got[0].Contents), "@flaky\n  Scenario: Flaky login") {
+		t.Errorf("filtered feature:\n%s", got[0].Contents)
+	}
+}
@@ -0,0 +1,146 @@
+package exporters
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"fry.org/cmo/cli/internal/infrastructure/redact"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// maxConsoleMessages bounds the messages kept by a run, a noisy page must not exhaust the history memory
+const maxConsoleMessages = 500
+
+type ConsoleLevel string
+
+const (
+	// ConsoleException is an uncaught JavaScript exception
+	ConsoleException ConsoleLevel = "exception"
+	ConsoleError     ConsoleLevel = "error"
+	ConsoleWarning   ConsoleLevel = "warning"
+	ConsoleInfo      ConsoleLevel = "info"
+)
+
+// ConsoleMessage is a message written to the browser console or an uncaught exception
+type ConsoleMessage struct {
+	Time     time.Time
+	Scenario string
+	Level    ConsoleLevel
+	Text     string
+	Source   string
+}
+
+// IsError reports whether the message is an exception or a console error
+func (m ConsoleMessage) IsError() bool {
+
+	return m.Level == ConsoleException || m.Level == ConsoleError
+}
+
+var jsErrorsCounterVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "browser_js_errors_total",
+	Help: "Uncaught JavaScript exceptions and console errors raised by the pages visited by the probes",
+}, []string{"feature_name", "scenario_name", "kind"})
+
+func init() {
+	prometheus.MustRegister(jsErrorsCounterVec)
+}
+
+// listenConsole returns a chromedp target listener recording the console of the browser in the run
+func listenConsole(feature string, run *ProbeRun) func(ev interface{}) {
+
+	return func(ev interface{}) {
+		var m ConsoleMessage
+
+		switch ev := ev.(type) {
+		case *runtime.EventExceptionThrown:
+			d := ev.ExceptionDetails
+			m = ConsoleMessage{
+				Level: ConsoleException,
+				Text:  d.Text,
+			}
+			if d.URL != "" {
+				m.Source = fmt.Sprintf("%s:%d:%d", d.URL, d.LineNumber+1, d.ColumnNumber+1)
+			}
+			if d.Exception != nil && d.Exception.Description != "" {
+				m.Text = d.Exception.Description
+			}
+		case *runtime.EventConsoleAPICalled:
+			switch ev.Type {
+			case runtime.APITypeError, runtime.APITypeAssert:
+				m.Level = ConsoleError
+			case runtime.APITypeWarning:
+				m.Level = ConsoleWarning
+			case runtime.APITypeLog, runtime.APITypeInfo:
+				m.Level = ConsoleInfo
+			default:
+				return
+			}
+			args := make([]string, 0, len(ev.Args))
+			for _, arg := range ev.Args {
+				args = append(args, remoteObjectString(arg))
+			}
+			m.Text = strings.Join(args, " ")
+			if ev.StackTrace != nil && len(ev.StackTrace.CallFrames) > 0 {
+				f := ev.StackTrace.CallFrames[0]
+				m.Source = fmt.Sprintf("%s:%d:%d", f.URL, f.LineNumber+1, f.ColumnNumber+1)
+			}
+		default:
+			return
+		}
+		m.Time = time.Now()
+		m.Scenario = run.CurrentScenario()
+		if m.IsError() {
+			incWithExemplar(jsErrorsCounterVec.WithLabelValues(feature, m.Scenario, string(m.Level)), run)
+		}
+		run.addConsoleMessage(m)
+	}
+}
+
+func remoteObjectString(o *runtime.RemoteObject) string {
+
+	switch {
+	case o.UnserializableValue != "":
+		return o.UnserializableValue.String()
+	case len(o.Value) > 0:
+		var s string
+		if err := json.Unmarshal(o.Value, &s); err == nil {
+			return s
+		}
+		return string(o.Value)
+	default:
+		return o.Description
+	}
+}
+
+func (r *ProbeRun) addConsoleMessage(m ConsoleMessage) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(r.Console) >= maxConsoleMessages {
+		r.ConsoleDropped++
+		return
+	}
+	m.Text = redact.String(m.Text)
+	m.Source = redact.String(m.Source)
+	r.Console = append(r.Console, m)
+}
+
+// JSErrors returns the exceptions thrown while the scenario was running, every one when the scenario is empty
+func (r *ProbeRun) JSErrors(scenario string) []ConsoleMessage {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var errs []ConsoleMessage
+	for _, m := range r.Console {
+		if m.Level == ConsoleException && (scenario == "" || m.Scenario == scenario) {
+			errs = append(errs, m)
+		}
+	}
+
+	return errs
+}
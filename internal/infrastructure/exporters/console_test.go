@@ -0,0 +1,83 @@
+package exporters
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"fry.org/cmo/cli/internal/infrastructure/redact"
+	"github.com/chromedp/cdproto/runtime"
+)
+
+func TestConsoleDropped(t *testing.T) {
+
+	run := newProbeRun("console.feature", "", "https://app.example.com")
+	for i := 0; i < maxConsoleMessages+7; i++ {
+		run.addConsoleMessage(ConsoleMessage{Level: ConsoleInfo, Text: fmt.Sprint(i)})
+	}
+	if len(run.Console) != maxConsoleMessages || run.ConsoleDropped != 7 {
+		t.Fatalf("%d messages kept, %d dropped, want %d and 7", len(run.Console), run.ConsoleDropped, maxConsoleMessages)
+	}
+	// The first messages are kept, the later ones are only counted
+	if run.Console[0].Text != "0" || run.Console[maxConsoleMessages-1].Text != fmt.Sprint(maxConsoleMessages-1) {
+		t.Errorf("kept the messages %s to %s", run.Console[0].Text, run.Console[maxConsoleMessages-1].Text)
+	}
+	// An exception thrown once the buffer is full is only counted
+	run.addConsoleMessage(ConsoleMessage{Level: ConsoleException, Text: "TypeError"})
+	if run.ConsoleDropped != 8 || len(run.JSErrors("")) != 0 {
+		t.Errorf("%d dropped, %d exceptions", run.ConsoleDropped, len(run.JSErrors("")))
+	}
+}
+
+func TestListenConsole(t *testing.T) {
+
+	run := newProbeRun("console.feature", "", "https://app.example.com")
+	listen := listenConsole("ConsoleFeature", run)
+	str := func(s string) *runtime.RemoteObject {
+		b, _ := json.Marshal(s)
+		return &runtime.RemoteObject{Type: runtime.TypeString, Value: b}
+	}
+	frames := &runtime.StackTrace{CallFrames: []*runtime.CallFrame{{URL: "https://app.example.com/app.js", LineNumber: 9, ColumnNumber: 4}}}
+
+	run.Publish(RunEvent{Kind: RunEventStepStarted, Scenario: "Login"})
+	listen(&runtime.EventConsoleAPICalled{Type: runtime.APITypeLog, Args: []*runtime.RemoteObject{str("loaded"), {Type: runtime.TypeNumber, Value: []byte("42")}}})
+	listen(&runtime.EventConsoleAPICalled{Type: runtime.APITypeWarning, Args: []*runtime.RemoteObject{{Type: runtime.TypeNumber, UnserializableValue: "NaN"}}})
+	listen(&runtime.EventConsoleAPICalled{Type: runtime.APITypeError, Args: []*runtime.RemoteObject{str("Authorization: Bearer abc.def")}, StackTrace: frames})
+	// The debug and table calls, and the other events, are not recorded
+	listen(&runtime.EventConsoleAPICalled{Type: runtime.APITypeDebug, Args: []*runtime.RemoteObject{str("debug")}})
+	listen(&runtime.EventExecutionContextsCleared{})
+	run.Publish(RunEvent{Kind: RunEventStepStarted, Scenario: "Search"})
+	listen(&runtime.EventExceptionThrown{ExceptionDetails: &runtime.ExceptionDetails{
+		Text:         "Uncaught",
+		URL:          "https://app.example.com/search.js",
+		LineNumber:   0,
+		ColumnNumber: 11,
+		Exception:    &runtime.RemoteObject{Type: runtime.TypeObject, Description: "TypeError: x is undefined"},
+	}})
+
+	want := []ConsoleMessage{
+		{Scenario: "Login", Level: ConsoleInfo, Text: "loaded 42"},
+		{Scenario: "Login", Level: ConsoleWarning, Text: "NaN"},
+		{Scenario: "Login", Level: ConsoleError, Text: "Authorization: Bearer " + redact.Mask, Source: "https://app.example.com/app.js:10:5"},
+		{Scenario: "Search", Level: ConsoleException, Text: "TypeError: x is undefined", Source: "https://app.example.com/search.js:1:12"},
+	}
+	if len(run.Console) != len(want) {
+		t.Fatalf("recorded %+v", run.Console)
+	}
+	for i, m := range run.Console {
+		if m.Time.IsZero() {
+			t.Errorf("message %d has no time", i)
+		}
+		m.Time = want[i].Time
+		if m != want[i] {
+			t.Errorf("message %d = %+v, want %+v", i, m, want[i])
+		}
+	}
+	if errs := run.JSErrors("Login"); len(errs) != 0 {
+		t.Errorf("Login exceptions %+v", errs)
+	}
+	if errs := run.JSErrors("Search"); len(errs) != 1 || !strings.HasPrefix(errs[0].Text, "TypeError") {
+		t.Errorf("Search exceptions %+v", errs)
+	}
+}
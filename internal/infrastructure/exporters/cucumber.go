@@ -10,7 +10,10 @@ import (
 	"sync"
 	"time"
 
+	"fry.org/cmo/cli/internal/application/artifacts"
 	"fry.org/cmo/cli/internal/application/exporters"
+	"fry.org/cmo/cli/internal/infrastructure/push"
+	"fry.org/cmo/cli/internal/infrastructure/redact"
 	"github.com/chromedp/chromedp"
 	"github.com/iancoleman/strcase"
 	"github.com/prometheus/client_golang/prometheus"
@@ -43,6 +46,8 @@ type CucumberStats struct {
 	Start    time.Time
 	Duration time.Duration
 	Result   CucumberResult
+	// Error is the error returned by the step, if any
+	Error string
 }
 
 type CucumberPlugin interface {
@@ -59,6 +64,12 @@ type cucumberHandler struct {
 	timeout     time.Duration
 	templates   map[string]*template.Template
 	history     historyBuffer
+	liveMutex   sync.RWMutex
+	live        map[string]*ProbeRun
+	store       artifacts.ArtifactStore
+	modules     map[string]Module
+	pusher      push.Pusher
+	alerting    *alerting
 }
 
 // NewCucumberExporter creates a new CucumberExporter
@@ -68,6 +79,8 @@ func NewCucumberExporter(opts ...ExporterOption) (exporters.CucumberExporter, er
 	h := cucumberHandler{
 		PluginSet: make(map[string]CucumberPlugin),
 		timeout:   2 * time.Second,
+		live:      make(map[string]*ProbeRun),
+		modules:   make(map[string]Module),
 	}
 	// Loop through each option
 	for _, option := range opts {
@@ -119,7 +132,7 @@ func WithCucumberRootPrefix(prefix string) ExporterOption {
 
 		if c, ok = i.(*cucumberHandler); ok {
 			c.Handle(path.Join(prefix, "/probes"), http.HandlerFunc(c.ProbesEndpoint))
-			c.Handle(path.Join(prefix, "/metrics"), promhttp.Handler())
+			c.Handle(path.Join(prefix, "/metrics"), metricsHandler())
 			return nil
 		}
 
@@ -174,20 +187,6 @@ type PluginResponse struct {
 	err error
 }
 
-func helper(ctx context.Context, plugin CucumberPlugin) <-chan PluginResponse {
-
-	respChan := make(chan PluginResponse, 1)
-	go func() {
-		set, err := plugin.Do(ctx)
-		respChan <- PluginResponse{
-			set: set,
-			err: err,
-		}
-	}()
-
-	return respChan
-}
-
 func (c *cucumberHandler) handle(w http.ResponseWriter, r *http.Request, plugins map[string]CucumberPlugin) {
 	var plugin CucumberPlugin
 	var ok bool
@@ -209,29 +208,123 @@ func (c *cucumberHandler) handle(w http.ResponseWriter, r *http.Request, plugins
 		http.Error(w, fmt.Sprintf("unknown feature %q", featureName), http.StatusBadRequest)
 		return
 	}
+	moduleName := params.Get("module")
+	module, ok := c.module(moduleName)
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown module %q", moduleName), http.StatusBadRequest)
+		return
+	}
+	if moduleName == "" {
+		moduleName = DefaultModuleName
+	}
+	if err := module.Targets.Allow(r.Context(), target); err != nil {
+		reason := TargetRejectedInvalid
+		var te *TargetError
+		if errors.As(err, &te) {
+			reason = te.Reason
+		}
+		targetRejectedCounterVec.WithLabelValues(moduleName, reason).Inc()
+		http.Error(w, fmt.Sprintf("target %q not allowed by module %q: %s", redact.String(target), moduleName, err.Error()), http.StatusBadRequest)
+		return
+	}
 
 	scenarioSuccessGaugeVec := prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "scenario_success",
 		Help: "Displays whether or not the scenario test was succesful",
-	}, []string{"feature_name", "scenario_name"})
+	}, []string{"feature_name", "scenario_name", "network_profile"})
 
 	stepSuccessGaugeVec := prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "step_success",
 		Help: "Displays whether or not the step was a success",
-	}, []string{"feature_name", "scenario_name", "step_name"})
+	}, []string{"feature_name", "scenario_name", "step_name", "network_profile"})
 
 	stepDurationGaugeVec := prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "step_duration_seconds",
 		Help: "Duration of test steps in seconds",
-	}, []string{"feature_name", "scenario_name", "step_name", "step_status"})
+	}, []string{"feature_name", "scenario_name", "step_name", "step_status", "network_profile"})
+
+	networkRequestsGaugeVec := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "scenario_network_requests",
+		Help: "Number of network requests issued by the browser during the scenario",
+	}, []string{"feature_name", "scenario_name"})
+
+	networkFailedGaugeVec := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "scenario_network_failed_requests",
+		Help: "Number of network requests that failed or got an HTTP error status during the scenario",
+	}, []string{"feature_name", "scenario_name"})
+
+	networkBytesGaugeVec := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "scenario_network_transferred_bytes",
+		Help: "Bytes received by the browser during the scenario",
+	}, []string{"feature_name", "scenario_name"})
+
+	navigationPhaseGaugeVec := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "page_navigation_phase_seconds",
+		Help: "Navigation timing phases (dns, tls, ttfb, dom_content_loaded, load) of the pages visited by the scenario",
+	}, []string{"feature_name", "scenario_name", "page", "phase"})
+
+	lcpGaugeVec := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "page_largest_contentful_paint_seconds",
+		Help: "Largest Contentful Paint of the pages visited by the scenario",
+	}, []string{"feature_name", "scenario_name", "page"})
+
+	clsGaugeVec := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "page_cumulative_layout_shift_score",
+		Help: "Cumulative Layout Shift of the pages visited by the scenario",
+	}, []string{"feature_name", "scenario_name", "page"})
+
+	inpGaugeVec := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "page_interaction_to_next_paint_seconds",
+		Help: "Interaction to Next Paint of the pages visited by the scenario",
+	}, []string{"feature_name", "scenario_name", "page"})
+
+	fidGaugeVec := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "page_first_input_delay_seconds",
+		Help: "First Input Delay of the pages visited by the scenario",
+	}, []string{"feature_name", "scenario_name", "page"})
+
+	scenarioAttemptsGaugeVec := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "scenario_attempts",
+		Help: "Number of attempts run by the scenario, more than one when it was retried",
+	}, []string{"feature_name", "scenario_name"})
+
+	visualDiffGaugeVec := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "visual_diff_ratio",
+		Help: "Ratio of pixels that differ between a screenshot and its baseline",
+	}, []string{"feature_name", "scenario_name", "baseline_name"})
 
 	registry := prometheus.NewRegistry()
 	registry.MustRegister(scenarioSuccessGaugeVec)
 	registry.MustRegister(stepSuccessGaugeVec)
 	registry.MustRegister(stepDurationGaugeVec)
+	registry.MustRegister(scenarioAttemptsGaugeVec)
+	registry.MustRegister(visualDiffGaugeVec)
+	registry.MustRegister(networkRequestsGaugeVec)
+	registry.MustRegister(networkFailedGaugeVec)
+	registry.MustRegister(networkBytesGaugeVec)
+	registry.MustRegister(navigationPhaseGaugeVec)
+	registry.MustRegister(lcpGaugeVec)
+	registry.MustRegister(clsGaugeVec)
+	registry.MustRegister(inpGaugeVec)
+	registry.MustRegister(fidGaugeVec)
 	//FIXME: add context withTimeout to avoid endless requests
 	ctx, cancelFn := context.WithCancel(r.Context())
+	run := newProbeRun(featureName, moduleName, target)
+	ctx, span := startRunSpan(ctx, r, run)
+	if p, err := module.Emulation.NetworkProfile(); err == nil {
+		run.NetworkProfile = p.Name
+	}
+	c.startRun(run)
+	w.Header().Set("X-Run-Id", run.Id)
 	ct := context.WithValue(ctx, ContextKeyTargetUrl, target)
+	ct = context.WithValue(ct, ContextKeyRun, run)
+	ct = context.WithValue(ct, ContextKeyModule, module)
+	intercept := newInterceptor(module.Interception)
+	intercept.propagateTrace(module.Tracing.Propagate, traceparent(ctx))
+	intercept.restrictNavigations(module.Targets)
+	ct = context.WithValue(ct, ContextKeyInterceptor, intercept)
+	recorder := newNetworkRecorder(run)
+	ct = context.WithValue(ct, ContextKeyNetwork, recorder)
 	defer cancelFn()
 	//Initialize chromedp context
 	opts := append(chromedp.DefaultExecAllocatorOptions[:],
@@ -241,12 +334,47 @@ func (c *cucumberHandler) handle(w http.ResponseWriter, r *http.Request, plugins
 	)
 	actx, _ := chromedp.NewExecAllocator(ct, opts...)
 	plugingCtx, _ := chromedp.NewContext(actx)
+	chromedp.ListenTarget(plugingCtx, recorder.listen)
+	chromedp.ListenTarget(plugingCtx, listenConsole(strcase.ToCamel(featureName), run))
+	vitals := newVitalsRecorder(run)
+	chromedp.ListenTarget(plugingCtx, vitals.listen)
+	if err := chromedp.Run(plugingCtx, vitals.install()); err != nil {
+		run.Publish(RunEvent{
+			Kind: RunEventOutput,
+			Line: fmt.Sprintf("failed to install the web vitals observers: %s", err.Error()),
+		})
+	}
+	if err := chromedp.Run(plugingCtx, emulate(module.Emulation)); err != nil {
+		run.Publish(RunEvent{
+			Kind: RunEventOutput,
+			Line: fmt.Sprintf("failed to emulate the module network conditions: %s", err.Error()),
+		})
+		run.NetworkProfile = NetworkProfileNone
+	}
+	if err := intercept.enable(plugingCtx); err != nil {
+		run.Publish(RunEvent{
+			Kind: RunEventOutput,
+			Line: fmt.Sprintf("failed to enable the request interception: %s", err.Error()),
+		})
+	}
+	recordNetwork := func() {
+		if err := c.recordNetwork(run, recorder); err != nil {
+			run.Publish(RunEvent{
+				Kind: RunEventOutput,
+				Line: fmt.Sprintf("failed to save the network archive: %s", err.Error()),
+			})
+		}
+	}
 
 	//FIXME: Add some values to history ring buffer when context fails
 	select {
 	case <-plugingCtx.Done():
 		// Extract the reason for cancellation
 		err := plugingCtx.Err()
+		recordNetwork()
+		run.complete(nil, err)
+		endRunSpan(ctx, span, run, recorder.completed())
+		c.finishRun(run)
 		switch err {
 		case context.Canceled:
 			// Handle cancellation scenario
@@ -258,40 +386,77 @@ func (c *cucumberHandler) handle(w http.ResponseWriter, r *http.Request, plugins
 				w.WriteHeader(http.StatusInternalServerError)
 				w.Write([]byte(fmt.Sprintf("Scenario name not found for metrics %v", e)))
 			} else {
-				scenarioSuccessGaugeVec.WithLabelValues(strcase.ToCamel(featureName), name).Set(float64(CucumberFailure))
+				scenarioSuccessGaugeVec.WithLabelValues(strcase.ToCamel(featureName), name, run.NetworkProfileOf(name)).Set(float64(CucumberFailure))
+				observeTimeout(strcase.ToCamel(featureName), name, run)
+				c.notify(run, map[string]scenarioOutcome{name: {failed: true, err: run.Error}})
 			}
 		default:
 			// Handle other errors
 
 		}
-	case pluginChan := <-helper(plugingCtx, plugin):
-		c.addHistory(pluginChan.set)
-		if pluginChan.err != nil {
-			for k, v := range pluginChan.set {
-				scenarioSuccessGaugeVec.WithLabelValues(strcase.ToCamel(featureName), k).Set(float64(CucumberFailure))
-				for _, stats := range v.Stats {
-					stepSuccessGaugeVec.WithLabelValues(strcase.ToCamel(featureName), k, stats.Id).Set(float64(stats.Result))
-				}
+	case pluginChan := <-runAttempts(plugingCtx, plugin, run, module.Retry):
+		recordNetwork()
+		vitals.flush(plugingCtx)
+		run.setPages(vitals.collected())
+		observeVitals(strcase.ToCamel(featureName), run)
+		run.complete(pluginChan.set, pluginChan.err)
+		endRunSpan(ctx, span, run, recorder.completed())
+		c.finishRun(run)
+		observeRun(strcase.ToCamel(featureName), run)
+		observeAttempts(strcase.ToCamel(featureName), run)
+		c.notify(run, outcomes(run))
+		for scenario := range run.Set {
+			scenarioAttemptsGaugeVec.WithLabelValues(strcase.ToCamel(featureName), scenario).Set(float64(run.ScenarioAttempts(scenario)))
+		}
+		for _, d := range run.VisualDiffs {
+			visualDiffGaugeVec.WithLabelValues(strcase.ToCamel(featureName), d.Scenario, d.Baseline).Set(d.Ratio)
+		}
+		for _, p := range run.Pages {
+			for phase, d := range p.Phases() {
+				navigationPhaseGaugeVec.WithLabelValues(strcase.ToCamel(featureName), p.Scenario, p.Path, phase).Set(d)
 			}
-		} else {
-			for k, v := range pluginChan.set {
-				isSucceeded := true
-				for _, stats := range v.Stats {
-					stepDurationGaugeVec.WithLabelValues(strcase.ToCamel(featureName), k, stats.Id, stats.Result.String()).Set(stats.Duration.Seconds())
-					stepSuccessGaugeVec.WithLabelValues(strcase.ToCamel(featureName), k, stats.Id).Set(float64(stats.Result))
-					if stats.Result != CucumberSuccess {
-						isSucceeded = false
-					}
-				}
-				//0 failure
-				if isSucceeded {
-					scenarioSuccessGaugeVec.WithLabelValues(strcase.ToCamel(featureName), k).Set(float64(CucumberSuccess))
-				} else {
-					scenarioSuccessGaugeVec.WithLabelValues(strcase.ToCamel(featureName), k).Set(float64(CucumberFailure))
-				}
+			if p.LCP >= 0 {
+				lcpGaugeVec.WithLabelValues(strcase.ToCamel(featureName), p.Scenario, p.Path).Set(p.LCP)
+			}
+			if p.INP >= 0 {
+				inpGaugeVec.WithLabelValues(strcase.ToCamel(featureName), p.Scenario, p.Path).Set(p.INP)
+			}
+			if p.FID >= 0 {
+				fidGaugeVec.WithLabelValues(strcase.ToCamel(featureName), p.Scenario, p.Path).Set(p.FID)
+			}
+			clsGaugeVec.WithLabelValues(strcase.ToCamel(featureName), p.Scenario, p.Path).Set(p.CLS)
+		}
+		for scenario, stats := range run.Network {
+			if scenario == "" {
+				// Requests issued before the first step are not bound to any scenario
+				continue
+			}
+			networkRequestsGaugeVec.WithLabelValues(strcase.ToCamel(featureName), scenario).Set(float64(stats.Requests))
+			networkFailedGaugeVec.WithLabelValues(strcase.ToCamel(featureName), scenario).Set(float64(stats.Failed))
+			networkBytesGaugeVec.WithLabelValues(strcase.ToCamel(featureName), scenario).Set(float64(stats.Bytes))
+		}
+		// A scenario that passed in an attempt is successful even though a later attempt failed
+		for k, v := range pluginChan.set {
+			for _, stats := range v.Stats {
+				stepDurationGaugeVec.WithLabelValues(strcase.ToCamel(featureName), k, stats.Id, stats.Result.String(), run.NetworkProfileOf(k)).Set(stats.Duration.Seconds())
+				stepSuccessGaugeVec.WithLabelValues(strcase.ToCamel(featureName), k, stats.Id, run.NetworkProfileOf(k)).Set(float64(stats.Result))
+			}
+			if scenarioPassed(v) {
+				scenarioSuccessGaugeVec.WithLabelValues(strcase.ToCamel(featureName), k, run.NetworkProfileOf(k)).Set(float64(CucumberSuccess))
+			} else {
+				scenarioSuccessGaugeVec.WithLabelValues(strcase.ToCamel(featureName), k, run.NetworkProfileOf(k)).Set(float64(CucumberFailure))
 			}
 		}
 	}
-	h := promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+	if c.pusher != nil {
+		go c.push(registry, map[string]string{
+			"feature": featureName,
+			"module":  moduleName,
+			"target":  target,
+		})
+	}
+	h := promhttp.HandlerFor(registry, promhttp.HandlerOpts{
+		EnableOpenMetrics: true,
+	})
 	h.ServeHTTP(w, r)
 }
@@ -0,0 +1,138 @@
+package exporters
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/speijnik/go-errortree"
+)
+
+// DiscoveryConfig sets how the static targets of a module are listed by the service discovery endpoint
+type DiscoveryConfig struct {
+	// Features probed on every static target, all the registered ones when empty
+	Features []string `json:"features,omitempty"`
+	// Labels added to the discovered targets
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// TargetGroup is a group of targets in the Prometheus http_sd_configs format
+type TargetGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+var labelNameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+func (d DiscoveryConfig) validate() error {
+	var rcerror error
+
+	for n, f := range d.Features {
+		if f == "" {
+			rcerror = errortree.Add(rcerror, fmt.Sprintf("features[%d]", n), errors.New("empty feature name"))
+		}
+	}
+	for k := range d.Labels {
+		if !labelNameRe.MatchString(k) || strings.HasPrefix(k, "__") {
+			rcerror = errortree.Add(rcerror, "labels", fmt.Errorf("invalid label name %q", k))
+		}
+	}
+
+	return rcerror
+}
+
+func WithCucumberServiceDiscovery(prefix string) ExporterOption {
+
+	return ExportOptionFn(func(i interface{}) error {
+		var rcerror error
+		var c *cucumberHandler
+		var ok bool
+
+		if c, ok = i.(*cucumberHandler); ok {
+			probes := path.Join(prefix, "/probes")
+			c.Handle(path.Join(prefix, "/sd"), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c.ServiceDiscoveryEndpoint(w, r, probes)
+			}))
+			return nil
+		}
+
+		return errortree.Add(rcerror, "WithCucumberServiceDiscovery", errors.New("type mismatch, cucumberHandler expected"))
+	})
+}
+
+// ServiceDiscoveryEndpoint lists a target group for every static target of the modules and feature,
+// scraped through the /probes endpoint of this exporter at the address the request was sent to.
+func (c *cucumberHandler) ServiceDiscoveryEndpoint(w http.ResponseWriter, r *http.Request, probes string) {
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	groups := c.targetGroups(r.Host, scheme, probes)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(groups); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (c *cucumberHandler) targetGroups(address string, scheme string, probes string) []TargetGroup {
+
+	c.pluginMutex.RLock()
+	registered := make([]string, 0, len(c.PluginSet))
+	for name := range c.PluginSet {
+		registered = append(registered, name)
+	}
+	c.pluginMutex.RUnlock()
+	sort.Strings(registered)
+
+	names := make([]string, 0, len(c.modules))
+	for name := range c.modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	groups := []TargetGroup{}
+	for _, name := range names {
+		m := c.modules[name]
+		features := registered
+		if len(m.Discovery.Features) > 0 {
+			features = nil
+			for _, f := range m.Discovery.Features {
+				// Skip the features unknown to /probes
+				if n := sort.SearchStrings(registered, f); n < len(registered) && registered[n] == f {
+					features = append(features, f)
+				}
+			}
+		}
+		for _, target := range m.Targets.Static {
+			for _, feature := range features {
+				labels := map[string]string{
+					"__scheme__":       scheme,
+					"__metrics_path__": probes,
+					"__param_module":   name,
+					"__param_feature":  feature,
+					"__param_target":   target,
+					"instance":         target,
+					"module":           name,
+					"feature":          feature,
+				}
+				for k, v := range m.Discovery.Labels {
+					if _, reserved := labels[k]; !reserved {
+						labels[k] = v
+					}
+				}
+				groups = append(groups, TargetGroup{
+					Targets: []string{address},
+					Labels:  labels,
+				})
+			}
+		}
+	}
+
+	return groups
+}
@@ -0,0 +1,173 @@
+package exporters
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// stubPlugin is a registered feature that is never run
+type stubPlugin struct{}
+
+func (stubPlugin) Do(context.Context) (CucumberStatsSet, error) { return nil, nil }
+
+func (stubPlugin) GetScenarioName() (string, error) { return "", nil }
+
+// discover serves the service discovery endpoint of the handler and returns the groups listed
+func discover(t *testing.T, c *cucumberHandler) []TargetGroup {
+
+	t.Helper()
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/uxperi/sd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("status %s, content type %s", resp.Status, resp.Header.Get("Content-Type"))
+	}
+	var groups []TargetGroup
+	if err = json.NewDecoder(resp.Body).Decode(&groups); err != nil {
+		t.Fatal(err)
+	}
+	for _, g := range groups {
+		if len(g.Targets) != 1 || g.Targets[0] != strings.TrimPrefix(srv.URL, "http://") {
+			t.Errorf("group %v does not scrape the exporter at %s", g.Targets, srv.URL)
+		}
+		if g.Labels["__scheme__"] != "http" || g.Labels["__metrics_path__"] != "/uxperi/probes" {
+			t.Errorf("group %v is not scraped through /probes", g.Labels)
+		}
+	}
+
+	return groups
+}
+
+// assigned lists the module, feature and target of the groups
+func assigned(groups []TargetGroup) string {
+
+	var got []string
+	for _, g := range groups {
+		got = append(got, g.Labels["__param_module"]+" "+g.Labels["__param_feature"]+" "+g.Labels["__param_target"])
+	}
+
+	return strings.Join(got, ", ")
+}
+
+func newDiscoveryHandler(t *testing.T, modules map[string]Module, features ...string) *cucumberHandler {
+
+	t.Helper()
+	opts := []ExporterOption{WithCucumberModules(modules), WithCucumberServiceDiscovery("/uxperi")}
+	for _, f := range features {
+		opts = append(opts, WithCucumberPlugin(f, stubPlugin{}))
+	}
+	c, err := NewCucumberExporter(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c.(*cucumberHandler)
+}
+
+func TestServiceDiscoveryModules(t *testing.T) {
+
+	c := newDiscoveryHandler(t, map[string]Module{
+		"prod": {
+			Targets: TargetsConfig{Static: []string{"https://app.example.com/", "https://admin.example.com/"}},
+			Discovery: DiscoveryConfig{
+				Features: []string{"login.feature", "unknown.feature"},
+				Labels:   map[string]string{"env": "prod", "module": "overridden", "__param_target": "https://evil.example.com/"},
+			},
+		},
+		"staging": {
+			Targets: TargetsConfig{Static: []string{"https://staging.example.com/"}},
+		},
+		// Without static targets, a module is not discovered
+		"adhoc": {
+			Targets: TargetsConfig{Hosts: []string{"*.example.com"}},
+		},
+	}, "login.feature", "search.feature")
+
+	groups := discover(t, c)
+	want := "prod login.feature https://app.example.com/, prod login.feature https://admin.example.com/, " +
+		"staging login.feature https://staging.example.com/, staging search.feature https://staging.example.com/"
+	if got := assigned(groups); got != want {
+		t.Fatalf("discovered %s, want %s", got, want)
+	}
+	for _, g := range groups {
+		module := g.Labels["__param_module"]
+		if g.Labels["module"] != module || g.Labels["feature"] != g.Labels["__param_feature"] || g.Labels["instance"] != g.Labels["__param_target"] {
+			t.Errorf("group %v labels do not match its params", g.Labels)
+		}
+		if env, ok := g.Labels["env"]; (module == "prod") != ok || (ok && env != "prod") {
+			t.Errorf("group of module %s has the env label %q", module, env)
+		}
+	}
+}
+
+func TestServiceDiscoveryRefresh(t *testing.T) {
+
+	c := newDiscoveryHandler(t, map[string]Module{
+		"prod": {
+			Targets:   TargetsConfig{Static: []string{"https://app.example.com/"}},
+			Discovery: DiscoveryConfig{Features: []string{"search.feature"}},
+		},
+	}, "login.feature")
+
+	// The listed feature is not registered yet, the module has nothing to probe
+	if groups := discover(t, c); len(groups) != 0 {
+		t.Fatalf("discovered %s, want none", assigned(groups))
+	}
+	// Every request lists the features registered since
+	if err := c.registerCucumberPlugin("search.feature", stubPlugin{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := assigned(discover(t, c)); got != "prod search.feature https://app.example.com/" {
+		t.Errorf("discovered %s after the registration", got)
+	}
+}
+
+func TestServiceDiscoveryEmpty(t *testing.T) {
+
+	c := newDiscoveryHandler(t, nil, "login.feature")
+	// An empty list, not null, so Prometheus drops the previous targets
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/uxperi/sd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(body)) != "[]" {
+		t.Errorf("body = %s, want []", body)
+	}
+}
+
+func TestDiscoveryConfigValidate(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		config  DiscoveryConfig
+		wantErr bool
+	}{
+		{name: "empty"},
+		{name: "valid", config: DiscoveryConfig{Features: []string{"login.feature"}, Labels: map[string]string{"env": "prod", "_team": "web"}}},
+		{name: "empty feature", config: DiscoveryConfig{Features: []string{"login.feature", ""}}, wantErr: true},
+		{name: "invalid label", config: DiscoveryConfig{Labels: map[string]string{"env-name": "prod"}}, wantErr: true},
+		{name: "leading digit", config: DiscoveryConfig{Labels: map[string]string{"1env": "prod"}}, wantErr: true},
+		{name: "reserved label", config: DiscoveryConfig{Labels: map[string]string{"__param_target": "https://evil.example.com/"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := tt.config.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: validate() = %v, want an error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package exporters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"github.com/speijnik/go-errortree"
+)
+
+const (
+	// NetworkProfileNone disables the network emulation
+	NetworkProfileNone = "none"
+	// NetworkProfileCustom uses the latency and throughput of the module
+	NetworkProfileCustom  = "custom"
+	NetworkProfileOffline = "offline"
+)
+
+// NetworkProfile describes the network conditions emulated by the browser
+type NetworkProfile struct {
+	Name    string
+	Offline bool
+	Latency time.Duration
+	// DownloadKbps and UploadKbps are the throughput in kilobits per second, 0 means unlimited
+	DownloadKbps float64
+	UploadKbps   float64
+}
+
+// networkProfiles are the presets of the Chrome DevTools and Lighthouse
+var networkProfiles = map[string]NetworkProfile{
+	NetworkProfileNone: {
+		Name: NetworkProfileNone,
+	},
+	NetworkProfileOffline: {
+		Name:    NetworkProfileOffline,
+		Offline: true,
+	},
+	"slow-3g": {
+		Name:         "slow-3g",
+		Latency:      2000 * time.Millisecond,
+		DownloadKbps: 400,
+		UploadKbps:   400,
+	},
+	"3g": {
+		Name:         "3g",
+		Latency:      562500 * time.Microsecond,
+		DownloadKbps: 1474.56,
+		UploadKbps:   675,
+	},
+	"slow-4g": {
+		Name:         "slow-4g",
+		Latency:      150 * time.Millisecond,
+		DownloadKbps: 1638.4,
+		UploadKbps:   750,
+	},
+}
+
+// LookupNetworkProfile returns the preset with the given name
+func LookupNetworkProfile(name string) (NetworkProfile, error) {
+	var rcerror error
+
+	if p, ok := networkProfiles[strings.ToLower(name)]; ok {
+		return p, nil
+	}
+	names := make([]string, 0, len(networkProfiles))
+	for n := range networkProfiles {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	return NetworkProfile{}, errortree.Add(rcerror, "LookupNetworkProfile", fmt.Errorf("unknown network profile %q, expected one of %s", name, strings.Join(names, ", ")))
+}
+
+// EmulationConfig sets the network conditions and the CPU speed emulated by the browser
+type EmulationConfig struct {
+	// Network is the name of a network profile, custom to use the latency and throughput below
+	Network      string  `json:"network"`
+	LatencyMs    float64 `json:"latency_ms,omitempty"`
+	DownloadKbps float64 `json:"download_kbps,omitempty"`
+	UploadKbps   float64 `json:"upload_kbps,omitempty"`
+	// CPUThrottling is the slowdown factor of the CPU, 1 means no throttling
+	CPUThrottling float64 `json:"cpu_throttling"`
+}
+
+// NetworkProfile returns the network conditions configured
+func (e EmulationConfig) NetworkProfile() (NetworkProfile, error) {
+
+	if e.Network == NetworkProfileCustom {
+		return NetworkProfile{
+			Name:         NetworkProfileCustom,
+			Latency:      time.Duration(e.LatencyMs * float64(time.Millisecond)),
+			DownloadKbps: e.DownloadKbps,
+			UploadKbps:   e.UploadKbps,
+		}, nil
+	}
+
+	return LookupNetworkProfile(e.Network)
+}
+
+func (e EmulationConfig) validate() error {
+	var rcerror error
+
+	if _, err := e.NetworkProfile(); err != nil {
+		rcerror = errortree.Add(rcerror, "network", err)
+	}
+	if e.Network == NetworkProfileCustom && (e.LatencyMs < 0 || e.DownloadKbps < 0 || e.UploadKbps < 0) {
+		rcerror = errortree.Add(rcerror, "network", errors.New("custom network requires non negative latency and throughput"))
+	}
+	if e.CPUThrottling < 1 {
+		rcerror = errortree.Add(rcerror, "cpu_throttling", fmt.Errorf("throttling factor %v lower than 1", e.CPUThrottling))
+	}
+
+	return rcerror
+}
+
+// EmulateNetwork applies the network conditions to the browser
+func EmulateNetwork(p NetworkProfile) chromedp.Action {
+
+	throughput := func(kbps float64) float64 {
+		if kbps <= 0 {
+			// -1 disables the throttling
+			return -1
+		}
+		// DevTools expects bytes per second
+		return kbps * 1024 / 8
+	}
+
+	return network.EmulateNetworkConditions(p.Offline,
+		float64(p.Latency)/float64(time.Millisecond),
+		throughput(p.DownloadKbps),
+		throughput(p.UploadKbps))
+}
+
+// EmulateCPU slows down the CPU of the browser by the given factor
+func EmulateCPU(rate float64) chromedp.Action {
+
+	if rate < 1 {
+		rate = 1
+	}
+
+	return emulation.SetCPUThrottlingRate(rate)
+}
+
+// emulate applies the emulation settings of the module
+func emulate(e EmulationConfig) chromedp.Action {
+
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		p, err := e.NetworkProfile()
+		if err != nil {
+			return err
+		}
+		if p.Name != NetworkProfileNone {
+			if err = EmulateNetwork(p).Do(ctx); err != nil {
+				return err
+			}
+		}
+		if e.CPUThrottling > 1 {
+			return EmulateCPU(e.CPUThrottling).Do(ctx)
+		}
+
+		return nil
+	})
+}
+
+// SetNetworkProfile records the network profile emulated by the scenario stored in the context
+func SetNetworkProfile(ctx context.Context, name string) {
+
+	run, err := RunFromContext(ctx)
+	if err != nil {
+		return
+	}
+	scenario, _ := StringFromContext(ctx, ContextKeyScenarioName)
+
+	run.mutex.Lock()
+	defer run.mutex.Unlock()
+	if run.profiles == nil {
+		run.profiles = make(map[string]string)
+	}
+	run.profiles[scenario] = name
+}
+
+// NetworkProfileOf returns the last network profile emulated by the scenario
+func (r *ProbeRun) NetworkProfileOf(scenario string) string {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if p, ok := r.profiles[scenario]; ok {
+		return p
+	}
+
+	return r.NetworkProfile
+}
@@ -0,0 +1,75 @@
+package exporters
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLookupNetworkProfile(t *testing.T) {
+
+	for _, name := range []string{"none", "offline", "slow-3g", "3g", "slow-4g", "Slow-4G"} {
+		p, err := LookupNetworkProfile(name)
+		if err != nil {
+			t.Errorf("LookupNetworkProfile(%q): %v", name, err)
+			continue
+		}
+		if p.Name != strings.ToLower(name) {
+			t.Errorf("LookupNetworkProfile(%q) = %s", name, p.Name)
+		}
+	}
+	// The custom profile is only built from the parameters of a module
+	for _, name := range []string{"", "custom", "4g", "slow 3g"} {
+		if _, err := LookupNetworkProfile(name); err == nil {
+			t.Errorf("LookupNetworkProfile(%q) succeeded", name)
+		} else if !strings.Contains(err.Error(), "expected one of 3g, none, offline, slow-3g, slow-4g") {
+			t.Errorf("LookupNetworkProfile(%q) error %q does not list the profiles", name, err)
+		}
+	}
+}
+
+func TestEmulationConfigNetworkProfile(t *testing.T) {
+
+	p, err := EmulationConfig{Network: NetworkProfileCustom, LatencyMs: 120.5, DownloadKbps: 2048, UploadKbps: 512}.NetworkProfile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := NetworkProfile{Name: NetworkProfileCustom, Latency: 120500 * time.Microsecond, DownloadKbps: 2048, UploadKbps: 512}
+	if p != want {
+		t.Errorf("NetworkProfile() = %+v, want %+v", p, want)
+	}
+	// The parameters of a custom network are ignored by the presets
+	p, err = EmulationConfig{Network: "slow-3g", LatencyMs: 1, DownloadKbps: 1}.NetworkProfile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p != networkProfiles["slow-3g"] {
+		t.Errorf("NetworkProfile() = %+v, want the slow-3g preset", p)
+	}
+}
+
+func TestEmulationConfigValidate(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		config  EmulationConfig
+		wantErr bool
+	}{
+		{name: "default", config: DefaultModule().Emulation},
+		{name: "preset", config: EmulationConfig{Network: "3g", CPUThrottling: 4}},
+		{name: "custom", config: EmulationConfig{Network: NetworkProfileCustom, LatencyMs: 40, DownloadKbps: 10240, CPUThrottling: 1}},
+		{name: "custom unlimited", config: EmulationConfig{Network: NetworkProfileCustom, CPUThrottling: 1}},
+		{name: "unknown profile", config: EmulationConfig{Network: "5g", CPUThrottling: 1}, wantErr: true},
+		{name: "empty profile", config: EmulationConfig{CPUThrottling: 1}, wantErr: true},
+		{name: "negative latency", config: EmulationConfig{Network: NetworkProfileCustom, LatencyMs: -1, CPUThrottling: 1}, wantErr: true},
+		{name: "negative download", config: EmulationConfig{Network: NetworkProfileCustom, DownloadKbps: -1, CPUThrottling: 1}, wantErr: true},
+		{name: "negative upload", config: EmulationConfig{Network: NetworkProfileCustom, UploadKbps: -1, CPUThrottling: 1}, wantErr: true},
+		{name: "no cpu throttling factor", config: EmulationConfig{Network: NetworkProfileNone}, wantErr: true},
+		{name: "cpu speedup", config: EmulationConfig{Network: NetworkProfileNone, CPUThrottling: 0.5}, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := tt.config.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: validate() = %v, want an error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
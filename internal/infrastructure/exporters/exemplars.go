@@ -0,0 +1,118 @@
+package exporters
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	// ExemplarRunId is the exemplar label holding the id of the run in the history
+	ExemplarRunId = "run_id"
+	// ExemplarTraceId is the exemplar label holding the trace of the run, when tracing is enabled
+	ExemplarTraceId = "trace_id"
+)
+
+var (
+	runDurationHistogramVec = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "probe_run_duration_seconds",
+		Help:    "Duration of the probe runs",
+		Buckets: []float64{.5, 1, 2.5, 5, 10, 20, 30, 60, 120},
+	}, []string{"feature_name", "module"})
+
+	scenarioDurationHistogramVec = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "scenario_duration_seconds",
+		Help:    "Duration of the scenarios run by the probes",
+		Buckets: []float64{.25, .5, 1, 2.5, 5, 10, 20, 30, 60},
+	}, []string{"feature_name", "scenario_name", "network_profile"})
+
+	scenarioFailuresCounterVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "scenario_failures_total",
+		Help: "Scenarios that failed or did not finish before the probe timeout",
+	}, []string{"feature_name", "scenario_name", "network_profile"})
+
+	stepFailuresCounterVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "step_failures_total",
+		Help: "Steps that failed",
+	}, []string{"feature_name", "scenario_name", "step_name", "network_profile"})
+)
+
+func init() {
+	prometheus.MustRegister(runDurationHistogramVec)
+	prometheus.MustRegister(scenarioDurationHistogramVec)
+	prometheus.MustRegister(scenarioFailuresCounterVec)
+	prometheus.MustRegister(stepFailuresCounterVec)
+}
+
+// metricsHandler exposes the process wide metrics, with the exemplars when the scraper negotiates
+// the OpenMetrics format
+func metricsHandler() http.Handler {
+
+	return promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{
+		EnableOpenMetrics: true,
+	}))
+}
+
+// Exemplar returns the exemplar labels linking a sample to the run in the history
+func (r *ProbeRun) Exemplar() prometheus.Labels {
+
+	labels := prometheus.Labels{
+		ExemplarRunId: r.Id,
+	}
+	if r.TraceId != "" {
+		labels[ExemplarTraceId] = r.TraceId
+	}
+
+	return labels
+}
+
+// observeWithExemplar records the value with the exemplar of the run
+func observeWithExemplar(o prometheus.Observer, v float64, run *ProbeRun) {
+
+	if eo, ok := o.(prometheus.ExemplarObserver); ok {
+		eo.ObserveWithExemplar(v, run.Exemplar())
+		return
+	}
+	o.Observe(v)
+}
+
+// incWithExemplar increments the counter with the exemplar of the run
+func incWithExemplar(c prometheus.Counter, run *ProbeRun) {
+
+	if ea, ok := c.(prometheus.ExemplarAdder); ok {
+		ea.AddWithExemplar(1, run.Exemplar())
+		return
+	}
+	c.Inc()
+}
+
+// observeRun feeds the process wide duration histograms and failure counters with the finished
+// run, every sample carrying the exemplar of the run
+func observeRun(feature string, run *ProbeRun) {
+
+	observeWithExemplar(runDurationHistogramVec.WithLabelValues(feature, run.Module), run.Duration.Seconds(), run)
+	for scenario, item := range run.Set {
+		profile := run.NetworkProfileOf(scenario)
+		var d float64
+		failed := run.Error != ""
+		for _, st := range item.Stats {
+			d += st.Duration.Seconds()
+			if st.Result == CucumberFailure {
+				failed = true
+				incWithExemplar(stepFailuresCounterVec.WithLabelValues(feature, scenario, st.Id, profile), run)
+			}
+		}
+		observeWithExemplar(scenarioDurationHistogramVec.WithLabelValues(feature, scenario, profile), d, run)
+		if failed {
+			incWithExemplar(scenarioFailuresCounterVec.WithLabelValues(feature, scenario, profile), run)
+		}
+	}
+}
+
+// observeTimeout counts the scenario that did not finish before the probe timeout as failed
+func observeTimeout(feature string, scenario string, run *ProbeRun) {
+
+	observeWithExemplar(runDurationHistogramVec.WithLabelValues(feature, run.Module), run.Duration.Seconds(), run)
+	incWithExemplar(scenarioFailuresCounterVec.WithLabelValues(feature, scenario, run.NetworkProfileOf(scenario)), run)
+}
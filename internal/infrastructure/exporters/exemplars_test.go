@@ -0,0 +1,121 @@
+package exporters
+
+import (
+	"bufio"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// exemplarLine matches an OpenMetrics sample of the feature with its exemplar labels
+var exemplarLine = regexp.MustCompile(`^(\w+)\{[^}]*feature_name="ExemplarsFeature"[^}]*\} \S+ # \{([^}]*)\}`)
+
+// scrapeExemplars returns the exemplar labels of the samples of the feature, by metric name
+func scrapeExemplars(t *testing.T, srv *httptest.Server) map[string][]map[string]string {
+
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/metrics", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The Accept header of a Prometheus server with the exemplar storage enabled
+	req.Header.Set("Accept", "application/openmetrics-text;version=1.0.0,application/openmetrics-text;version=0.0.1;q=0.75,text/plain;version=0.0.4;q=0.5,*/*;q=0.1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/openmetrics-text") {
+		t.Fatalf("content type %s, OpenMetrics expected", ct)
+	}
+
+	exemplars := make(map[string][]map[string]string)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		m := exemplarLine.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		labels := make(map[string]string)
+		for _, pair := range strings.Split(m[2], ",") {
+			k, v, _ := strings.Cut(pair, "=")
+			labels[k] = strings.Trim(v, `"`)
+		}
+		exemplars[m[1]] = append(exemplars[m[1]], labels)
+	}
+	if err = scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return exemplars
+}
+
+func TestExemplarsLinkHistory(t *testing.T) {
+
+	e, err := NewCucumberExporter(WithCucumberRootPrefix("/"), WithCucumberHistoryEndpoint("/", 4, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := e.(*cucumberHandler)
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	run := newProbeRun("exemplars.feature", "", "https://app.example.com")
+	run.TraceId = "4bf92f3577b34da6a3ce929d0e0e4736"
+	c.startRun(run)
+	run.complete(CucumberStatsSet{
+		"Login": {Stats: []CucumberStats{
+			{Id: "IOpenTheLoginPage", Duration: time.Second, Result: CucumberSuccess},
+			{Id: "IShouldBeRedirectedToTheDashboardPage", Duration: time.Second, Result: CucumberFailure, Error: "timeout"},
+		}},
+	}, errors.New("scenario failed"))
+	c.finishRun(run)
+	observeRun("ExemplarsFeature", run)
+
+	exemplars := scrapeExemplars(t, srv)
+	for _, metric := range []string{"probe_run_duration_seconds_bucket", "scenario_duration_seconds_bucket", "scenario_failures_total", "step_failures_total"} {
+		if len(exemplars[metric]) == 0 {
+			t.Errorf("%s has no exemplar", metric)
+		}
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	for metric, list := range exemplars {
+		for _, labels := range list {
+			if labels[ExemplarRunId] != run.Id || labels[ExemplarTraceId] != run.TraceId {
+				t.Errorf("%s exemplar %v, want the run %s and trace %s", metric, labels, run.Id, run.TraceId)
+				continue
+			}
+			// The run id of the exemplar opens the run in the history
+			resp, err := client.Get(srv.URL + "/history?" + ExemplarRunId + "=" + labels[ExemplarRunId])
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("%s exemplar run %s: status %s", metric, labels[ExemplarRunId], resp.Status)
+			}
+			// The trace id redirects to the run of the trace
+			resp, err = client.Get(srv.URL + "/history?" + ExemplarTraceId + "=" + labels[ExemplarTraceId])
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "/history?id="+run.Id {
+				t.Errorf("%s exemplar trace %s: status %s, location %s", metric, labels[ExemplarTraceId], resp.Status, resp.Header.Get("Location"))
+			}
+		}
+	}
+	// A trace unknown to the history is not found
+	resp, err := client.Get(srv.URL + "/history?" + ExemplarTraceId + "=00000000000000000000000000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown trace: status %s", resp.Status)
+	}
+}
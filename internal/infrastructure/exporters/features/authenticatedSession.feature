@@ -0,0 +1,6 @@
+Feature: Authenticated session
+
+Scenario: Reuse the session
+  Given I have an authenticated session
+  When I open the target page
+  Then I should be redirected to the dashboard page
@@ -0,0 +1,392 @@
+package features
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"fry.org/cmo/cli/internal/application/artifacts"
+	"fry.org/cmo/cli/internal/infrastructure/exporters"
+	"fry.org/cmo/cli/internal/infrastructure/visual"
+	"github.com/chromedp/chromedp"
+	"github.com/cucumber/godog"
+	"github.com/iancoleman/strcase"
+	"github.com/speijnik/go-errortree"
+)
+
+// browserSteps implements the steps and hooks shared by the browser based features
+type browserSteps struct {
+	ctx       context.Context
+	artifacts artifacts.ArtifactStore
+	// feature is the name used to scope the visual regression baselines
+	feature string
+	// step is the id of the step being executed
+	step string
+}
+
+func (b *browserSteps) registerBrowserSteps(ctx *godog.ScenarioContext) {
+
+	ctx.Step(`^I take a screenshot named "([^"]*)"$`, b.iTakeAScreenshotNamed)
+	ctx.Step(`^the page should match the baseline "([^"]*)"$`, b.thePageShouldMatchTheBaseline)
+	ctx.Step(`^there should be no JavaScript errors$`, b.thereShouldBeNoJavaScriptErrors)
+	ctx.Step(`^I am on a "([^"]*)" network$`, b.iAmOnANetwork)
+	ctx.Step(`^the network has (\d+)ms of latency, (\d+) kbps of download and (\d+) kbps of upload$`, b.theNetworkHas)
+	ctx.Step(`^the browser is offline$`, b.theBrowserIsOffline)
+	ctx.Step(`^the browser is back online$`, b.theBrowserIsBackOnline)
+	ctx.Step(`^the CPU is (\d+(?:\.\d+)?) times slower$`, b.theCPUIsTimesSlower)
+	ctx.Step(`^requests to "([^"]*)" are blocked$`, b.requestsToAreBlocked)
+	ctx.Step(`^requests carry the header "([^"]*)" with value "([^"]*)"$`, b.requestsCarryTheHeader)
+	ctx.Step(`^requests to "([^"]*)" respond with status (\d+)$`, b.requestsToRespondWithStatus)
+	ctx.Step(`^requests to "([^"]*)" respond with status (\d+) and body:$`, b.requestsToRespondWithStatusAndBody)
+	ctx.Step(`^I wait for "([^"]*)" to be visible$`, b.iWaitForToBeVisible)
+	ctx.Step(`^I wait for "([^"]*)" to be hidden$`, b.iWaitForToBeHidden)
+	ctx.Step(`^I wait for the URL to match "([^"]*)"$`, b.iWaitForTheURLToMatch)
+	ctx.Step(`^I wait for the network to be idle$`, b.iWaitForTheNetworkToBeIdle)
+	ctx.Step(`^I wait until the script returns true:$`, b.iWaitUntilTheScriptReturnsTrue)
+}
+
+// snapshotStep applies the screenshot policy of the module once a step has finished
+func (b *browserSteps) snapshotStep(step string, failed bool) error {
+
+	cfg := exporters.ModuleFromContext(b.ctx).Screenshots
+	switch {
+	case cfg.Policy == exporters.ScreenshotEveryStep,
+		cfg.Policy == exporters.ScreenshotOnFailure && failed:
+		return takeSnapshot(b.ctx, b.artifacts, cfg, step, step)
+	}
+
+	return nil
+}
+
+func (b *browserSteps) iTakeAScreenshotNamed(name string) error {
+	var rcerror error
+
+	cfg := exporters.ModuleFromContext(b.ctx).Screenshots
+	if err := takeSnapshot(b.ctx, b.artifacts, cfg, b.step, name); err != nil {
+		return errortree.Add(rcerror, "iTakeAScreenshotNamed", err)
+	}
+
+	return nil
+}
+
+func (b *browserSteps) thePageShouldMatchTheBaseline(name string) error {
+	var rcerror, err error
+	var buf []byte
+	var baseline, actual image.Image
+
+	if b.artifacts == nil {
+		return errortree.Add(rcerror, "thePageShouldMatchTheBaseline", errors.New("artifact store not configured"))
+	}
+	name = snapshotNameRe.ReplaceAllString(name, "_")
+	module := exporters.ModuleFromContext(b.ctx)
+	// Baselines are always compared losslessly
+	cfg := module.Screenshots
+	cfg.Format = exporters.ScreenshotPNG
+	if err = chromedp.Run(b.ctx, captureScreenshot(cfg, &buf)); err != nil {
+		return errortree.Add(rcerror, "thePageShouldMatchTheBaseline", err)
+	}
+	if actual, err = png.Decode(bytes.NewReader(buf)); err != nil {
+		return errortree.Add(rcerror, "thePageShouldMatchTheBaseline", err)
+	}
+	run, err := exporters.RunFromContext(b.ctx)
+	if err != nil {
+		return errortree.Add(rcerror, "thePageShouldMatchTheBaseline", err)
+	}
+	host, err := b.targetHost()
+	if err != nil {
+		return errortree.Add(rcerror, "thePageShouldMatchTheBaseline", err)
+	}
+	// Keep the screenshot as candidate so it can be approved as the new baseline
+	if err = b.artifacts.Put(b.ctx, artifacts.CandidateKey(run.Module, host, b.feature, name), "image/png", buf); err != nil {
+		return errortree.Add(rcerror, "thePageShouldMatchTheBaseline", err)
+	}
+	prefix := fmt.Sprintf("%s-%s", time.Now().Format("20060102150405"), name)
+	if err = b.saveImage(prefix+"-actual.png", buf); err != nil {
+		return errortree.Add(rcerror, "thePageShouldMatchTheBaseline", err)
+	}
+	if baseline, err = b.baseline(artifacts.BaselineKey(run.Module, host, b.feature, name)); err != nil {
+		if errors.Is(err, artifacts.ErrNotFound) {
+			return errortree.Add(rcerror, "thePageShouldMatchTheBaseline",
+				fmt.Errorf("no baseline %q for feature %q of module %q on %s, approve it with 'uxperi baseline approve --baseline.feature=%s --baseline.module=%s --baseline.target=%s %s'",
+					name, b.feature, run.Module, host, b.feature, run.Module, host, name))
+		}
+		return errortree.Add(rcerror, "thePageShouldMatchTheBaseline", err)
+	}
+
+	opts := visual.Options{
+		Threshold: module.Visual.Threshold,
+	}
+	for _, r := range module.Visual.Ignore {
+		opts.Ignore = append(opts.Ignore, image.Rect(r.X, r.Y, r.X+r.Width, r.Y+r.Height))
+	}
+	res := visual.Compare(baseline, actual, opts)
+	exporters.AddVisualDiff(b.ctx, exporters.VisualDiff{
+		Baseline: name,
+		Ratio:    res.Ratio,
+	})
+	if res.DiffPixels > 0 {
+		diff := new(bytes.Buffer)
+		if err = png.Encode(diff, res.Diff); err != nil {
+			return errortree.Add(rcerror, "thePageShouldMatchTheBaseline", err)
+		}
+		if err = b.saveImage(prefix+"-diff.png", diff.Bytes()); err != nil {
+			return errortree.Add(rcerror, "thePageShouldMatchTheBaseline", err)
+		}
+	}
+	if res.Ratio > module.Visual.MaxDiffRatio {
+		return errortree.Add(rcerror, "thePageShouldMatchTheBaseline",
+			fmt.Errorf("%d of %d pixels differ from baseline %q (ratio %.4f > %.4f)", res.DiffPixels, res.ComparedPixels, name, res.Ratio, module.Visual.MaxDiffRatio))
+	}
+
+	return nil
+}
+
+func (b *browserSteps) thereShouldBeNoJavaScriptErrors() error {
+	var rcerror error
+
+	run, err := exporters.RunFromContext(b.ctx)
+	if err != nil {
+		return errortree.Add(rcerror, "thereShouldBeNoJavaScriptErrors", err)
+	}
+	scenario, err := exporters.StringFromContext(b.ctx, exporters.ContextKeyScenarioName)
+	if err != nil {
+		return errortree.Add(rcerror, "thereShouldBeNoJavaScriptErrors", err)
+	}
+	if errs := run.JSErrors(scenario); len(errs) > 0 {
+		msgs := make([]string, 0, len(errs))
+		for _, e := range errs {
+			msgs = append(msgs, fmt.Sprintf("%s (%s)", e.Text, e.Source))
+		}
+		return errortree.Add(rcerror, "thereShouldBeNoJavaScriptErrors",
+			fmt.Errorf("%d JavaScript exceptions thrown: %s", len(errs), strings.Join(msgs, "; ")))
+	}
+
+	return nil
+}
+
+func (b *browserSteps) emulateNetwork(p exporters.NetworkProfile) error {
+
+	if err := chromedp.Run(b.ctx, exporters.EmulateNetwork(p)); err != nil {
+		return err
+	}
+	exporters.SetNetworkProfile(b.ctx, p.Name)
+
+	return nil
+}
+
+func (b *browserSteps) iAmOnANetwork(name string) error {
+	var rcerror error
+
+	p, err := exporters.LookupNetworkProfile(name)
+	if err != nil {
+		return errortree.Add(rcerror, "iAmOnANetwork", err)
+	}
+	if err = b.emulateNetwork(p); err != nil {
+		return errortree.Add(rcerror, "iAmOnANetwork", err)
+	}
+
+	return nil
+}
+
+func (b *browserSteps) theNetworkHas(latency int, download int, upload int) error {
+	var rcerror error
+
+	if err := b.emulateNetwork(exporters.NetworkProfile{
+		Name:         exporters.NetworkProfileCustom,
+		Latency:      time.Duration(latency) * time.Millisecond,
+		DownloadKbps: float64(download),
+		UploadKbps:   float64(upload),
+	}); err != nil {
+		return errortree.Add(rcerror, "theNetworkHas", err)
+	}
+
+	return nil
+}
+
+func (b *browserSteps) theBrowserIsOffline() error {
+	var rcerror error
+
+	p, _ := exporters.LookupNetworkProfile(exporters.NetworkProfileOffline)
+	if err := b.emulateNetwork(p); err != nil {
+		return errortree.Add(rcerror, "theBrowserIsOffline", err)
+	}
+
+	return nil
+}
+
+// theBrowserIsBackOnline restores the network conditions of the module
+func (b *browserSteps) theBrowserIsBackOnline() error {
+	var rcerror error
+
+	p, err := exporters.ModuleFromContext(b.ctx).Emulation.NetworkProfile()
+	if err != nil {
+		return errortree.Add(rcerror, "theBrowserIsBackOnline", err)
+	}
+	if err = b.emulateNetwork(p); err != nil {
+		return errortree.Add(rcerror, "theBrowserIsBackOnline", err)
+	}
+
+	return nil
+}
+
+func (b *browserSteps) theCPUIsTimesSlower(rate float64) error {
+	var rcerror error
+
+	if err := chromedp.Run(b.ctx, exporters.EmulateCPU(rate)); err != nil {
+		return errortree.Add(rcerror, "theCPUIsTimesSlower", err)
+	}
+
+	return nil
+}
+
+func (b *browserSteps) requestsToAreBlocked(glob string) error {
+	var rcerror error
+
+	if err := exporters.BlockRequests(b.ctx, glob); err != nil {
+		return errortree.Add(rcerror, "requestsToAreBlocked", err)
+	}
+
+	return nil
+}
+
+func (b *browserSteps) requestsCarryTheHeader(name string, value string) error {
+	var rcerror error
+
+	if err := exporters.InjectRequestHeader(b.ctx, name, value); err != nil {
+		return errortree.Add(rcerror, "requestsCarryTheHeader", err)
+	}
+
+	return nil
+}
+
+func (b *browserSteps) requestsToRespondWithStatus(glob string, status int) error {
+	var rcerror error
+
+	if err := exporters.MockResponses(b.ctx, exporters.MockRule{
+		URL:    glob,
+		Status: status,
+	}); err != nil {
+		return errortree.Add(rcerror, "requestsToRespondWithStatus", err)
+	}
+
+	return nil
+}
+
+func (b *browserSteps) requestsToRespondWithStatusAndBody(glob string, status int, body *godog.DocString) error {
+	var rcerror error
+
+	if err := exporters.MockResponses(b.ctx, exporters.MockRule{
+		URL:         glob,
+		Status:      status,
+		ContentType: body.MediaType,
+		Body:        body.Content,
+	}); err != nil {
+		return errortree.Add(rcerror, "requestsToRespondWithStatusAndBody", err)
+	}
+
+	return nil
+}
+
+// wait waits for the condition with the wait settings of the module
+func (b *browserSteps) wait(cond exporters.WaitCondition) error {
+
+	return exporters.Wait(b.ctx, cond, exporters.ModuleFromContext(b.ctx).Wait.Options())
+}
+
+func (b *browserSteps) iWaitForToBeVisible(selector string) error {
+	var rcerror error
+
+	if err := b.wait(exporters.SelectorVisible(selector)); err != nil {
+		return errortree.Add(rcerror, "iWaitForToBeVisible", err)
+	}
+
+	return nil
+}
+
+func (b *browserSteps) iWaitForToBeHidden(selector string) error {
+	var rcerror error
+
+	if err := b.wait(exporters.SelectorHidden(selector)); err != nil {
+		return errortree.Add(rcerror, "iWaitForToBeHidden", err)
+	}
+
+	return nil
+}
+
+func (b *browserSteps) iWaitForTheURLToMatch(pattern string) error {
+	var rcerror error
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return errortree.Add(rcerror, "iWaitForTheURLToMatch", err)
+	}
+	if err = b.wait(exporters.URLMatches(re)); err != nil {
+		return errortree.Add(rcerror, "iWaitForTheURLToMatch", err)
+	}
+
+	return nil
+}
+
+func (b *browserSteps) iWaitForTheNetworkToBeIdle() error {
+	var rcerror error
+
+	cfg := exporters.ModuleFromContext(b.ctx).Wait
+	if err := b.wait(exporters.NetworkIdle(cfg.NetworkIdle(), cfg.LongRequest())); err != nil {
+		return errortree.Add(rcerror, "iWaitForTheNetworkToBeIdle", err)
+	}
+
+	return nil
+}
+
+func (b *browserSteps) iWaitUntilTheScriptReturnsTrue(script *godog.DocString) error {
+	var rcerror error
+
+	if err := b.wait(exporters.JSPredicate(script.Content)); err != nil {
+		return errortree.Add(rcerror, "iWaitUntilTheScriptReturnsTrue", err)
+	}
+
+	return nil
+}
+
+// baseline loads the approved baseline stored under the key
+func (b *browserSteps) baseline(key string) (image.Image, error) {
+
+	r, err := b.artifacts.Get(b.ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return png.Decode(bytes.NewReader(content))
+}
+
+// targetHost returns the host of the probed target, it scopes the baselines with the module
+func (b *browserSteps) targetHost() (string, error) {
+
+	target, err := exporters.StringFromContext(b.ctx, exporters.ContextKeyTargetUrl)
+	if err != nil {
+		return "", err
+	}
+
+	return artifacts.TargetHost(target)
+}
+
+func (b *browserSteps) saveImage(name string, content []byte) error {
+
+	return exporters.SaveArtifact(b.ctx, b.artifacts, exporters.Artifact{
+		Name:        name,
+		Step:        strcase.ToCamel(b.step),
+		ContentType: "image/png",
+	}, content)
+}
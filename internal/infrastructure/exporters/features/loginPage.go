@@ -5,28 +5,43 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net/url"
 	"path"
 	"time"
 
+	"fry.org/cmo/cli/internal/application/artifacts"
+	"fry.org/cmo/cli/internal/application/credentials"
 	"fry.org/cmo/cli/internal/application/logger"
+	"fry.org/cmo/cli/internal/application/sessions"
 	"fry.org/cmo/cli/internal/infrastructure/exporters"
+	"fry.org/cmo/cli/internal/infrastructure/redact"
+	"fry.org/cmo/cli/internal/infrastructure/totp"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
 	"github.com/cucumber/godog"
 	"github.com/cucumber/godog/colors"
 	"github.com/iancoleman/strcase"
-	"github.com/sethvargo/go-retry"
 	"github.com/speijnik/go-errortree"
 )
 
 type loginPage struct {
 	logger.Logger
+	browserSteps
 	featureFolder string
-	ctx           context.Context
 	statsSet      exporters.CucumberStatsSet
-	auth          struct {
-		id       string
-		password string
+	// auth is the credential used when the module does not name one
+	auth        credentials.Credential
+	credentials credentials.CredentialStore
+	// user is the credential the scenario logged in with, nil until a step picks one
+	user    *credentials.Credential
+	session struct {
+		store  sessions.SessionStore
+		maxAge time.Duration
+		// restored is set when the scenario reuses a stored session instead of logging in
+		restored bool
+		script   page.ScriptIdentifier
 	}
-	snapshotsFolder string
 }
 
 func NewLoginPageFeature(p string, opts ...exporters.ExporterOption) (exporters.CucumberPlugin, error) {
@@ -35,6 +50,7 @@ func NewLoginPageFeature(p string, opts ...exporters.ExporterOption) (exporters.
 	l := loginPage{
 		featureFolder: path.Join(p, "loginPage.feature"),
 	}
+	l.feature = "loginPage"
 	// Loop through each option
 	for _, option := range opts {
 		if err := option.Apply(&l); err != nil {
@@ -45,7 +61,26 @@ func NewLoginPageFeature(p string, opts ...exporters.ExporterOption) (exporters.
 	return &l, nil
 }
 
-func WithLoginPageSnapshotFolder(path string) exporters.ExporterOption {
+// NewAuthenticatedSessionFeature runs the features that only need a logged in user. The session
+// saved by a previous login is reused when available, so the identity provider is not hit on every run.
+func NewAuthenticatedSessionFeature(p string, opts ...exporters.ExporterOption) (exporters.CucumberPlugin, error) {
+	var rcerror error
+
+	l := loginPage{
+		featureFolder: path.Join(p, "authenticatedSession.feature"),
+	}
+	l.feature = "authenticatedSession"
+	// Loop through each option
+	for _, option := range opts {
+		if err := option.Apply(&l); err != nil {
+			return nil, errortree.Add(rcerror, "NewAuthenticatedSessionFeature", err)
+		}
+	}
+
+	return &l, nil
+}
+
+func WithLoginPageArtifactStore(s artifacts.ArtifactStore) exporters.ExporterOption {
 
 	return exporters.ExportOptionFn(func(i interface{}) error {
 		var rcerror error
@@ -53,11 +88,11 @@ func WithLoginPageSnapshotFolder(path string) exporters.ExporterOption {
 		var ok bool
 
 		if pl, ok = i.(*loginPage); ok {
-			pl.snapshotsFolder = path
+			pl.artifacts = s
 			return nil
 		}
 
-		return errortree.Add(rcerror, "WithLoginPageSnapshotFolder", errors.New("type mismatch, loginPage expected"))
+		return errortree.Add(rcerror, "WithLoginPageArtifactStore", errors.New("type mismatch, loginPage expected"))
 	})
 }
 
@@ -85,8 +120,8 @@ func WithLoginPageAuth(id string, p string) exporters.ExporterOption {
 		var ok bool
 
 		if l, ok = i.(*loginPage); ok {
-			l.auth.id = id
-			l.auth.password = p
+			l.auth.Username = id
+			l.auth.Password = p
 			return nil
 		}
 
@@ -94,6 +129,64 @@ func WithLoginPageAuth(id string, p string) exporters.ExporterOption {
 	})
 }
 
+// WithLoginPageSessionStore saves the browser session after a successful login. Sessions older
+// than maxAge are not reused.
+func WithLoginPageSessionStore(s sessions.SessionStore, maxAge time.Duration) exporters.ExporterOption {
+
+	return exporters.ExportOptionFn(func(i interface{}) error {
+		var rcerror error
+		var l *loginPage
+		var ok bool
+
+		if l, ok = i.(*loginPage); ok {
+			l.session.store = s
+			l.session.maxAge = maxAge
+			return nil
+		}
+
+		return errortree.Add(rcerror, "WithLoginPageSessionStore", errors.New("type mismatch, loginPage expected"))
+	})
+}
+
+// WithLoginPageTOTP sets the secret, base32 or otpauth:// URI, of the one-time codes asked by the MFA prompt
+func WithLoginPageTOTP(secret string) exporters.ExporterOption {
+
+	return exporters.ExportOptionFn(func(i interface{}) error {
+		var rcerror error
+		var l *loginPage
+		var ok bool
+
+		if l, ok = i.(*loginPage); ok {
+			if secret != "" {
+				if _, err := totp.Parse(secret); err != nil {
+					return errortree.Add(rcerror, "WithLoginPageTOTP", err)
+				}
+			}
+			l.auth.TOTP = secret
+			return nil
+		}
+
+		return errortree.Add(rcerror, "WithLoginPageTOTP", errors.New("type mismatch, loginPage expected"))
+	})
+}
+
+// WithLoginPageCredentialStore sets the store of the credentials referenced by name from the modules and the steps
+func WithLoginPageCredentialStore(s credentials.CredentialStore) exporters.ExporterOption {
+
+	return exporters.ExportOptionFn(func(i interface{}) error {
+		var rcerror error
+		var l *loginPage
+		var ok bool
+
+		if l, ok = i.(*loginPage); ok {
+			l.credentials = s
+			return nil
+		}
+
+		return errortree.Add(rcerror, "WithLoginPageCredentialStore", errors.New("type mismatch, loginPage expected"))
+	})
+}
+
 func (pl *loginPage) suiteInit(ctx *godog.TestSuiteContext) {
 
 	ctx.BeforeSuite(func() {
@@ -106,7 +199,15 @@ func (pl *loginPage) scenarioInit(ctx *godog.ScenarioContext) {
 
 	ctx.Before(func(c context.Context, sc *godog.Scenario) (context.Context, error) {
 		// This code will be executed once, before any scenarios are run
+		tags := make([]string, 0, len(sc.Tags))
+		for _, t := range sc.Tags {
+			tags = append(tags, t.Name)
+		}
 		pl.ctx = context.WithValue(pl.ctx, exporters.ContextKeyScenarioName, strcase.ToCamel(sc.Name))
+		pl.ctx = context.WithValue(pl.ctx, exporters.ContextKeyScenarioTags, tags)
+		pl.user = nil
+		pl.session.restored = false
+		pl.session.script = ""
 		return context.WithValue(c, exporters.ContextKeyScenarioName, strcase.ToCamel(sc.Name)), nil
 	})
 
@@ -132,6 +233,13 @@ func (pl *loginPage) scenarioInit(ctx *godog.ScenarioContext) {
 			item := pl.statsSet[name]
 			item.Stats = append(item.Stats, stat)
 			pl.statsSet[name] = item
+			pl.step = stat.Id
+			exporters.PublishRunEvent(pl.ctx, exporters.RunEvent{
+				Kind:     exporters.RunEventStepStarted,
+				Time:     stat.Start,
+				Scenario: name,
+				Step:     stat.Id,
+			})
 		}
 
 		return c, nil
@@ -145,6 +253,7 @@ func (pl *loginPage) scenarioInit(ctx *godog.ScenarioContext) {
 			stat.Duration = time.Since(stat.Start)
 			if err != nil {
 				stat.Result = exporters.CucumberFailure
+				stat.Error = err.Error()
 			} else {
 				switch status {
 				case 0:
@@ -156,6 +265,20 @@ func (pl *loginPage) scenarioInit(ctx *godog.ScenarioContext) {
 				}
 			}
 			pl.statsSet[name].Stats[len(pl.statsSet[name].Stats)-1] = stat
+			if e := pl.snapshotStep(stat.Id, stat.Result == exporters.CucumberFailure); e != nil {
+				exporters.PublishRunEvent(pl.ctx, exporters.RunEvent{
+					Kind: exporters.RunEventOutput,
+					Line: fmt.Sprintf("failed to take the snapshot of the step %s: %s", stat.Id, e.Error()),
+				})
+			}
+			exporters.PublishRunEvent(pl.ctx, exporters.RunEvent{
+				Kind:     exporters.RunEventStepFinished,
+				Scenario: name,
+				Step:     stat.Id,
+				Result:   stat.Result.String(),
+				Duration: stat.Duration.String(),
+				Error:    stat.Error,
+			})
 		}
 		return c, nil
 	})
@@ -166,8 +289,14 @@ func (pl *loginPage) registerSteps(ctx *godog.ScenarioContext) {
 
 	ctx.Step(`^I am on the login page$`, pl.iAmOnTheLoginPage)
 	ctx.Step(`^I enter my username and password$`, pl.iEnterMyUsernameAndPassword)
+	ctx.Step(`^I enter my one-time code$`, pl.iEnterMyOneTimeCode)
 	ctx.Step(`^I click the login button$`, pl.iClickTheLoginButton)
 	ctx.Step(`^I should be redirected to the dashboard page$`, pl.iShouldBeRedirectedToTheDashboardPage)
+	ctx.Step(`^I have an authenticated session$`, pl.iHaveAnAuthenticatedSession)
+	ctx.Step(`^I have an authenticated session as "([^"]*)"$`, pl.iHaveAnAuthenticatedSessionAs)
+	ctx.Step(`^I log in as "([^"]*)"$`, pl.iLogInAs)
+	ctx.Step(`^I open the target page$`, pl.iOpenTheTargetPage)
+	pl.registerBrowserSteps(ctx)
 }
 
 func (pl *loginPage) GetScenarioName() (string, error) {
@@ -182,7 +311,12 @@ func (pl *loginPage) Do(c context.Context) (exporters.CucumberStatsSet, error) {
 
 	pl.ctx = c
 	buf := new(bytes.Buffer)
-	if content, err := exporters.GetFeature(exporters.FeaturesFS, pl.featureFolder); err != nil {
+	content, err := exporters.GetFeature(exporters.FeaturesFS, pl.featureFolder)
+	if err == nil {
+		// A retry only runs the scenarios that did not pass yet
+		content, err = exporters.FilterScenarios(c, content)
+	}
+	if err != nil {
 		return pl.statsSet, errortree.Add(rcerror, "loginPage.Do", err)
 	} else {
 
@@ -190,7 +324,7 @@ func (pl *loginPage) Do(c context.Context) (exporters.CucumberStatsSet, error) {
 
 			// Output: io.Discard,
 			// Output: colors.Colored(os.Stdout),
-			Output: colors.Colored(buf),
+			Output: colors.Colored(io.MultiWriter(buf, exporters.NewRunOutputWriter(pl.ctx))),
 			//pretty, progress, cucumber, events and junit
 			Format:        "pretty",
 			StopOnFailure: true,
@@ -200,7 +334,7 @@ func (pl *loginPage) Do(c context.Context) (exporters.CucumberStatsSet, error) {
 		}
 	}
 	suite := godog.TestSuite{
-		Name:                 "loginPage",
+		Name:                 pl.feature,
 		TestSuiteInitializer: pl.suiteInit,
 		ScenarioInitializer:  pl.scenarioInit,
 		Options:              &godogOpts,
@@ -213,12 +347,19 @@ func (pl *loginPage) Do(c context.Context) (exporters.CucumberStatsSet, error) {
 	}()
 	// fmt.Printf("[DBG]Waiting for context done\n")
 	<-done
-	fmt.Println(buf.String())
+	output := redact.String(buf.String())
+	// The run output writer already published every line, the whole output is only logged for debugging
+	if pl.Logger != nil {
+		pl.Logger.WithFields(logger.Fields{
+			"feature": pl.feature,
+			"output":  output,
+		}).Debug("Feature run finished")
+	}
 	if name, err := pl.GetScenarioName(); err != nil {
 		return pl.statsSet, errortree.Add(rcerror, "loginPage.Do", err)
 	} else {
 		item := pl.statsSet[name]
-		item.Output = buf.String()
+		item.Output = output
 		pl.statsSet[name] = item
 	}
 	// We have to return l.stats always to return the partial errors in case of error
@@ -242,14 +383,10 @@ func (pl *loginPage) iAmOnTheLoginPage() error {
 	// pl.Logger.WithFields(logger.Fields{
 	// 	"name": "I am on the login page",
 	// }).Debug("Executing step")
-	impl := loginPageImpl{
-		snapshotsFolder: pl.snapshotsFolder,
-	}
-	if err := impl.doAzureLogin(pl.ctx); err != nil {
-		takeSnapshot(pl.ctx, pl.snapshotsFolder, "iAmOnTheLoginPage")
+	impl := loginPageImpl{}
+	if err := impl.doLogin(pl.ctx); err != nil {
 		return errortree.Add(rcerror, "iAmOnTheLoginPage", err)
 	}
-	// takeSnapshot(pl.ctx, pl.snapshotsFolder, "iAmOnTheLoginPage_success")
 	// pl.Logger.WithFields(logger.Fields{
 	// 	"name": "I am on the login page",
 	// }).Debug("Step done")
@@ -260,22 +397,13 @@ func (pl *loginPage) iAmOnTheLoginPage() error {
 func (pl *loginPage) iEnterMyUsernameAndPassword() error {
 	var rcerror error
 
-	impl := loginPageImpl{
-		snapshotsFolder: pl.snapshotsFolder,
-	}
-	c := context.Background()
-	b := retry.NewConstant(500 * time.Millisecond)
-	b = retry.WithMaxDuration(7*time.Second, b)
-	if err := retry.Do(c, b, func(ct context.Context) error {
-		if err := impl.loadUserAndPasswordWindow(pl.ctx, pl.auth.id, pl.auth.password); err != nil {
-			// fmt.Println("[DBG]retry loadUserAndPasswordWindow")
-			takeSnapshot(pl.ctx, pl.snapshotsFolder, "iEnterMyUsernameAndPassword")
-			// This marks the error as retryable
-			return retry.RetryableError(err)
-		}
-		// fmt.Println("[DBG]success loadUserAndPasswordWindow")
-		// takeSnapshot(pl.ctx, pl.snapshotsFolder, "loadUserAndPasswordWindow_success")
-		return nil
+	cred, err := pl.credential()
+	if err != nil {
+		return errortree.Add(rcerror, "iEnterMyUsernameAndPassword", err)
+	}
+	impl := loginPageImpl{}
+	if err := exporters.RetryStep(pl.ctx, func(ctx context.Context) error {
+		return impl.loadUserAndPasswordWindow(ctx, cred.Username, cred.Password)
 	}); err != nil {
 		return errortree.Add(rcerror, "iEnterMyUsernameAndPassword", err)
 	}
@@ -283,54 +411,260 @@ func (pl *loginPage) iEnterMyUsernameAndPassword() error {
 	return nil
 }
 
-func (pl *loginPage) iClickTheLoginButton() error {
+// iEnterMyOneTimeCode answers the MFA prompt, it does nothing when the user has no TOTP secret
+func (pl *loginPage) iEnterMyOneTimeCode() error {
 	var rcerror error
 
-	impl := loginPageImpl{
-		snapshotsFolder: pl.snapshotsFolder,
-	}
-	c := context.Background()
-	b := retry.NewConstant(500 * time.Millisecond)
-	b = retry.WithMaxDuration(7*time.Second, b)
-	if err := retry.Do(c, b, func(ct context.Context) error {
-		if err := impl.loadConsentAzurePage(pl.ctx); err != nil {
-			// fmt.Println("[DBG]retry loadConsentAzurePage")
-			takeSnapshot(pl.ctx, pl.snapshotsFolder, "iClickTheLoginButton")
-			// This marks the error as retryable
-			return retry.RetryableError(err)
-		}
-		// fmt.Println("[DBG]success loadConsentAzurePage")
-		// takeSnapshot(pl.ctx, pl.snapshotsFolder, "loadConsentAzurePage_success")
+	cred, err := pl.credential()
+	if err != nil {
+		return errortree.Add(rcerror, "iEnterMyOneTimeCode", err)
+	}
+	if cred.TOTP == "" {
 		return nil
-	}); err != nil {
+	}
+	impl := loginPageImpl{}
+	if err := impl.enterOneTimeCode(pl.ctx, cred.TOTP); err != nil {
+		return errortree.Add(rcerror, "iEnterMyOneTimeCode", err)
+	}
+
+	return nil
+}
+
+func (pl *loginPage) iClickTheLoginButton() error {
+	var rcerror error
+
+	impl := loginPageImpl{}
+	if err := exporters.RetryStep(pl.ctx, impl.loadConsentPage); err != nil {
 		return errortree.Add(rcerror, "iClickTheLoginButton", err)
 	}
 
 	return nil
 }
 
+// dashboardLoaded checks the dashboard is shown, the tests replace it to run without a browser
+var dashboardLoaded = func(ctx context.Context) error {
+
+	impl := loginPageImpl{}
+
+	return impl.isMainFELoad(ctx)
+}
+
 func (pl *loginPage) iShouldBeRedirectedToTheDashboardPage() error {
 	var rcerror error
 
-	impl := loginPageImpl{
-		snapshotsFolder: pl.snapshotsFolder,
-	}
-	c := context.Background()
-	b := retry.NewConstant(500 * time.Millisecond)
-	b = retry.WithMaxDuration(7*time.Second, b)
-	if err := retry.Do(c, b, func(ct context.Context) error {
-		if err := impl.isMainFELoad(pl.ctx); err != nil {
-			// fmt.Println("[DBG]retry isMainFELoad")
-			takeSnapshot(pl.ctx, pl.snapshotsFolder, "iShouldBeRedirectedToTheDashboardPage")
-			// This marks the error as retryable
-			return retry.RetryableError(err)
+	if err := dashboardLoaded(pl.ctx); err != nil {
+		if pl.session.restored {
+			// The stored session is no longer valid, the next run logs in again
+			pl.deleteSession()
 		}
-		// fmt.Println("[DBG]success isMainFELoad")
-		// takeSnapshot(pl.ctx, pl.snapshotsFolder, "isMainFELoad_success")
-		return nil
-	}); err != nil {
 		return errortree.Add(rcerror, "iShouldBeRedirectedToTheDashboardPage", err)
 	}
+	if !pl.session.restored {
+		pl.saveSession()
+	}
 
 	return nil
 }
+
+func (pl *loginPage) iHaveAnAuthenticatedSessionAs(name string) error {
+	var rcerror error
+
+	cred, err := pl.lookupCredential(name)
+	if err != nil {
+		return errortree.Add(rcerror, "iHaveAnAuthenticatedSessionAs", err)
+	}
+	pl.user = &cred
+	if err = pl.iHaveAnAuthenticatedSession(); err != nil {
+		return errortree.Add(rcerror, "iHaveAnAuthenticatedSessionAs", err)
+	}
+
+	return nil
+}
+
+func (pl *loginPage) iHaveAnAuthenticatedSession() error {
+	var rcerror error
+
+	if s, ok := pl.loadSession(); ok {
+		id, err := restoreSession(pl.ctx, s)
+		if err != nil {
+			return errortree.Add(rcerror, "iHaveAnAuthenticatedSession", err)
+		}
+		pl.session.restored = true
+		pl.session.script = id
+		return nil
+	}
+	// There is no session to reuse, go through the whole login
+	cred, err := pl.credential()
+	if err != nil {
+		return errortree.Add(rcerror, "iHaveAnAuthenticatedSession", err)
+	}
+	impl := loginPageImpl{}
+	if err = impl.doFeature(pl.ctx, cred.Username, cred.Password, cred.TOTP); err != nil {
+		return errortree.Add(rcerror, "iHaveAnAuthenticatedSession", err)
+	}
+	pl.saveSession()
+
+	return nil
+}
+
+// iLogInAs goes through the whole login with a named credential
+func (pl *loginPage) iLogInAs(name string) error {
+	var rcerror error
+
+	cred, err := pl.lookupCredential(name)
+	if err != nil {
+		return errortree.Add(rcerror, "iLogInAs", err)
+	}
+	pl.user = &cred
+	impl := loginPageImpl{}
+	if err = impl.doFeature(pl.ctx, cred.Username, cred.Password, cred.TOTP); err != nil {
+		return errortree.Add(rcerror, "iLogInAs", err)
+	}
+	pl.saveSession()
+
+	return nil
+}
+
+// credential returns the credential of the scenario: the one picked by a step, the one named by
+// the module or the default one, in this order
+func (pl *loginPage) credential() (credentials.Credential, error) {
+
+	if pl.user != nil {
+		return *pl.user, nil
+	}
+	name := exporters.ModuleFromContext(pl.ctx).Login.Credential
+	if name == "" {
+		return pl.auth, nil
+	}
+	cred, err := pl.lookupCredential(name)
+	if err != nil {
+		return cred, err
+	}
+	pl.user = &cred
+
+	return cred, nil
+}
+
+func (pl *loginPage) lookupCredential(name string) (credentials.Credential, error) {
+
+	if pl.credentials == nil {
+		return credentials.Credential{}, fmt.Errorf("credential %q requested but no credential store configured", name)
+	}
+	cred, err := pl.credentials.Get(pl.ctx, name)
+	if err != nil {
+		if errors.Is(err, credentials.ErrNotFound) {
+			return cred, fmt.Errorf("unknown credential %q", name)
+		}
+		return cred, err
+	}
+	redact.AddSecret(cred.Password, cred.TOTP)
+
+	return cred, nil
+}
+
+func (pl *loginPage) iOpenTheTargetPage() error {
+	var rcerror error
+
+	target, err := exporters.StringFromContext(pl.ctx, exporters.ContextKeyTargetUrl)
+	if err != nil {
+		return errortree.Add(rcerror, "iOpenTheTargetPage", err)
+	}
+	if err = chromedp.Run(pl.ctx, chromedp.Navigate(target)); err != nil {
+		return errortree.Add(rcerror, "iOpenTheTargetPage", err)
+	}
+	if pl.session.script != "" {
+		if err = chromedp.Run(pl.ctx, page.RemoveScriptToEvaluateOnNewDocument(pl.session.script)); err != nil {
+			return errortree.Add(rcerror, "iOpenTheTargetPage", err)
+		}
+		pl.session.script = ""
+	}
+
+	return nil
+}
+
+// sessionKey identifies the session of the user in the target host
+func (pl *loginPage) sessionKey() (string, error) {
+
+	target, err := exporters.StringFromContext(pl.ctx, exporters.ContextKeyTargetUrl)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", err
+	}
+	cred, err := pl.credential()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s|%s", u.Host, cred.Username), nil
+}
+
+// loadSession returns the stored session when it exists and it is not too old
+func (pl *loginPage) loadSession() (sessions.Session, bool) {
+
+	if pl.session.store == nil {
+		return sessions.Session{}, false
+	}
+	key, err := pl.sessionKey()
+	if err != nil {
+		pl.warn("Failed to load session", err)
+		return sessions.Session{}, false
+	}
+	s, err := pl.session.store.Load(pl.ctx, key)
+	if err != nil {
+		if !errors.Is(err, sessions.ErrNotFound) {
+			pl.warn("Failed to load session", err)
+		}
+		return s, false
+	}
+	if pl.session.maxAge > 0 && time.Since(s.Created) > pl.session.maxAge {
+		return s, false
+	}
+
+	return s, true
+}
+
+// saveSession stores the session of the browser. A failure does not fail the step, the next run just logs in again.
+func (pl *loginPage) saveSession() {
+
+	if pl.session.store == nil {
+		return
+	}
+	key, err := pl.sessionKey()
+	if err != nil {
+		pl.warn("Failed to save session", err)
+		return
+	}
+	s, err := captureSession(pl.ctx)
+	if err != nil {
+		pl.warn("Failed to save session", err)
+		return
+	}
+	s.Created = time.Now()
+	if err = pl.session.store.Save(pl.ctx, key, s); err != nil {
+		pl.warn("Failed to save session", err)
+	}
+}
+
+func (pl *loginPage) deleteSession() {
+
+	key, err := pl.sessionKey()
+	if err == nil {
+		err = pl.session.store.Delete(pl.ctx, key)
+	}
+	if err != nil {
+		pl.warn("Failed to delete session", err)
+	}
+}
+
+func (pl *loginPage) warn(msg string, err error) {
+
+	if pl.Logger != nil {
+		pl.Logger.WithFields(logger.Fields{
+			"feature": pl.feature,
+			"error":   err,
+		}).Warn(msg)
+	}
+}
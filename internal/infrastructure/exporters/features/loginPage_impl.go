@@ -2,135 +2,205 @@ package features
 
 import (
 	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"fry.org/cmo/cli/internal/infrastructure/exporters"
-	"github.com/chromedp/cdproto/runtime"
+	"fry.org/cmo/cli/internal/infrastructure/totp"
 	"github.com/chromedp/chromedp"
-	"github.com/sethvargo/go-retry"
 	"github.com/speijnik/go-errortree"
 )
 
-type loginPageImpl struct {
-	snapshotsFolder string
-}
+type loginPageImpl struct{}
 
 func (l *loginPageImpl) loadUserAndPasswordWindow(ctx context.Context, user string, pass string) error {
 	var rcerror error
 
-	// Wait for the email input field to become available
-	emailInput := `//input[@type='email']`
-	err := chromedp.Run(ctx, chromedp.Click(emailInput))
+	sel, err := exporters.ModuleFromContext(ctx).Login.LoginSelectors()
+	if err != nil {
+		return errortree.Add(rcerror, "loadUserAndPasswordWindow:selectors", err)
+	}
+	// Wait for the username input field to become available
+	err = chromedp.Run(ctx, chromedp.Click(sel.Username))
 	if err != nil || errors.Is(err, context.Canceled) {
 		return errortree.Add(rcerror, "loadUserAndPasswordWindow:getEmailbox", err)
 	}
 
-	// Fill in the email address
-	err = chromedp.Run(ctx, chromedp.SendKeys(emailInput, user, chromedp.BySearch))
+	// Fill in the username
+	err = chromedp.Run(ctx, chromedp.SendKeys(sel.Username, user, chromedp.BySearch))
 	if err != nil || errors.Is(err, context.Canceled) {
 		return errortree.Add(rcerror, "loadUserAndPasswordWindow:fillEmail", err)
 	}
 
-	// Click the "Next" button to proceed to the password page
-	nextButton := `//input[@value='Next']`
-	err = chromedp.Run(ctx, chromedp.Click(nextButton))
-	if err != nil || errors.Is(err, context.Canceled) {
-		return errortree.Add(rcerror, "loadUserAndPasswordWindow:submitEmail", err)
+	// Click the "Next" button to proceed to the password page, when the password is asked in another page
+	if sel.Next != "" {
+		err = chromedp.Run(ctx, chromedp.Click(sel.Next))
+		if err != nil || errors.Is(err, context.Canceled) {
+			return errortree.Add(rcerror, "loadUserAndPasswordWindow:submitEmail", err)
+		}
 	}
 
 	// Wait for the password input field to become available
-	passwordInput := `//input[@type='password']`
-	err = chromedp.Run(ctx, chromedp.Click(passwordInput))
+	err = chromedp.Run(ctx, chromedp.Click(sel.Password))
 	if err != nil || errors.Is(err, context.Canceled) {
 		return errortree.Add(rcerror, "loadUserAndPasswordWindow:getPasswordBox", err)
 	}
 
 	// Fill in the password
-	err = chromedp.Run(ctx, chromedp.SendKeys(passwordInput, pass, chromedp.BySearch))
+	err = chromedp.Run(ctx, chromedp.SendKeys(sel.Password, pass, chromedp.BySearch))
 	if err != nil || errors.Is(err, context.Canceled) {
 		return errortree.Add(rcerror, "loadUserAndPasswordWindow:fillPassword", err)
 	}
-	// Click the "Sign in" button to proceed to the OAuth2 consent page
-	signInButton := `//input[@type='submit']`
-	time.Sleep(3 * time.Second)
-	c := context.Background()
-	b := retry.NewConstant(500 * time.Millisecond)
-	b = retry.WithMaxDuration(5*time.Second, b)
-	if err := retry.Do(c, b, func(ct context.Context) error {
-		err = chromedp.Run(ctx, chromedp.Click(signInButton))
-		if err != nil {
-			if errors.Is(err, context.Canceled) {
-				return errortree.Add(rcerror, "loadUserAndPasswordWindow:submitPassword", err)
-			} else {
-				fmt.Println("[DBG]retry loadUserAndPasswordWindow:submitPassword")
-				// This marks the error as retryable
-				return retry.RetryableError(err)
-			}
-		}
-		// fmt.Println("[DBG]success loadUserAndPasswordWindow:submitPassword")
-		return nil
-	}); err != nil {
+	// Click the "Sign in" button to proceed to the OAuth2 consent page, the callers retry the whole form
+	err = chromedp.Run(ctx, chromedp.Click(sel.Submit))
+	if err != nil || errors.Is(err, context.Canceled) {
 		return errortree.Add(rcerror, "loadUserAndPasswordWindow:submitPassword", err)
 	}
 
 	return nil
 }
 
-func (l *loginPageImpl) loadConsentAzurePage(ctx context.Context) error {
+// otpSteps remembers the time step of the last code reserved with every secret, as the identity
+// providers reject a code used twice
+var otpSteps = struct {
+	sync.Mutex
+	last map[[sha256.Size]byte]uint64
+}{
+	last: make(map[[sha256.Size]byte]uint64),
+}
+
+// reserveStep returns the first time step not used yet with the secret whose code is valid long
+// enough to be submitted. The step is reserved, the concurrent logins sharing the secret get the next ones.
+func reserveStep(key totp.Key, secret string, now time.Time) uint64 {
+
+	otpSteps.Lock()
+	defer otpSteps.Unlock()
+
+	id := sha256.Sum256([]byte(secret))
+	step := key.Step(now)
+	if key.Remaining(now) < 5*time.Second {
+		step++
+	}
+	if last, ok := otpSteps.last[id]; ok && step <= last {
+		step = last + 1
+	}
+	otpSteps.last[id] = step
+
+	return step
+}
+
+// nextOneTimeCode returns a code not used yet that is valid long enough to be submitted, waiting
+// for its time step when it is a future one
+func nextOneTimeCode(ctx context.Context, secret string) (string, error) {
+
+	key, err := totp.Parse(secret)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	step := reserveStep(key, secret, now)
+	if wait := key.Start(step).Sub(now); wait > 0 {
+		t := time.NewTimer(wait)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-t.C:
+		}
+	}
+
+	return key.Code(step), nil
+}
+
+func (l *loginPageImpl) enterOneTimeCode(ctx context.Context, secret string) error {
+	var rcerror error
+
+	sel, err := exporters.ModuleFromContext(ctx).Login.LoginSelectors()
+	if err != nil {
+		return errortree.Add(rcerror, "enterOneTimeCode:selectors", err)
+	}
+	if sel.OTP == "" {
+		return errortree.Add(rcerror, "enterOneTimeCode", errors.New("the login profile has no one-time code selector"))
+	}
+	// Wait for the one-time code field before generating the code, so it does not expire meanwhile
+	err = chromedp.Run(ctx, chromedp.Click(sel.OTP))
+	if err != nil || errors.Is(err, context.Canceled) {
+		return errortree.Add(rcerror, "enterOneTimeCode:getCodeBox", err)
+	}
+	code, err := nextOneTimeCode(ctx, secret)
+	if err != nil {
+		return errortree.Add(rcerror, "enterOneTimeCode:generateCode", err)
+	}
+	err = chromedp.Run(ctx, chromedp.SendKeys(sel.OTP, code, chromedp.BySearch))
+	if err != nil || errors.Is(err, context.Canceled) {
+		return errortree.Add(rcerror, "enterOneTimeCode:fillCode", err)
+	}
+	if sel.OTPSubmit != "" {
+		err = chromedp.Run(ctx, chromedp.Click(sel.OTPSubmit))
+		if err != nil || errors.Is(err, context.Canceled) {
+			return errortree.Add(rcerror, "enterOneTimeCode:submitCode", err)
+		}
+	}
+
+	return nil
+}
+
+func (l *loginPageImpl) loadConsentPage(ctx context.Context) error {
 	var rcerror error
 
+	sel, err := exporters.ModuleFromContext(ctx).Login.LoginSelectors()
+	if err != nil {
+		return errortree.Add(rcerror, "loadConsentPage:selectors", err)
+	}
+	// The identity provider does not ask for anything after signing in
+	if sel.Consent == "" {
+		return nil
+	}
 	// Click the "Accept" button to finish the OAuth2 flow
-	acceptButton := `//input[@type='submit']`
-	err := chromedp.Run(ctx, chromedp.Click(acceptButton))
+	err = chromedp.Run(ctx, chromedp.Click(sel.Consent))
 	if err != nil || errors.Is(err, context.Canceled) {
-		return errortree.Add(rcerror, "loadConsentAzurePage:submitOauth2", err)
+		return errortree.Add(rcerror, "loadConsentPage:submitOauth2", err)
 	}
 
 	return nil
 }
 
-func (l *loginPageImpl) doAzureLogin(ctx context.Context) error {
+func (l *loginPageImpl) doLogin(ctx context.Context) error {
 	var rcerror, err error
 	var redirectedURL, target string
 
 	target, err = exporters.StringFromContext(ctx, exporters.ContextKeyTargetUrl)
 	if err != nil || errors.Is(err, context.Canceled) {
-		return errortree.Add(rcerror, "doAzureLogin:extractURL", err)
+		return errortree.Add(rcerror, "doLogin:extractURL", err)
 	}
 	// Start by navigating to the login page
 	err = chromedp.Run(ctx, chromedp.Navigate(target))
 	if err != nil || errors.Is(err, context.Canceled) {
-		return errortree.Add(rcerror, "doAzureLogin:navigateURL", err)
+		return errortree.Add(rcerror, "doLogin:navigateURL", err)
 	}
 
 	// Check if the page has been redirected
 	err = chromedp.Run(ctx, chromedp.Evaluate(`window.location.href`, &redirectedURL))
 	if err != nil || errors.Is(err, context.Canceled) {
-		return errortree.Add(rcerror, "doAzureLogin:checkredirection", err)
+		return errortree.Add(rcerror, "doLogin:checkredirection", err)
 	}
 	if strings.Contains(redirectedURL, target) {
-		return errortree.Add(rcerror, "doAzureLogin", errors.New("redirection failed"))
+		return errortree.Add(rcerror, "doLogin", errors.New("redirection failed"))
 	}
 
 	return nil
 }
 
+// isMainFELoad waits for the dashboard as long as the step retry policy of the scenario, checking
+// it at the interval of the policy. The wait stands for the retries, it is not retried on top of that.
 func (l *loginPageImpl) isMainFELoad(ctx context.Context) error {
 	var rcerror error
 	// check if main.css has been loaded
 
-	chromedp.ListenTarget(ctx, func(ev interface{}) {
-		switch ev := ev.(type) {
-		case *runtime.EventExceptionThrown:
-			// Since ts.URL uses a random port, replace it.
-			s := ev.ExceptionDetails.Error()
-			fmt.Printf("* %s\n", s)
-		}
-	})
-
 	cssLoaded := false
 	err := chromedp.Run(ctx, chromedp.EvaluateAsDevTools(`
 		Array.from(document.querySelectorAll('link[rel="stylesheet"]'))
@@ -150,84 +220,60 @@ func (l *loginPageImpl) isMainFELoad(ctx context.Context) error {
 		return errortree.Add(rcerror, "isMainFELoad:loadjs", err)
 	}
 	// log.Printf("main.js loaded: %v", jsLoaded)
-	err = waitUntilLoads(ctx, "h3")
+	module := exporters.ModuleFromContext(ctx)
+	dashboard := module.Login.Dashboard
+	opts := exporters.StepPolicy(ctx).WaitOptions()
+	opts.ReloadEvery = time.Duration(dashboard.ReloadEveryMs * float64(time.Millisecond))
+	err = exporters.Wait(ctx, exporters.SelectorVisible(dashboard.Selector), opts)
 	if err != nil {
-		return errortree.Add(rcerror, "isMainFELoad", errors.New("failed to load h3 element in main page"))
+		return errortree.Add(rcerror, "isMainFELoad", fmt.Errorf("failed to load %s element in main page: %w", dashboard.Selector, err))
 	}
-	//Last, but not least, check if CREATION-PORTAL title is part of the html
-	htmlLoaded := ""
-	htmlContent := ""
-	expectedText := "CREATION PORTAL"
-
-	chromedp.Run(ctx, chromedp.Evaluate(`document.documentElement.outerHTML`, &htmlContent))
-	err = chromedp.Run(ctx, chromedp.Evaluate(`document.querySelector('h3') !== null ? document.querySelector('h3').textContent : null`, &htmlLoaded))
+	if dashboard.Text == "" {
+		return nil
+	}
+	//Last, but not least, check if the expected text is part of the element
+	var htmlLoaded *string
+	err = chromedp.Run(ctx, chromedp.Evaluate(fmt.Sprintf(`(() => {
+		const e = document.querySelector(%q);
+		return e !== null ? e.textContent : null;
+	})()`, dashboard.Selector), &htmlLoaded))
 	if err != nil {
-		if htmlLoaded == "" {
-			return errortree.Add(rcerror, "isMainFELoad", errors.New("creation portal element not found in html main page"))
-		} else {
-			if expectedText != htmlLoaded {
-				return errortree.Add(rcerror, "isMainFELoad", errors.New("element from main page didn't match which creation portal expression"))
-			}
-		}
-
+		return errortree.Add(rcerror, "isMainFELoad", err)
+	}
+	if htmlLoaded == nil {
+		return errortree.Add(rcerror, "isMainFELoad", fmt.Errorf("%s element not found in html main page", dashboard.Selector))
+	}
+	if !strings.Contains(*htmlLoaded, dashboard.Text) {
+		return errortree.Add(rcerror, "isMainFELoad", fmt.Errorf("%s element from main page didn't match %q", dashboard.Selector, dashboard.Text))
 	}
+
 	return nil
 }
 
-func (l *loginPageImpl) doFeature(ctx context.Context, user string, pass string) error {
-	var rcerror, err error
+// doFeature goes through the whole login. The snapshots of the failures are taken by the step
+// hooks, according to the screenshot policy of the module.
+func (l *loginPageImpl) doFeature(ctx context.Context, user string, pass string, otp string) error {
+	var rcerror error
 
 	impl := loginPageImpl{}
 
-	c := context.Background()
-	b := retry.NewConstant(500 * time.Millisecond)
-	b = retry.WithMaxDuration(7*time.Second, b)
-	if err := retry.Do(c, b, func(ct context.Context) error {
-		if err = impl.doAzureLogin(ctx); err != nil {
-			// fmt.Println("[DBG]retry doAzureLogin")
-			takeSnapshot(ctx, l.snapshotsFolder, "iEnterMyUsernameAndPassword")
-			// This marks the error as retryable
-			return retry.RetryableError(err)
-		}
-		// fmt.Println("[DBG]success doAzureLogin")
-		return nil
-	}); err != nil {
+	if err := exporters.RetryStep(ctx, impl.doLogin); err != nil {
 		return errortree.Add(rcerror, "doFeature.iEnterMyUsernameAndPassword", err)
 	}
-	if err := retry.Do(c, b, func(ct context.Context) error {
-		if err = impl.loadUserAndPasswordWindow(ctx, user, pass); err != nil {
-			// This marks the error as retryable
-			// fmt.Println("[DBG]retry loadUserAndPasswordWindow")
-			takeSnapshot(ctx, l.snapshotsFolder, "iEnterMyUsernameAndPassword")
-			return retry.RetryableError(err)
-		}
-		// fmt.Println("[DBG]success loadUserAndPasswordWindow")
-		return nil
+	if err := exporters.RetryStep(ctx, func(ct context.Context) error {
+		return impl.loadUserAndPasswordWindow(ct, user, pass)
 	}); err != nil {
 		return errortree.Add(rcerror, "doFeature.iEnterMyUsernameAndPassword", err)
 	}
-	if err := retry.Do(c, b, func(ct context.Context) error {
-		if err = impl.loadConsentAzurePage(ctx); err != nil {
-			// This marks the error as retryable
-			// fmt.Println("[DBG]retry loadConsentAzurePage")
-			takeSnapshot(ctx, l.snapshotsFolder, "iClickTheLoginButton")
-			return retry.RetryableError(err)
+	if otp != "" {
+		if err := impl.enterOneTimeCode(ctx, otp); err != nil {
+			return errortree.Add(rcerror, "doFeature.iEnterMyOneTimeCode", err)
 		}
-		// fmt.Println("[DBG]success loadConsentAzurePage")
-		return nil
-	}); err != nil {
+	}
+	if err := exporters.RetryStep(ctx, impl.loadConsentPage); err != nil {
 		return errortree.Add(rcerror, "doFeature.iClickTheLoginButton", err)
 	}
-	if err := retry.Do(c, b, func(ct context.Context) error {
-		if err := impl.isMainFELoad(ctx); err != nil {
-			// This marks the error as retryable
-			// fmt.Println("[DBG]retry isMainFELoad")
-			takeSnapshot(ctx, l.snapshotsFolder, "iShouldBeRedirectedToTheDashboardPage")
-			return retry.RetryableError(err)
-		}
-		// fmt.Println("[DBG]success isMainFELoad")
-		return nil
-	}); err != nil {
+	if err := impl.isMainFELoad(ctx); err != nil {
 		return errortree.Add(rcerror, "doFeature.iShouldBeRedirectedToTheDashboardPage", err)
 	}
 
@@ -240,12 +286,12 @@ func (l *loginPageImpl) doFeature(ctx context.Context, user string, pass string)
 
 // 	target, err = exporters.StringFromContext(ctx, exporters.ContextKeyTargetUrl)
 // 	if err != nil || errors.Is(err, context.Canceled) {
-// 		return errortree.Add(rcerror, "doAzureLogin:extractURL", err)
+// 		return errortree.Add(rcerror, "doLogin:extractURL", err)
 // 	}
 // 	// Start by navigating to the login page
 // 	err = chromedp.Run(ctx, chromedp.Navigate(target))
 // 	if err != nil || errors.Is(err, context.Canceled) {
-// 		return errortree.Add(rcerror, "doAzureLogin:navigateURL", err)
+// 		return errortree.Add(rcerror, "doLogin:navigateURL", err)
 // 	}
 // 	return nil
 // }
@@ -0,0 +1,37 @@
+package features
+
+import (
+	"testing"
+	"time"
+
+	"fry.org/cmo/cli/internal/infrastructure/totp"
+)
+
+func TestReserveStep(t *testing.T) {
+
+	const alice, bob = "JBSWY3DPEHPK3PXP", "GEZDGNBVGY3TQOJQ"
+	key, err := totp.Parse(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 20s left in the step, the current code is valid long enough
+	now := time.Unix(30*1000+10, 0)
+	if step := reserveStep(key, alice, now); step != 1000 {
+		t.Errorf("first step = %d, want 1000", step)
+	}
+	// The code of the step is used, the next login with the secret gets the next step
+	if step := reserveStep(key, alice, now); step != 1001 {
+		t.Errorf("second step = %d, want 1001", step)
+	}
+	if step := reserveStep(key, alice, now); step != 1002 {
+		t.Errorf("third step = %d, want 1002", step)
+	}
+	// The other secrets are not delayed
+	if step := reserveStep(key, bob, now); step != 1000 {
+		t.Errorf("step of another secret = %d, want 1000", step)
+	}
+	// Less than 5s left in the step, the code would expire before being submitted
+	if step := reserveStep(key, bob, time.Unix(30*1100+27, 0)); step != 1101 {
+		t.Errorf("step close to the end = %d, want 1101", step)
+	}
+}
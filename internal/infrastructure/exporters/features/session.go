@@ -0,0 +1,146 @@
+package features
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"fry.org/cmo/cli/internal/application/sessions"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/storage"
+	"github.com/chromedp/chromedp"
+	"github.com/speijnik/go-errortree"
+)
+
+// captureSession reads the cookies of the browser and the local storage of the current page
+func captureSession(ctx context.Context) (sessions.Session, error) {
+	var rcerror error
+	var s sessions.Session
+	var origin, items string
+
+	if err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		cookies, err := storage.GetCookies().Do(ctx)
+		if err != nil {
+			return err
+		}
+		s.Cookies = sessionCookies(cookies)
+		return nil
+	}),
+		chromedp.Evaluate(`window.location.origin`, &origin),
+		chromedp.Evaluate(`JSON.stringify(Object.assign({}, window.localStorage))`, &items),
+	); err != nil {
+		return s, errortree.Add(rcerror, "captureSession", err)
+	}
+	local := make(map[string]string)
+	if err := json.Unmarshal([]byte(items), &local); err != nil {
+		return s, errortree.Add(rcerror, "captureSession", err)
+	}
+	if len(local) > 0 {
+		s.LocalStorage = map[string]map[string]string{
+			origin: local,
+		}
+	}
+
+	return s, nil
+}
+
+// sessionCookies converts the cookies of the browser, the session cookies keep no expiry
+func sessionCookies(cookies []*network.Cookie) []sessions.Cookie {
+
+	var all []sessions.Cookie
+	for _, c := range cookies {
+		cookie := sessions.Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			HTTPOnly: c.HTTPOnly,
+			Secure:   c.Secure,
+			SameSite: c.SameSite.String(),
+		}
+		if !c.Session {
+			cookie.Expires = c.Expires
+		}
+		all = append(all, cookie)
+	}
+
+	return all
+}
+
+// restoreSession sets the cookies of the session and injects its local storage in the pages of
+// the same origin. The returned script must be removed once the session has been restored.
+func restoreSession(ctx context.Context, s sessions.Session) (page.ScriptIdentifier, error) {
+	var rcerror error
+	var id page.ScriptIdentifier
+
+	cookies := cookieParams(s.Cookies)
+	script, err := localStorageScript(s.LocalStorage)
+	if err != nil {
+		return id, errortree.Add(rcerror, "restoreSession", err)
+	}
+	if err = chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		if len(cookies) > 0 {
+			if err := network.SetCookies(cookies).Do(ctx); err != nil {
+				return err
+			}
+		}
+		if len(s.LocalStorage) > 0 {
+			if id, err = page.AddScriptToEvaluateOnNewDocument(script).Do(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})); err != nil {
+		return id, errortree.Add(rcerror, "restoreSession", err)
+	}
+
+	return id, nil
+}
+
+// cookieParams converts the cookies of the session for the browser, the expiry is in seconds since the epoch
+func cookieParams(cookies []sessions.Cookie) []*network.CookieParam {
+
+	params := make([]*network.CookieParam, 0, len(cookies))
+	for _, c := range cookies {
+		cookie := &network.CookieParam{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			HTTPOnly: c.HTTPOnly,
+			Secure:   c.Secure,
+			SameSite: network.CookieSameSite(c.SameSite),
+		}
+		if c.Expires > 0 {
+			sec, frac := math.Modf(c.Expires)
+			t := cdp.TimeSinceEpoch(time.Unix(int64(sec), int64(frac*1e9)))
+			cookie.Expires = &t
+		}
+		params = append(params, cookie)
+	}
+
+	return params
+}
+
+// localStorageScript returns the script filling the local storage of the pages with the items of their origin
+func localStorageScript(local map[string]map[string]string) (string, error) {
+
+	b, err := json.Marshal(local)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`(() => {
+		const items = (%s || {})[window.location.origin];
+		if (!items) {
+			return;
+		}
+		for (const [k, v] of Object.entries(items)) {
+			window.localStorage.setItem(k, v);
+		}
+	})();`, b), nil
+}
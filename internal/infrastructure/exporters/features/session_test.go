@@ -0,0 +1,157 @@
+package features
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"fry.org/cmo/cli/internal/application/credentials"
+	"fry.org/cmo/cli/internal/application/sessions"
+	"fry.org/cmo/cli/internal/infrastructure/exporters"
+	"github.com/chromedp/cdproto/network"
+)
+
+func TestSessionCookies(t *testing.T) {
+
+	browser := []*network.Cookie{
+		{Name: "ESTSAUTH", Value: "a", Domain: ".login.example.com", Path: "/", Expires: -1, HTTPOnly: true, Secure: true, Session: true, SameSite: network.CookieSameSiteNone},
+		{Name: "lang", Value: "en", Domain: "app.example.com", Path: "/", Expires: 1700000000.25, SameSite: network.CookieSameSiteLax},
+	}
+	want := []sessions.Cookie{
+		{Name: "ESTSAUTH", Value: "a", Domain: ".login.example.com", Path: "/", HTTPOnly: true, Secure: true, SameSite: "None"},
+		{Name: "lang", Value: "en", Domain: "app.example.com", Path: "/", Expires: 1700000000.25, SameSite: "Lax"},
+	}
+	got := sessionCookies(browser)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("sessionCookies = %+v, want %+v", got, want)
+	}
+
+	params := cookieParams(got)
+	if len(params) != 2 {
+		t.Fatalf("%d cookie params, want 2", len(params))
+	}
+	// The session cookie stays a session cookie
+	if params[0].Expires != nil || params[0].SameSite != network.CookieSameSiteNone || !params[0].HTTPOnly || !params[0].Secure {
+		t.Errorf("session cookie param = %+v", params[0])
+	}
+	if params[1].Expires == nil || !params[1].Expires.Time().Equal(time.Unix(1700000000, 250000000)) {
+		t.Errorf("expiry = %v, want 1700000000.25", params[1].Expires)
+	}
+	if params[1].Name != "lang" || params[1].Domain != "app.example.com" || params[1].SameSite != network.CookieSameSiteLax {
+		t.Errorf("cookie param = %+v", params[1])
+	}
+}
+
+func TestLocalStorageScript(t *testing.T) {
+
+	script, err := localStorageScript(map[string]map[string]string{
+		"https://app.example.com": {"msal.token": `{"secret":"a'b"}`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The items are embedded as JSON, keyed by origin
+	if !strings.Contains(script, `{"https://app.example.com":{"msal.token":"{\"secret\":\"a'b\"}"}}`) {
+		t.Errorf("script does not embed the items as JSON:\n%s", script)
+	}
+	if !strings.Contains(script, "[window.location.origin]") {
+		t.Errorf("script does not restrict the items to the origin of the page:\n%s", script)
+	}
+}
+
+// recordingSessions keeps the sessions in a map and counts the writes
+type recordingSessions struct {
+	sessions map[string]sessions.Session
+	saved    int
+	deleted  int
+}
+
+func (r *recordingSessions) Load(_ context.Context, key string) (sessions.Session, error) {
+
+	s, ok := r.sessions[key]
+	if !ok {
+		return s, sessions.ErrNotFound
+	}
+	return s, nil
+}
+
+func (r *recordingSessions) Save(_ context.Context, key string, s sessions.Session) error {
+
+	r.saved++
+	r.sessions[key] = s
+	return nil
+}
+
+func (r *recordingSessions) Delete(_ context.Context, key string) error {
+
+	r.deleted++
+	delete(r.sessions, key)
+	return nil
+}
+
+func newSessionLoginPage(created time.Time) (*loginPage, *recordingSessions) {
+
+	store := &recordingSessions{sessions: map[string]sessions.Session{
+		"app.example.com|alice": {Created: created},
+	}}
+	pl := &loginPage{auth: credentials.Credential{Username: "alice", Password: "secret"}}
+	pl.ctx = context.WithValue(context.Background(), exporters.ContextKeyTargetUrl, "https://app.example.com/login")
+	pl.session.store = store
+	pl.session.maxAge = time.Hour
+
+	return pl, store
+}
+
+func TestLoadSession(t *testing.T) {
+
+	pl, _ := newSessionLoginPage(time.Now().Add(-time.Minute))
+	if _, ok := pl.loadSession(); !ok {
+		t.Error("the stored session is not loaded")
+	}
+	pl, _ = newSessionLoginPage(time.Now().Add(-2 * time.Hour))
+	if _, ok := pl.loadSession(); ok {
+		t.Error("a session older than the max age is loaded")
+	}
+	pl, _ = newSessionLoginPage(time.Now())
+	pl.auth.Username = "bob"
+	if _, ok := pl.loadSession(); ok {
+		t.Error("the session of another user is loaded")
+	}
+}
+
+func TestDashboardDeletesInvalidSession(t *testing.T) {
+
+	defer func(f func(ctx context.Context) error) { dashboardLoaded = f }(dashboardLoaded)
+	tests := []struct {
+		name      string
+		restored  bool
+		dashboard error
+		wantErr   bool
+		deleted   int
+	}{
+		{name: "restored session reaches the dashboard", restored: true},
+		{name: "restored session is no longer valid", restored: true, dashboard: errors.New("timeout"), wantErr: true, deleted: 1},
+		{name: "login does not reach the dashboard", dashboard: errors.New("timeout"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pl, store := newSessionLoginPage(time.Now())
+			pl.session.restored = tt.restored
+			dashboardLoaded = func(ctx context.Context) error { return tt.dashboard }
+			err := pl.iShouldBeRedirectedToTheDashboardPage()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("iShouldBeRedirectedToTheDashboardPage = %v, want an error %v", err, tt.wantErr)
+			}
+			if store.deleted != tt.deleted {
+				t.Errorf("%d sessions deleted, want %d", store.deleted, tt.deleted)
+			}
+			// A restored session is not saved again
+			if tt.restored && store.saved != 0 {
+				t.Errorf("the restored session is saved again")
+			}
+		})
+	}
+}
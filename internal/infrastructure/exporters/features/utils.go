@@ -4,25 +4,81 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
-	"path"
+	"regexp"
 	"strings"
 	"time"
 
+	"fry.org/cmo/cli/internal/application/artifacts"
+	"fry.org/cmo/cli/internal/infrastructure/exporters"
+	"github.com/chromedp/cdproto/page"
 	"github.com/chromedp/chromedp"
+	"github.com/iancoleman/strcase"
 	"github.com/speijnik/go-errortree"
 )
 
-func takeSnapshot(ctx context.Context, folder string, stepName string) error {
+var snapshotNameRe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// captureScreenshot captures the viewport, the whole page or a single element as configured
+func captureScreenshot(cfg exporters.ScreenshotsConfig, res *[]byte) chromedp.Action {
+
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+
+		capture := page.CaptureScreenshot().WithFromSurface(true)
+		if cfg.Format == exporters.ScreenshotJPEG {
+			capture = capture.WithFormat(page.CaptureScreenshotFormatJpeg).WithQuality(int64(cfg.Quality))
+		} else {
+			capture = capture.WithFormat(page.CaptureScreenshotFormatPng)
+		}
+		switch cfg.Scope {
+		case exporters.ScreenshotFullPage:
+			capture = capture.WithCaptureBeyondViewport(true)
+		case exporters.ScreenshotElement:
+			var clip page.Viewport
+			if err = chromedp.Evaluate(fmt.Sprintf(`(() => {
+				const e = document.querySelector(%q);
+				if (e === null) {
+					return null;
+				}
+				const r = e.getBoundingClientRect();
+				return {x: r.left + window.scrollX, y: r.top + window.scrollY, width: r.width, height: r.height};
+			})()`, cfg.Selector), &clip).Do(ctx); err != nil {
+				return err
+			}
+			if clip.Width == 0 || clip.Height == 0 {
+				return fmt.Errorf("element %q not found or not visible", cfg.Selector)
+			}
+			clip.Scale = 1
+			capture = capture.WithCaptureBeyondViewport(true).WithClip(&clip)
+		}
+		*res, err = capture.Do(ctx)
+
+		return err
+	})
+}
+
+// takeSnapshot saves a screenshot of the browser as an artifact of the step
+func takeSnapshot(ctx context.Context, store artifacts.ArtifactStore, cfg exporters.ScreenshotsConfig, stepName string, name string) error {
 	var rcerror, err error
 	var buf []byte
 
-	if err = chromedp.Run(ctx, chromedp.CaptureScreenshot(&buf)); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+	if err = chromedp.Run(ctx, captureScreenshot(cfg, &buf)); err != nil && !errors.Is(err, context.DeadlineExceeded) {
 		return errortree.Add(rcerror, "failed to take snapshot", err)
 	}
-	// save screenshot to file
-	fileName := fmt.Sprintf("%s-%s.png", time.Now().Format("20060102150405"), stepName)
-	if err = os.WriteFile(path.Join(folder, fileName), buf, 0644); err != nil {
+	if len(buf) == 0 {
+		return errortree.Add(rcerror, "failed to take snapshot", errors.New("empty snapshot"))
+	}
+	// save screenshot to the artifact store
+	a := exporters.Artifact{
+		Name:        fmt.Sprintf("%s-%s.png", time.Now().Format("20060102150405"), snapshotNameRe.ReplaceAllString(name, "_")),
+		Step:        strcase.ToCamel(stepName),
+		ContentType: "image/png",
+	}
+	if cfg.Format == exporters.ScreenshotJPEG {
+		a.Name = strings.TrimSuffix(a.Name, ".png") + ".jpg"
+		a.ContentType = "image/jpeg"
+	}
+	if err = exporters.SaveArtifact(ctx, store, a, buf); err != nil {
 		return errortree.Add(rcerror, "failed to save snapshot", err)
 	}
 
@@ -41,27 +97,3 @@ func getSeasonFrom(season string) string {
 
 	return seasonNumber
 }
-
-// FIXME: this wait would last forever
-func waitUntilLoads(ctx context.Context, elementQuery string) error {
-	var rcerror error
-	for {
-		// Reload the current page
-		err := chromedp.Run(ctx, chromedp.Reload())
-		if err != nil {
-			return errortree.Add(rcerror, "reloadPage", err)
-		}
-
-		// Wait for the HTML object to become visible
-		err = chromedp.Run(ctx, chromedp.WaitVisible(elementQuery, chromedp.ByQuery))
-		if err == nil {
-			// Object found, break out of loop
-			break
-		} else {
-			// Object not found, continue looping
-			continue
-		}
-	}
-	return nil
-
-}
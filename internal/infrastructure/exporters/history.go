@@ -1,6 +1,7 @@
 package exporters
 
 import (
+	"context"
 	"embed"
 	"errors"
 	"fmt"
@@ -9,6 +10,8 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/antifuchs/o"
 	"github.com/robert-nix/ansihtml"
@@ -19,25 +22,128 @@ import (
 var htmlFS embed.FS
 
 type historyBuffer struct {
-	ring o.Ring
-	data []CucumberStatsSet
+	mutex  sync.RWMutex
+	ring   o.Ring
+	data   []*ProbeRun
+	maxAge time.Duration
 }
 
-func (c *cucumberHandler) newHistoryBuffer(size uint) error {
+type historyPage struct {
+	Live []*ProbeRun
+	Runs []*ProbeRun
+}
+
+func (c *cucumberHandler) newHistoryBuffer(size uint, maxAge time.Duration) error {
+	var rcerror error
 
+	if size == 0 {
+		return errortree.Add(rcerror, "newHistoryBuffer", errors.New("history size must be greater than zero"))
+	}
 	c.history = historyBuffer{
-		ring: o.NewRing(size),
-		data: make([]CucumberStatsSet, size),
+		ring:   o.NewRing(size),
+		data:   make([]*ProbeRun, size),
+		maxAge: maxAge,
 	}
 
 	return nil
 }
 
-func (c *cucumberHandler) addHistory(s CucumberStatsSet) error {
+func (c *cucumberHandler) addHistory(r *ProbeRun) error {
+	var evicted []*ProbeRun
 
-	c.history.data[c.history.ring.ForcePush()] = s
+	c.history.mutex.Lock()
+	i := c.history.ring.ForcePush()
+	if old := c.history.data[i]; old != nil {
+		evicted = append(evicted, old)
+	}
+	c.history.data[i] = r
+	c.history.mutex.Unlock()
 
-	return nil
+	return c.dropHistory(append(evicted, c.expireHistory()...))
+}
+
+// expireHistory removes the runs older than the retention max age
+func (c *cucumberHandler) expireHistory() []*ProbeRun {
+	var expired []*ProbeRun
+
+	if c.history.maxAge <= 0 {
+		return expired
+	}
+
+	c.history.mutex.Lock()
+	defer c.history.mutex.Unlock()
+
+	deadline := time.Now().Add(-c.history.maxAge)
+	for !c.history.ring.Empty() {
+		s := o.ScanFIFO(c.history.ring)
+		s.Next()
+		i := s.Value()
+		if r := c.history.data[i]; r != nil && r.Start.After(deadline) {
+			break
+		}
+		c.history.ring.Shift()
+		if c.history.data[i] != nil {
+			expired = append(expired, c.history.data[i])
+		}
+		c.history.data[i] = nil
+	}
+
+	return expired
+}
+
+// dropHistory garbage-collects the artifacts of the runs evicted by the retention policy
+func (c *cucumberHandler) dropHistory(runs []*ProbeRun) error {
+	var rcerror error
+
+	if c.store == nil {
+		return nil
+	}
+	for _, r := range runs {
+		if err := r.removeArtifacts(context.Background(), c.store); err != nil {
+			rcerror = errortree.Add(rcerror, r.Id, err)
+		}
+	}
+
+	return rcerror
+}
+
+// historyRuns returns the stored runs, newest first
+func (c *cucumberHandler) historyRuns() []*ProbeRun {
+	var runs []*ProbeRun
+
+	c.history.mutex.RLock()
+	defer c.history.mutex.RUnlock()
+
+	for s := o.ScanLIFO(c.history.ring); s.Next(); {
+		if r := c.history.data[s.Value()]; r != nil {
+			runs = append(runs, r)
+		}
+	}
+
+	return runs
+}
+
+func (c *cucumberHandler) findHistory(id string) (*ProbeRun, bool) {
+
+	for _, r := range c.historyRuns() {
+		if r.Id == id {
+			return r, true
+		}
+	}
+
+	return nil, false
+}
+
+// findTrace returns the id of the stored or in-flight run of the trace
+func (c *cucumberHandler) findTrace(traceId string) (string, bool) {
+
+	for _, r := range append(c.liveRuns(), c.historyRuns()...) {
+		if r.TraceId != "" && strings.EqualFold(r.TraceId, traceId) {
+			return r.Id, true
+		}
+	}
+
+	return "", false
 }
 
 // const cucumberHistorySize 50
@@ -57,6 +163,16 @@ func (c *cucumberHandler) loadTemplates() error {
 	}
 	c.templates[pt.Name()] = pt
 
+	if pt, err = template.New("run.gohtml").Funcs(funcs).ParseFS(htmlFS, "html/run.gohtml", "html/css/layout_*.gocss"); err != nil {
+		return errortree.Add(rcerror, "loadTemplates", err)
+	}
+	c.templates[pt.Name()] = pt
+
+	if pt, err = template.New("live.gohtml").Funcs(funcs).ParseFS(htmlFS, "html/live.gohtml", "html/css/layout_*.gocss", "html/css/terminal_*.gocss"); err != nil {
+		return errortree.Add(rcerror, "loadTemplates", err)
+	}
+	c.templates[pt.Name()] = pt
+
 	if pt, err = template.New("terminal.gohtml").Funcs(funcs).ParseFS(htmlFS, "html/terminal.gohtml", "html/css/terminal_*.gocss"); err != nil {
 		return errortree.Add(rcerror, "loadTemplates", err)
 	}
@@ -65,7 +181,7 @@ func (c *cucumberHandler) loadTemplates() error {
 	return nil
 }
 
-func WithCucumberHistoryEndpoint(prefix string) ExporterOption {
+func WithCucumberHistoryEndpoint(prefix string, size uint, maxAge time.Duration) ExporterOption {
 
 	return ExportOptionFn(func(i interface{}) error {
 		var rcerror error
@@ -75,10 +191,15 @@ func WithCucumberHistoryEndpoint(prefix string) ExporterOption {
 		if c, ok = i.(*cucumberHandler); ok {
 			c.templates = make(map[string]*template.Template)
 			c.Handle(path.Join(prefix, "/history"), http.HandlerFunc(c.HistoryEndpoint))
+			c.Handle(path.Join(prefix, "/history/live"), http.HandlerFunc(c.LiveEndpoint))
+			c.Handle(path.Join(prefix, "/history/events"), http.HandlerFunc(c.EventsEndpoint))
+			c.Handle(path.Join(prefix, "/history/artifacts"), http.HandlerFunc(c.ArtifactsEndpoint))
 			if err := c.loadTemplates(); err != nil {
 				return errortree.Add(rcerror, "WithCucumberHistory", err)
 			}
-			c.newHistoryBuffer(25)
+			if err := c.newHistoryBuffer(size, maxAge); err != nil {
+				return errortree.Add(rcerror, "WithCucumberHistory", err)
+			}
 
 			return nil
 		}
@@ -93,41 +214,81 @@ func (c *cucumberHandler) HistoryEndpoint(w http.ResponseWriter, r *http.Request
 
 	params := r.URL.Query()
 	id := params.Get("id")
+	if id == "" {
+		// The exemplars of the metrics link to the runs by their labels
+		id = params.Get(ExemplarRunId)
+	}
+	if traceId := params.Get(ExemplarTraceId); id == "" && traceId != "" {
+		if id, ok = c.findTrace(traceId); !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(fmt.Sprintf("Trace %s not found", traceId)))
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("./history?id=%s", id), http.StatusFound)
+		return
+	}
 	if id == "" {
 		if t, ok = c.templates["layout.gohtml"]; !ok {
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte("Layout template not found"))
 			return
 		}
-		if err := t.Execute(w, c.history.data); err != nil {
+		c.dropHistory(c.expireHistory())
+		page := historyPage{
+			Live: c.liveRuns(),
+			Runs: c.historyRuns(),
+		}
+		if err := t.Execute(w, page); err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			w.Write([]byte(fmt.Sprintf("Template %s Error: '%s'", t.Name(), err.Error())))
 			return
 		}
-	} else {
-		if i, err := strconv.Atoi(id); err != nil {
+		return
+	}
+	run, found := c.findHistory(id)
+	if !found {
+		if _, live := c.liveRun(id); live {
+			http.Redirect(w, r, fmt.Sprintf("./history/live?id=%s", id), http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(fmt.Sprintf("Run %s not found", id)))
+		return
+	}
+	scenario := params.Get("scenario")
+	if scenario == "" {
+		if t, ok = c.templates["run.gohtml"]; !ok {
 			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte(fmt.Sprintf("Converting %s Error: '%s'", id, err.Error())))
+			w.Write([]byte("Run template not found"))
 			return
-		} else {
-			scenario := params.Get("scenario")
-			if scenario == "" {
-				w.WriteHeader(http.StatusInternalServerError)
-				w.Write([]byte("Missing scenario param"))
-				return
-			}
-			if t, ok = c.templates["terminal.gohtml"]; !ok {
-				w.WriteHeader(http.StatusInternalServerError)
-				w.Write([]byte("Terminal template not found"))
-				return
-			}
-			//Translate ansi to html
-			html := string(ansihtml.ConvertToHTMLWithClasses([]byte(c.history.data[i][scenario].Output), "term-", false))
-			if err := t.Execute(w, template.HTML(html)); err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
-				w.Write([]byte(fmt.Sprintf("Template %s Error: '%s'", t.Name(), err.Error())))
-				return
-			}
 		}
+		if err := t.Execute(w, run); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(fmt.Sprintf("Template %s Error: '%s'", t.Name(), err.Error())))
+			return
+		}
+		return
+	}
+	if t, ok = c.templates["terminal.gohtml"]; !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("Terminal template not found"))
+		return
+	}
+	set := run.Set
+	if attempt := params.Get("attempt"); attempt != "" {
+		n, err := strconv.Atoi(attempt)
+		if err != nil || n < 1 || n > len(run.Attempts) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(fmt.Sprintf("Attempt %s of run %s not found", attempt, id)))
+			return
+		}
+		set = run.Attempts[n-1].Set
+	}
+	//Translate ansi to html
+	html := string(ansihtml.ConvertToHTMLWithClasses([]byte(set[scenario].Output), "term-", false))
+	if err := t.Execute(w, template.HTML(html)); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(fmt.Sprintf("Template %s Error: '%s'", t.Name(), err.Error())))
+		return
 	}
 }
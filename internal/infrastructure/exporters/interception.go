@@ -0,0 +1,366 @@
+package exporters
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"fry.org/cmo/cli/internal/infrastructure/redact"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"github.com/speijnik/go-errortree"
+)
+
+var (
+	ContextKeyInterceptor = ContextKey("interceptor")
+)
+
+// MockRule answers the requests matching the URL pattern without reaching the network
+type MockRule struct {
+	// URL is a glob, * matches any sequence of characters
+	URL string `json:"url"`
+	// Method restricts the rule to a HTTP method, any method when empty
+	Method      string            `json:"method,omitempty"`
+	Status      int               `json:"status"`
+	ContentType string            `json:"content_type,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Body        string            `json:"body,omitempty"`
+}
+
+// InterceptionConfig sets the rules applied to the requests issued by the browser
+type InterceptionConfig struct {
+	// Block are URL globs of the requests that are aborted, e.g. analytics or chat widgets
+	Block []string `json:"block,omitempty"`
+	// Headers are added to every request, e.g. a synthetic traffic marker
+	Headers map[string]string `json:"headers,omitempty"`
+	Mocks   []MockRule        `json:"mocks,omitempty"`
+}
+
+func (m MockRule) validate() error {
+	var rcerror error
+
+	if m.URL == "" {
+		rcerror = errortree.Add(rcerror, "url", errors.New("empty URL pattern"))
+	}
+	if m.Status < 100 || m.Status > 599 {
+		rcerror = errortree.Add(rcerror, "status", fmt.Errorf("invalid HTTP status %d, expected 100 to 599", m.Status))
+	}
+
+	return rcerror
+}
+
+func (i InterceptionConfig) validate() error {
+	var rcerror error
+
+	for _, p := range i.Block {
+		if p == "" {
+			rcerror = errortree.Add(rcerror, "block", errors.New("empty URL pattern"))
+		}
+	}
+	for k := range i.Headers {
+		if k == "" {
+			rcerror = errortree.Add(rcerror, "headers", errors.New("empty header name"))
+		}
+	}
+	for n, m := range i.Mocks {
+		if err := m.validate(); err != nil {
+			rcerror = errortree.Add(rcerror, fmt.Sprintf("mocks[%d]", n), err)
+		}
+	}
+
+	return rcerror
+}
+
+// globRe converts a URL glob to a regular expression
+func globRe(glob string) *regexp.Regexp {
+
+	return regexp.MustCompile("^" + strings.ReplaceAll(regexp.QuoteMeta(glob), `\*`, ".*") + "$")
+}
+
+type blockRule struct {
+	re *regexp.Regexp
+}
+
+type mockRule struct {
+	re   *regexp.Regexp
+	rule MockRule
+}
+
+// interceptor applies the interception rules through the DevTools Fetch domain
+type interceptor struct {
+	mutex   sync.Mutex
+	ctx     context.Context
+	enabled bool
+	block   []blockRule
+	headers map[string]string
+	mocks   []mockRule
+	// trace are the headers propagating the trace of the run to the requests matching propagate
+	trace     map[string]string
+	propagate []*regexp.Regexp
+	// targets checks the documents the browser navigates to, all is set once every request is intercepted
+	targets *TargetsConfig
+	all     bool
+}
+
+func newInterceptor(cfg InterceptionConfig) *interceptor {
+
+	i := &interceptor{
+		headers: make(map[string]string),
+	}
+	for _, p := range cfg.Block {
+		i.block = append(i.block, blockRule{re: globRe(p)})
+	}
+	for k, v := range cfg.Headers {
+		i.headers[k] = v
+	}
+	for _, m := range cfg.Mocks {
+		i.mocks = append(i.mocks, mockRule{re: globRe(m.URL), rule: m})
+	}
+
+	return i
+}
+
+func (i *interceptor) empty() bool {
+
+	return len(i.block) == 0 && len(i.headers) == 0 && len(i.mocks) == 0 && (len(i.trace) == 0 || len(i.propagate) == 0)
+}
+
+// propagateTrace sends the trace headers with the requests matching the URL globs
+func (i *interceptor) propagateTrace(globs []string, headers map[string]string) {
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.trace = headers
+	for _, p := range globs {
+		i.propagate = append(i.propagate, globRe(p))
+	}
+}
+
+// restrictNavigations checks the documents the browser navigates to, the redirections included,
+// against the targets of the module. The navigations of a module without any list are not checked.
+func (i *interceptor) restrictNavigations(t TargetsConfig) {
+
+	if !t.restricted() && len(t.Schemes) == 0 {
+		return
+	}
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.targets = &t
+}
+
+// enable starts intercepting the documents when the navigations are restricted, and every request
+// once there is any rule. It is a no-op when nothing changed since the last call.
+func (i *interceptor) enable(ctx context.Context) error {
+
+	i.mutex.Lock()
+	all := !i.empty()
+	if (!all && i.targets == nil) || (i.enabled && (i.all || !all)) {
+		i.mutex.Unlock()
+		return nil
+	}
+	first := !i.enabled
+	i.enabled, i.all = true, all
+	if first {
+		i.ctx = ctx
+	}
+	i.mutex.Unlock()
+
+	if first {
+		chromedp.ListenTarget(ctx, i.listen)
+	}
+	pattern := &fetch.RequestPattern{URLPattern: "*"}
+	if !all {
+		pattern.ResourceType = network.ResourceTypeDocument
+	}
+
+	return chromedp.Run(ctx, fetch.Enable().WithPatterns([]*fetch.RequestPattern{pattern}))
+}
+
+// listen is the chromedp target listener, the paused requests are resolved and resumed in the
+// background since the navigations checks resolve the host names
+func (i *interceptor) listen(ev interface{}) {
+
+	if ev, ok := ev.(*fetch.EventRequestPaused); ok {
+		go func() {
+			action := i.resolve(ev)
+			c := chromedp.FromContext(i.ctx)
+			// The request fails anyway when the target is gone, the other errors leave it paused
+			if err := action.Do(cdp.WithExecutor(i.ctx, c.Target)); err != nil && i.ctx.Err() == nil {
+				PublishRunEvent(i.ctx, RunEvent{
+					Kind: RunEventOutput,
+					Line: fmt.Sprintf("failed to resume the intercepted request %s: %s", redact.String(ev.Request.URL), err.Error()),
+				})
+			}
+		}()
+	}
+}
+
+// checkNavigation fails the documents not allowed by the targets of the module
+func (i *interceptor) checkNavigation(ev *fetch.EventRequestPaused) chromedp.Action {
+
+	i.mutex.Lock()
+	targets, ctx := i.targets, i.ctx
+	i.mutex.Unlock()
+
+	if targets == nil || ev.ResourceType != network.ResourceTypeDocument {
+		return nil
+	}
+	if err := targets.allowNavigation(ctx, ev.Request.URL); err != nil {
+		PublishRunEvent(ctx, RunEvent{
+			Kind: RunEventOutput,
+			Line: fmt.Sprintf("navigation to %s blocked: %s", redact.String(ev.Request.URL), err.Error()),
+		})
+		return fetch.FailRequest(ev.RequestID, network.ErrorReasonBlockedByClient)
+	}
+
+	return nil
+}
+
+// resolve decides what happens to a paused request: blocked, mocked or continued with the extra headers
+func (i *interceptor) resolve(ev *fetch.EventRequestPaused) chromedp.Action {
+
+	if action := i.checkNavigation(ev); action != nil {
+		return action
+	}
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	u := ev.Request.URL + ev.Request.URLFragment
+	for _, b := range i.block {
+		if b.re.MatchString(u) {
+			return fetch.FailRequest(ev.RequestID, network.ErrorReasonBlockedByClient)
+		}
+	}
+	// The last rule added wins, so steps can override the module mocks
+	for n := len(i.mocks) - 1; n >= 0; n-- {
+		m := i.mocks[n]
+		if m.re.MatchString(u) && (m.rule.Method == "" || strings.EqualFold(m.rule.Method, ev.Request.Method)) {
+			return fulfill(ev.RequestID, m.rule)
+		}
+	}
+	extra := make(map[string]string)
+	for k, v := range i.headers {
+		extra[k] = v
+	}
+	for _, p := range i.propagate {
+		if p.MatchString(u) {
+			for k, v := range i.trace {
+				extra[k] = v
+			}
+			break
+		}
+	}
+	if len(extra) == 0 {
+		return fetch.ContinueRequest(ev.RequestID)
+	}
+	headers := make(map[string]string)
+	for k, v := range ev.Request.Headers {
+		if s, ok := v.(string); ok {
+			headers[k] = s
+		}
+	}
+	for k, v := range extra {
+		headers[k] = v
+	}
+
+	return fetch.ContinueRequest(ev.RequestID).WithHeaders(headerEntries(headers))
+}
+
+func fulfill(id fetch.RequestID, m MockRule) chromedp.Action {
+
+	headers := make(map[string]string)
+	for k, v := range m.Headers {
+		headers[k] = v
+	}
+	if m.ContentType != "" {
+		headers["Content-Type"] = m.ContentType
+	}
+
+	return fetch.FulfillRequest(id, int64(m.Status)).
+		WithResponseHeaders(headerEntries(headers)).
+		WithResponsePhrase(http.StatusText(m.Status)).
+		WithBody(base64.StdEncoding.EncodeToString([]byte(m.Body)))
+}
+
+func headerEntries(h map[string]string) []*fetch.HeaderEntry {
+
+	entries := make([]*fetch.HeaderEntry, 0, len(h))
+	for k, v := range h {
+		entries = append(entries, &fetch.HeaderEntry{Name: k, Value: v})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+
+	return entries
+}
+
+func interceptorFromContext(ctx context.Context) (*interceptor, error) {
+	var i *interceptor
+	var ok bool
+	var rcerror error
+
+	if i, ok = ctx.Value(ContextKeyInterceptor).(*interceptor); !ok {
+		return nil, errortree.Add(rcerror, "interceptorFromContext", fmt.Errorf("type mismatch with key %s", ContextKeyInterceptor))
+	}
+
+	return i, nil
+}
+
+// BlockRequests aborts the upcoming requests matching the URL glob
+func BlockRequests(ctx context.Context, glob string) error {
+	var rcerror error
+
+	i, err := interceptorFromContext(ctx)
+	if err != nil {
+		return errortree.Add(rcerror, "BlockRequests", err)
+	}
+	i.mutex.Lock()
+	i.block = append(i.block, blockRule{re: globRe(glob)})
+	i.mutex.Unlock()
+
+	return i.enable(ctx)
+}
+
+// InjectRequestHeader adds the header to the upcoming requests
+func InjectRequestHeader(ctx context.Context, name string, value string) error {
+	var rcerror error
+
+	i, err := interceptorFromContext(ctx)
+	if err != nil {
+		return errortree.Add(rcerror, "InjectRequestHeader", err)
+	}
+	i.mutex.Lock()
+	i.headers[name] = value
+	i.mutex.Unlock()
+
+	return i.enable(ctx)
+}
+
+// MockResponses answers the upcoming requests matching the rule
+func MockResponses(ctx context.Context, m MockRule) error {
+	var rcerror error
+
+	if err := m.validate(); err != nil {
+		return errortree.Add(rcerror, "MockResponses", err)
+	}
+	i, err := interceptorFromContext(ctx)
+	if err != nil {
+		return errortree.Add(rcerror, "MockResponses", err)
+	}
+	i.mutex.Lock()
+	i.mocks = append(i.mocks, mockRule{re: globRe(m.URL), rule: m})
+	i.mutex.Unlock()
+
+	return i.enable(ctx)
+}
@@ -0,0 +1,83 @@
+package exporters
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+)
+
+func TestInterceptionConfigValidate(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		cfg     InterceptionConfig
+		wantErr bool
+	}{
+		{name: "empty"},
+		{name: "mock", cfg: InterceptionConfig{Mocks: []MockRule{{URL: "*/api/*", Status: 503}}}},
+		{name: "informational", cfg: InterceptionConfig{Mocks: []MockRule{{URL: "*", Status: 100}}}},
+		{name: "last status", cfg: InterceptionConfig{Mocks: []MockRule{{URL: "*", Status: 599}}}},
+		{name: "missing status", cfg: InterceptionConfig{Mocks: []MockRule{{URL: "*"}}}, wantErr: true},
+		{name: "status too low", cfg: InterceptionConfig{Mocks: []MockRule{{URL: "*", Status: 99}}}, wantErr: true},
+		{name: "status too high", cfg: InterceptionConfig{Mocks: []MockRule{{URL: "*", Status: 600}}}, wantErr: true},
+		{name: "missing url", cfg: InterceptionConfig{Mocks: []MockRule{{Status: 200}}}, wantErr: true},
+		{name: "empty block", cfg: InterceptionConfig{Block: []string{""}}, wantErr: true},
+		{name: "empty header", cfg: InterceptionConfig{Headers: map[string]string{"": "1"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate = %v, want an error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMockResponsesStatus(t *testing.T) {
+
+	i := newInterceptor(InterceptionConfig{})
+	ctx := context.WithValue(context.Background(), ContextKeyInterceptor, i)
+	for _, status := range []int{0, 42, 600, 1000} {
+		if err := MockResponses(ctx, MockRule{URL: "*", Status: status}); err == nil {
+			t.Errorf("MockResponses accepted the status %d", status)
+		}
+	}
+	if len(i.mocks) != 0 {
+		t.Errorf("%d invalid mocks were added", len(i.mocks))
+	}
+}
+
+func TestInterceptorResolve(t *testing.T) {
+
+	i := newInterceptor(InterceptionConfig{
+		Block:   []string{"*://analytics.example.com/*"},
+		Headers: map[string]string{"X-Synthetic": "uxperi"},
+		Mocks:   []MockRule{{URL: "*/api/status", Status: 503, Body: "down"}},
+	})
+	paused := func(u string) *fetch.EventRequestPaused {
+		return &fetch.EventRequestPaused{
+			RequestID:    "1",
+			Request:      &network.Request{URL: u, Method: "GET", Headers: network.Headers{"Accept": "*/*"}},
+			ResourceType: network.ResourceTypeXHR,
+		}
+	}
+	if a, ok := i.resolve(paused("https://analytics.example.com/collect")).(*fetch.FailRequestParams); !ok || a.ErrorReason != network.ErrorReasonBlockedByClient {
+		t.Error("the blocked request is not failed")
+	}
+	if a, ok := i.resolve(paused("https://portal.example.com/api/status")).(*fetch.FulfillRequestParams); !ok || a.ResponseCode != 503 {
+		t.Error("the mocked request is not fulfilled with 503")
+	}
+	a, ok := i.resolve(paused("https://portal.example.com/")).(*fetch.ContinueRequestParams)
+	if !ok {
+		t.Fatal("the request is not continued")
+	}
+	headers := make(map[string]string)
+	for _, h := range a.Headers {
+		headers[h.Name] = h.Value
+	}
+	if headers["X-Synthetic"] != "uxperi" || headers["Accept"] != "*/*" {
+		t.Errorf("headers = %v, want the original ones and X-Synthetic", headers)
+	}
+}
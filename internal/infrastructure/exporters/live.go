@@ -0,0 +1,176 @@
+package exporters
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/robert-nix/ansihtml"
+)
+
+// liveHeartbeat is the interval between SSE comments that keep idle connections open
+const liveHeartbeat = 15 * time.Second
+
+func (c *cucumberHandler) startRun(r *ProbeRun) {
+
+	c.liveMutex.Lock()
+	defer c.liveMutex.Unlock()
+
+	c.live[r.Id] = r
+}
+
+// finishRun moves the completed run from the live ones to the history, then tells the subscribers it
+// finished, so the final run is found when they follow it
+func (c *cucumberHandler) finishRun(r *ProbeRun) {
+
+	if c.history.data != nil {
+		c.addHistory(r)
+	}
+	c.liveMutex.Lock()
+	delete(c.live, r.Id)
+	c.liveMutex.Unlock()
+	r.finish()
+}
+
+func (c *cucumberHandler) liveRun(id string) (*ProbeRun, bool) {
+
+	c.liveMutex.RLock()
+	defer c.liveMutex.RUnlock()
+	r, ok := c.live[id]
+
+	return r, ok
+}
+
+// liveRuns returns the in-flight runs, oldest first
+func (c *cucumberHandler) liveRuns() []*ProbeRun {
+
+	c.liveMutex.RLock()
+	defer c.liveMutex.RUnlock()
+
+	runs := make([]*ProbeRun, 0, len(c.live))
+	for _, r := range c.live {
+		runs = append(runs, r)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].Start.Before(runs[j].Start)
+	})
+
+	return runs
+}
+
+// LiveEndpoint renders the page that follows an in-flight run
+func (c *cucumberHandler) LiveEndpoint(w http.ResponseWriter, r *http.Request) {
+	var t *template.Template
+	var ok bool
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("Missing id param"))
+		return
+	}
+	run, live := c.liveRun(id)
+	if !live {
+		if _, found := c.findHistory(id); found {
+			http.Redirect(w, r, fmt.Sprintf("../history?id=%s", id), http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(fmt.Sprintf("Run %s not found", id)))
+		return
+	}
+	if t, ok = c.templates["live.gohtml"]; !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("Live template not found"))
+		return
+	}
+	if err := t.Execute(w, run); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(fmt.Sprintf("Template %s Error: '%s'", t.Name(), err.Error())))
+		return
+	}
+}
+
+// EventsEndpoint streams the events of an in-flight run as Server-Sent Events
+func (c *cucumberHandler) EventsEndpoint(w http.ResponseWriter, r *http.Request) {
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "missing id param", http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	run, live := c.liveRun(id)
+	if !live {
+		if _, found := c.findHistory(id); !found {
+			http.Error(w, fmt.Sprintf("unknown run %q", id), http.StatusNotFound)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	if !live {
+		// The run finished before we subscribed
+		writeRunEvent(w, RunEvent{Kind: RunEventFinished, Time: time.Now()})
+		flusher.Flush()
+		return
+	}
+
+	// The browser reconnects with the id of the last event received, when the stream is cut or the
+	// subscriber is dropped for being too slow, the events it already got are not sent again
+	from := 0
+	if last, err := strconv.Atoi(r.Header.Get("Last-Event-ID")); err == nil {
+		from = last + 1
+	}
+	backlog, events, cancel := run.Subscribe(from)
+	defer cancel()
+	for _, ev := range backlog {
+		writeRunEvent(w, ev)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(liveHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		case ev, open := <-events:
+			if !open {
+				return
+			}
+			writeRunEvent(w, ev)
+			flusher.Flush()
+			if ev.Kind == RunEventFinished {
+				return
+			}
+		}
+	}
+}
+
+func writeRunEvent(w http.ResponseWriter, ev RunEvent) {
+
+	if ev.Kind == RunEventOutput {
+		//Translate ansi to html
+		ev.Line = string(ansihtml.ConvertToHTMLWithClasses([]byte(ev.Line), "term-", false))
+	}
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.Seq, ev.Kind, b)
+}
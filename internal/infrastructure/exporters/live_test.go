@@ -0,0 +1,108 @@
+package exporters
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSubscribeFrom(t *testing.T) {
+
+	run := newProbeRun("login.feature", "", "https://app.example.com")
+	for i := 0; i < 3; i++ {
+		run.Publish(RunEvent{Kind: RunEventOutput, Line: fmt.Sprint(i)})
+	}
+	for _, tt := range []struct{ from, first, want int }{{-1, 0, 3}, {0, 0, 3}, {2, 2, 1}, {3, 0, 0}, {10, 0, 0}} {
+		backlog, _, cancel := run.Subscribe(tt.from)
+		cancel()
+		if len(backlog) != tt.want {
+			t.Errorf("Subscribe(%d) backlog has %d events, want %d", tt.from, len(backlog), tt.want)
+		}
+		if len(backlog) > 0 && backlog[0].Seq != tt.first {
+			t.Errorf("Subscribe(%d) backlog starts at %d, want %d", tt.from, backlog[0].Seq, tt.first)
+		}
+	}
+}
+
+func TestEventsLastEventID(t *testing.T) {
+
+	c := &cucumberHandler{live: make(map[string]*ProbeRun)}
+	run := newProbeRun("login.feature", "", "https://app.example.com")
+	c.startRun(run)
+	for i := 0; i < 3; i++ {
+		run.Publish(RunEvent{Kind: RunEventOutput, Line: fmt.Sprint(i)})
+	}
+	srv := httptest.NewServer(http.HandlerFunc(c.EventsEndpoint))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"?id="+run.Id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The browser got the events 0 and 1 before the stream was cut
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var ids []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		id, ok := strings.CutPrefix(scanner.Text(), "id: ")
+		if !ok {
+			continue
+		}
+		ids = append(ids, id)
+		if id == "2" {
+			run.finish()
+		}
+	}
+	if strings.Join(ids, ",") != "2,3" {
+		t.Errorf("received the events %v, want 2,3", ids)
+	}
+}
+
+func TestFinishRunHistory(t *testing.T) {
+
+	c := &cucumberHandler{live: make(map[string]*ProbeRun)}
+	if err := c.newHistoryBuffer(4, 0); err != nil {
+		t.Fatal(err)
+	}
+	run := newProbeRun("login.feature", "", "https://app.example.com")
+	c.startRun(run)
+	_, events, cancel := run.Subscribe(0)
+	defer cancel()
+
+	run.complete(nil, errors.New("timeout"))
+	go c.finishRun(run)
+	var finished bool
+	for ev := range events {
+		if ev.Kind != RunEventFinished {
+			continue
+		}
+		finished = true
+		// The link to the final run is followed as soon as the event is received
+		if _, found := c.findHistory(run.Id); !found {
+			t.Error("the finished run is not in the history")
+		}
+		if _, live := c.liveRun(run.Id); live {
+			t.Error("the finished run is still live")
+		}
+		if ev.Error != "timeout" {
+			t.Errorf("finished event error = %q, want timeout", ev.Error)
+		}
+	}
+	if !finished {
+		t.Error("the finished event was not published")
+	}
+}
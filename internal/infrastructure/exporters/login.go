@@ -0,0 +1,173 @@
+package exporters
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/speijnik/go-errortree"
+)
+
+const (
+	LoginProfileAzureAD  = "azure-ad"
+	LoginProfileOkta     = "okta"
+	LoginProfileKeycloak = "keycloak"
+	// LoginProfileForm fits the plain username and password forms
+	LoginProfileForm = "form"
+)
+
+// LoginSelectors locate the elements of the identity provider login pages. They are CSS selectors
+// or XPath expressions.
+type LoginSelectors struct {
+	Username string `json:"username,omitempty"`
+	// Next is the button that moves from the username to the password page, empty when both
+	// fields are in the same page
+	Next     string `json:"next,omitempty"`
+	Password string `json:"password,omitempty"`
+	Submit   string `json:"submit,omitempty"`
+	// OTP is the one-time code field of the MFA prompt and OTPSubmit its button
+	OTP       string `json:"otp,omitempty"`
+	OTPSubmit string `json:"otp_submit,omitempty"`
+	// Consent is the button of the page shown after signing in, e.g. "Stay signed in?" or the
+	// OAuth2 consent, empty when there is no such page
+	Consent string `json:"consent,omitempty"`
+}
+
+// loginProfiles are the selectors of the supported identity providers
+var loginProfiles = map[string]LoginSelectors{
+	LoginProfileAzureAD: {
+		Username:  `//input[@type='email']`,
+		Next:      `//input[@value='Next']`,
+		Password:  `//input[@type='password']`,
+		Submit:    `//input[@type='submit']`,
+		OTP:       `//input[@name='otc']`,
+		OTPSubmit: `//input[@type='submit']`,
+		Consent:   `//input[@type='submit']`,
+	},
+	LoginProfileOkta: {
+		Username:  `input[name='identifier']`,
+		Next:      `input[type='submit'][value='Next']`,
+		Password:  `input[name='credentials.passcode']`,
+		Submit:    `input[type='submit'][value='Verify']`,
+		OTP:       `input[name='credentials.passcode']`,
+		OTPSubmit: `input[type='submit'][value='Verify']`,
+	},
+	LoginProfileKeycloak: {
+		Username:  `#username`,
+		Password:  `#password`,
+		Submit:    `#kc-login`,
+		OTP:       `#otp`,
+		OTPSubmit: `#kc-login`,
+	},
+	LoginProfileForm: {
+		Username:  `input[type='email'], input[name='username'], input[name='login']`,
+		Password:  `input[type='password']`,
+		Submit:    `button[type='submit'], input[type='submit']`,
+		OTP:       `input[autocomplete='one-time-code'], input[name='otp'], input[name='code']`,
+		OTPSubmit: `button[type='submit'], input[type='submit']`,
+	},
+}
+
+// LookupLoginProfile returns the selectors of the identity provider with the given name
+func LookupLoginProfile(name string) (LoginSelectors, error) {
+	var rcerror error
+
+	if p, ok := loginProfiles[strings.ToLower(name)]; ok {
+		return p, nil
+	}
+	names := make([]string, 0, len(loginProfiles))
+	for n := range loginProfiles {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	return LoginSelectors{}, errortree.Add(rcerror, "LookupLoginProfile", fmt.Errorf("unknown login profile %q, expected one of %s", name, strings.Join(names, ", ")))
+}
+
+// DashboardConfig identifies the page the users land on once logged in
+type DashboardConfig struct {
+	// Selector is the CSS selector of the element that must be visible, unlike the login selectors
+	// it can not be an XPath expression
+	Selector string `json:"selector"`
+	// Text is expected in the element, any text is accepted when empty
+	Text string `json:"text,omitempty"`
+	// ReloadEveryMs reloads the page while the element is not visible, 0 never reloads. The
+	// wait is bounded by the wait settings of the module.
+	ReloadEveryMs float64 `json:"reload_every_ms,omitempty"`
+}
+
+// UnmarshalJSON replaces the default dashboard as a whole, so the default text is not expected
+// in the element of another application
+func (d *DashboardConfig) UnmarshalJSON(b []byte) error {
+	type plain DashboardConfig
+	var p plain
+
+	if err := json.Unmarshal(b, &p); err != nil {
+		return err
+	}
+	*d = DashboardConfig(p)
+
+	return nil
+}
+
+// LoginConfig sets how the features log in the target application
+type LoginConfig struct {
+	// Profile is the name of the identity provider
+	Profile string `json:"profile"`
+	// Credential is the name of the credential to log in with, the default one when empty
+	Credential string `json:"credential,omitempty"`
+	// Selectors override the ones of the profile
+	Selectors LoginSelectors  `json:"selectors"`
+	Dashboard DashboardConfig `json:"dashboard"`
+}
+
+// LoginSelectors returns the selectors of the profile with the overrides applied
+func (l LoginConfig) LoginSelectors() (LoginSelectors, error) {
+
+	s, err := LookupLoginProfile(l.Profile)
+	if err != nil {
+		return s, err
+	}
+	override := func(v *string, o string) {
+		if o != "" {
+			*v = o
+		}
+	}
+	override(&s.Username, l.Selectors.Username)
+	override(&s.Next, l.Selectors.Next)
+	override(&s.Password, l.Selectors.Password)
+	override(&s.Submit, l.Selectors.Submit)
+	override(&s.OTP, l.Selectors.OTP)
+	override(&s.OTPSubmit, l.Selectors.OTPSubmit)
+	override(&s.Consent, l.Selectors.Consent)
+
+	return s, nil
+}
+
+func (l LoginConfig) validate() error {
+	var rcerror error
+
+	if _, err := l.LoginSelectors(); err != nil {
+		rcerror = errortree.Add(rcerror, "profile", err)
+	}
+	if l.Dashboard.Selector == "" {
+		rcerror = errortree.Add(rcerror, "dashboard.selector", errors.New("missing selector"))
+	} else if xpathLike(l.Dashboard.Selector) {
+		rcerror = errortree.Add(rcerror, "dashboard.selector", fmt.Errorf("%q looks like an XPath expression, the dashboard is located with a CSS selector", l.Dashboard.Selector))
+	}
+	if l.Dashboard.ReloadEveryMs < 0 {
+		rcerror = errortree.Add(rcerror, "dashboard.reload_every_ms", fmt.Errorf("negative reload period %v", l.Dashboard.ReloadEveryMs))
+	}
+
+	return rcerror
+}
+
+// xpathLike tells the XPath expressions, e.g. //h3 or (//div)[1], apart from the CSS selectors
+func xpathLike(selector string) bool {
+
+	s := strings.TrimSpace(selector)
+
+	return strings.HasPrefix(s, "/") || strings.HasPrefix(s, "./") || strings.HasPrefix(s, "(")
+}
@@ -0,0 +1,29 @@
+package exporters
+
+import "testing"
+
+func TestLoginConfigDashboardSelector(t *testing.T) {
+
+	tests := []struct {
+		selector string
+		wantErr  bool
+	}{
+		{selector: "h3"},
+		{selector: "#main-content h1"},
+		{selector: "div[data-test='dashboard'] > h1"},
+		{selector: "", wantErr: true},
+		{selector: "//h3", wantErr: true},
+		{selector: " //div[@id='main']", wantErr: true},
+		{selector: "(//h1)[1]", wantErr: true},
+		{selector: "./h3", wantErr: true},
+	}
+	for _, tt := range tests {
+		l := LoginConfig{
+			Profile:   "keycloak",
+			Dashboard: DashboardConfig{Selector: tt.selector},
+		}
+		if err := l.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate of the dashboard selector %q = %v, want an error %v", tt.selector, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package exporters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/speijnik/go-errortree"
+)
+
+var (
+	ContextKeyModule = ContextKey("module")
+)
+
+// DefaultModuleName is the module used when a probe does not ask for one
+const DefaultModuleName = "default"
+
+type ScreenshotPolicy string
+
+const (
+	ScreenshotNever     ScreenshotPolicy = "never"
+	ScreenshotOnFailure ScreenshotPolicy = "on-failure"
+	ScreenshotEveryStep ScreenshotPolicy = "every-step"
+)
+
+type ScreenshotScope string
+
+const (
+	ScreenshotViewport ScreenshotScope = "viewport"
+	ScreenshotFullPage ScreenshotScope = "full-page"
+	ScreenshotElement  ScreenshotScope = "element"
+)
+
+type ScreenshotFormat string
+
+const (
+	ScreenshotPNG  ScreenshotFormat = "png"
+	ScreenshotJPEG ScreenshotFormat = "jpeg"
+)
+
+// ScreenshotsConfig sets when and how the browser snapshots are taken
+type ScreenshotsConfig struct {
+	Policy ScreenshotPolicy `json:"policy"`
+	Scope  ScreenshotScope  `json:"scope"`
+	// Selector is the CSS selector of the element captured by the element scope
+	Selector string           `json:"selector,omitempty"`
+	Format   ScreenshotFormat `json:"format"`
+	// Quality is the JPEG compression quality, in the [0..100] range
+	Quality int `json:"quality,omitempty"`
+}
+
+// Region is a rectangle of a screenshot, in CSS pixels
+type Region struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// VisualConfig tunes the comparison of screenshots against their baselines
+type VisualConfig struct {
+	// Threshold is the color distance, in the [0..1] range, above which two pixels are considered different
+	Threshold float64 `json:"threshold"`
+	// MaxDiffRatio is the ratio of different pixels tolerated before failing the comparison
+	MaxDiffRatio float64 `json:"max_diff_ratio"`
+	// Ignore are the regions excluded from the comparison
+	Ignore []Region `json:"ignore,omitempty"`
+}
+
+// Module groups the settings used to probe a target
+type Module struct {
+	Screenshots  ScreenshotsConfig  `json:"screenshots"`
+	Visual       VisualConfig       `json:"visual"`
+	Emulation    EmulationConfig    `json:"emulation"`
+	Interception InterceptionConfig `json:"interception"`
+	Login        LoginConfig        `json:"login"`
+	Targets      TargetsConfig      `json:"targets"`
+	Discovery    DiscoveryConfig    `json:"discovery"`
+	Tracing      TracingConfig      `json:"tracing"`
+	Retry        RetryConfig        `json:"retry"`
+	StepRetry    StepRetryConfig    `json:"step_retry"`
+	Wait         WaitConfig         `json:"wait"`
+}
+
+type modulesFile struct {
+	Modules map[string]Module `json:"modules"`
+}
+
+// DefaultModule returns the settings used when no module is configured
+func DefaultModule() Module {
+
+	return Module{
+		Screenshots: ScreenshotsConfig{
+			Policy:  ScreenshotOnFailure,
+			Scope:   ScreenshotViewport,
+			Format:  ScreenshotPNG,
+			Quality: 80,
+		},
+		Visual: VisualConfig{
+			Threshold:    0.1,
+			MaxDiffRatio: 0.01,
+		},
+		Emulation: EmulationConfig{
+			Network:       NetworkProfileNone,
+			CPUThrottling: 1,
+		},
+		Login: LoginConfig{
+			Profile: LoginProfileAzureAD,
+			Dashboard: DashboardConfig{
+				Selector:      "h3",
+				Text:          "CREATION PORTAL",
+				ReloadEveryMs: 3000,
+			},
+		},
+		Retry: RetryConfig{
+			Attempts:   1,
+			Multiplier: 1,
+		},
+		StepRetry: StepRetryConfig{
+			BackoffPolicy: DefaultBackoffPolicy(),
+		},
+		Wait: DefaultWaitConfig(),
+	}
+}
+
+// UnmarshalJSON fills the missing settings with the default ones
+func (m *Module) UnmarshalJSON(b []byte) error {
+	type plain Module
+
+	p := plain(DefaultModule())
+	if err := json.Unmarshal(b, &p); err != nil {
+		return err
+	}
+	*m = Module(p)
+
+	return nil
+}
+
+func (m Module) validate() error {
+	var rcerror error
+
+	switch m.Screenshots.Policy {
+	case ScreenshotNever, ScreenshotOnFailure, ScreenshotEveryStep:
+	default:
+		rcerror = errortree.Add(rcerror, "screenshots.policy", fmt.Errorf("unsupported policy %q", m.Screenshots.Policy))
+	}
+	switch m.Screenshots.Scope {
+	case ScreenshotViewport, ScreenshotFullPage:
+	case ScreenshotElement:
+		if m.Screenshots.Selector == "" {
+			rcerror = errortree.Add(rcerror, "screenshots.selector", errors.New("element scope requires a selector"))
+		}
+	default:
+		rcerror = errortree.Add(rcerror, "screenshots.scope", fmt.Errorf("unsupported scope %q", m.Screenshots.Scope))
+	}
+	switch m.Screenshots.Format {
+	case ScreenshotPNG, ScreenshotJPEG:
+	default:
+		rcerror = errortree.Add(rcerror, "screenshots.format", fmt.Errorf("unsupported format %q", m.Screenshots.Format))
+	}
+	if m.Screenshots.Quality < 0 || m.Screenshots.Quality > 100 {
+		rcerror = errortree.Add(rcerror, "screenshots.quality", fmt.Errorf("quality %d out of the [0..100] range", m.Screenshots.Quality))
+	}
+
+	if m.Visual.Threshold < 0 || m.Visual.Threshold > 1 {
+		rcerror = errortree.Add(rcerror, "visual.threshold", fmt.Errorf("threshold %v out of the [0..1] range", m.Visual.Threshold))
+	}
+	if m.Visual.MaxDiffRatio < 0 || m.Visual.MaxDiffRatio > 1 {
+		rcerror = errortree.Add(rcerror, "visual.max_diff_ratio", fmt.Errorf("ratio %v out of the [0..1] range", m.Visual.MaxDiffRatio))
+	}
+	if err := m.Emulation.validate(); err != nil {
+		rcerror = errortree.Add(rcerror, "emulation", err)
+	}
+	if err := m.Interception.validate(); err != nil {
+		rcerror = errortree.Add(rcerror, "interception", err)
+	}
+	if err := m.Login.validate(); err != nil {
+		rcerror = errortree.Add(rcerror, "login", err)
+	}
+	if err := m.Targets.validate(); err != nil {
+		rcerror = errortree.Add(rcerror, "targets", err)
+	}
+	if err := m.Discovery.validate(); err != nil {
+		rcerror = errortree.Add(rcerror, "discovery", err)
+	}
+	if err := m.Tracing.validate(); err != nil {
+		rcerror = errortree.Add(rcerror, "tracing", err)
+	}
+	if err := m.Retry.validate(); err != nil {
+		rcerror = errortree.Add(rcerror, "retry", err)
+	}
+	if err := m.StepRetry.validate(); err != nil {
+		rcerror = errortree.Add(rcerror, "step_retry", err)
+	}
+	if err := m.Wait.validate(); err != nil {
+		rcerror = errortree.Add(rcerror, "wait", err)
+	}
+
+	return rcerror
+}
+
+// LoadModules reads the modules from a JSON file like {"modules": {"<name>": {...}}}
+func LoadModules(file string) (map[string]Module, error) {
+	var rcerror, errs error
+	var f modulesFile
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errortree.Add(rcerror, "LoadModules", err)
+	}
+	if err = json.Unmarshal(b, &f); err != nil {
+		return nil, errortree.Add(rcerror, "LoadModules", err)
+	}
+	for name, m := range f.Modules {
+		if err = m.validate(); err != nil {
+			errs = errortree.Add(errs, name, err)
+		}
+	}
+	if errs != nil {
+		return nil, errortree.Add(rcerror, "LoadModules", errs)
+	}
+
+	return f.Modules, nil
+}
+
+func WithCucumberModules(modules map[string]Module) ExporterOption {
+
+	return ExportOptionFn(func(i interface{}) error {
+		var rcerror error
+		var c *cucumberHandler
+		var ok bool
+
+		if c, ok = i.(*cucumberHandler); ok {
+			for name, m := range modules {
+				c.modules[name] = m
+			}
+			return nil
+		}
+
+		return errortree.Add(rcerror, "WithCucumberModules", errors.New("type mismatch, cucumberHandler expected"))
+	})
+}
+
+func (c *cucumberHandler) module(name string) (Module, bool) {
+
+	if name == "" {
+		name = DefaultModuleName
+	}
+	if m, ok := c.modules[name]; ok {
+		return m, true
+	}
+	if name == DefaultModuleName {
+		return DefaultModule(), true
+	}
+
+	return Module{}, false
+}
+
+// ModuleFromContext returns the module stored in the context or the default one
+func ModuleFromContext(ctx context.Context) Module {
+
+	if m, ok := ctx.Value(ContextKeyModule).(Module); ok {
+		return m
+	}
+
+	return DefaultModule()
+}
@@ -0,0 +1,437 @@
+package exporters
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"fry.org/cmo/cli/internal/infrastructure/redact"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/har"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+)
+
+const harArtifactName = "network.har"
+
+// NetworkStats summarizes the network activity of a scenario
+type NetworkStats struct {
+	Requests int
+	Failed   int
+	Blocked  int
+	Bytes    int64
+}
+
+// networkEntry is a request observed through the DevTools Network domain
+type networkEntry struct {
+	scenario  string
+	request   *network.Request
+	response  *network.Response
+	wallTime  time.Time
+	started   time.Time
+	finished  time.Time
+	bytes     int64
+	errorText string
+	// blocked is set for the requests aborted by the interception rules
+	blocked bool
+	// frame and loader tell the document that issued the request
+	frame  cdp.FrameID
+	loader cdp.LoaderID
+	// stream is set for the event streams, they stay open as long as the page
+	stream bool
+	// stale is set once the document that issued the request is gone, e.g. navigated away or
+	// reset before a retry, so the request does not keep the network busy
+	stale bool
+}
+
+// busy tells whether the request in flight keeps the network busy, the requests in flight for
+// longer than long are deemed long polls
+func (e *networkEntry) busy(now time.Time, long time.Duration) bool {
+
+	return !e.stale && !e.stream && now.Sub(e.wallTime) < long
+}
+
+func (e *networkEntry) failed() bool {
+
+	return !e.blocked && (e.errorText != "" || (e.response != nil && e.response.Status >= 400))
+}
+
+// networkRecorder keeps track of the requests issued by the browser during a run
+type networkRecorder struct {
+	mutex   sync.Mutex
+	run     *ProbeRun
+	pending map[network.RequestID]*networkEntry
+	entries []*networkEntry
+	// active is the last time a request started or finished
+	active time.Time
+}
+
+func newNetworkRecorder(run *ProbeRun) *networkRecorder {
+
+	return &networkRecorder{
+		run:     run,
+		pending: make(map[network.RequestID]*networkEntry),
+		active:  time.Now(),
+	}
+}
+
+// listen is the chromedp target listener, it must not block
+func (n *networkRecorder) listen(ev interface{}) {
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	switch ev := ev.(type) {
+	case *network.EventRequestWillBeSent:
+		if e, ok := n.pending[ev.RequestID]; ok && ev.RedirectResponse != nil {
+			// Redirects reuse the request id, close the previous hop
+			e.response = ev.RedirectResponse
+			e.finished = monotonic(ev.Timestamp)
+			e.bytes = int64(ev.RedirectResponse.EncodedDataLength)
+			n.entries = append(n.entries, e)
+		}
+		e := &networkEntry{
+			scenario: n.run.CurrentScenario(),
+			request:  ev.Request,
+			started:  monotonic(ev.Timestamp),
+			frame:    ev.FrameID,
+			loader:   ev.LoaderID,
+			stream:   ev.Type == network.ResourceTypeEventSource,
+		}
+		if ev.WallTime != nil {
+			e.wallTime = ev.WallTime.Time()
+		} else {
+			e.wallTime = time.Now()
+		}
+		n.pending[ev.RequestID] = e
+		n.active = time.Now()
+	case *network.EventResponseReceived:
+		if e, ok := n.pending[ev.RequestID]; ok {
+			e.response = ev.Response
+		}
+	case *network.EventLoadingFinished:
+		if e, ok := n.pending[ev.RequestID]; ok {
+			e.finished = monotonic(ev.Timestamp)
+			e.bytes = int64(ev.EncodedDataLength)
+			n.entries = append(n.entries, e)
+			delete(n.pending, ev.RequestID)
+			if !e.stale {
+				n.active = time.Now()
+			}
+		}
+	case *network.EventLoadingFailed:
+		if e, ok := n.pending[ev.RequestID]; ok {
+			e.finished = monotonic(ev.Timestamp)
+			e.errorText = ev.ErrorText
+			e.blocked = ev.BlockedReason != "" || ev.ErrorText == "net::ERR_BLOCKED_BY_CLIENT"
+			n.entries = append(n.entries, e)
+			delete(n.pending, ev.RequestID)
+			if !e.stale {
+				n.active = time.Now()
+			}
+		}
+	case *page.EventFrameNavigated:
+		// The requests of the previous document of the frame are never finished when it is torn down
+		for _, e := range n.pending {
+			if e.frame == ev.Frame.ID && e.loader != ev.Frame.LoaderID {
+				e.stale = true
+			}
+		}
+	case *page.EventFrameDetached:
+		for _, e := range n.pending {
+			if e.frame == ev.FrameID {
+				e.stale = true
+			}
+		}
+	}
+}
+
+// abandon marks the requests in flight as stale, e.g. before the browser is reset for a retry
+func (n *networkRecorder) abandon() {
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	for _, e := range n.pending {
+		e.stale = true
+	}
+}
+
+// activity returns the number of requests keeping the network busy, see busy, and the last time a
+// request started or finished
+func (n *networkRecorder) activity(long time.Duration) (int, time.Time) {
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	now := time.Now()
+	inflight := 0
+	for _, e := range n.pending {
+		if e.busy(now, long) {
+			inflight++
+		}
+	}
+
+	return inflight, n.active
+}
+
+// completed returns the finished requests plus the ones still in flight, ordered by start time
+func (n *networkRecorder) completed() []*networkEntry {
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	entries := make([]*networkEntry, 0, len(n.entries)+len(n.pending))
+	entries = append(entries, n.entries...)
+	for _, e := range n.pending {
+		entries = append(entries, e)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].wallTime.Before(entries[j].wallTime)
+	})
+
+	return entries
+}
+
+// stats aggregates the requests by scenario
+func (n *networkRecorder) stats() map[string]NetworkStats {
+
+	stats := make(map[string]NetworkStats)
+	for _, e := range n.completed() {
+		s := stats[e.scenario]
+		s.Requests++
+		s.Bytes += e.bytes
+		if e.failed() {
+			s.Failed++
+		}
+		if e.blocked {
+			s.Blocked++
+		}
+		stats[e.scenario] = s
+	}
+
+	return stats
+}
+
+// har renders the requests as an HTTP Archive. The archive is served by the history UI, so the
+// tokens are masked in the URLs and the headers; the cookies and the bodies are not recorded.
+func (n *networkRecorder) har() *har.HAR {
+
+	log := &har.Log{
+		Version: "1.2",
+		Creator: &har.Creator{
+			Name:    "uxperi",
+			Version: "1.0",
+		},
+		Entries: []*har.Entry{},
+	}
+	pages := make(map[string]bool)
+	for _, e := range n.completed() {
+		// Every scenario is a page of the archive
+		if e.scenario != "" && !pages[e.scenario] {
+			pages[e.scenario] = true
+			log.Pages = append(log.Pages, &har.Page{
+				StartedDateTime: e.wallTime.Format(time.RFC3339Nano),
+				ID:              e.scenario,
+				Title:           e.scenario,
+				PageTimings:     &har.PageTimings{},
+			})
+		}
+		log.Entries = append(log.Entries, e.har())
+	}
+
+	return &har.HAR{Log: log}
+}
+
+func (e *networkEntry) har() *har.Entry {
+
+	entry := &har.Entry{
+		Pageref:         e.scenario,
+		StartedDateTime: e.wallTime.Format(time.RFC3339Nano),
+		Request: &har.Request{
+			Method:      e.request.Method,
+			URL:         redact.String(e.request.URL),
+			HTTPVersion: "",
+			Cookies:     []*har.Cookie{},
+			Headers:     harHeaders(e.request.Headers),
+			QueryString: harQueryString(e.request.URL),
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		Response: &har.Response{
+			Cookies: []*har.Cookie{},
+			Headers: []*har.NameValuePair{},
+			Content: &har.Content{
+				Size: -1,
+			},
+			HeadersSize: -1,
+			BodySize:    e.bytes,
+		},
+		Cache: &har.Cache{},
+		Timings: &har.Timings{
+			Blocked: -1,
+			DNS:     -1,
+			Connect: -1,
+			Ssl:     -1,
+		},
+		Comment: redact.String(e.errorText),
+	}
+	if !e.finished.IsZero() {
+		entry.Time = float64(e.finished.Sub(e.started)) / float64(time.Millisecond)
+		entry.Timings.Receive = entry.Time
+	}
+	if r := e.response; r != nil {
+		entry.Request.HTTPVersion = r.Protocol
+		entry.Response.Status = r.Status
+		entry.Response.StatusText = r.StatusText
+		entry.Response.HTTPVersion = r.Protocol
+		entry.Response.Headers = harHeaders(r.Headers)
+		entry.Response.Content.MimeType = r.MimeType
+		entry.Response.RedirectURL = redact.String(header(r.Headers, "Location"))
+		entry.ServerIPAddress = r.RemoteIPAddress
+		if r.Timing != nil {
+			harTimings(entry, r.Timing, e)
+		}
+	}
+
+	return entry
+}
+
+// harTimings splits the elapsed time of the request in the HAR phases
+func harTimings(entry *har.Entry, t *network.ResourceTiming, e *networkEntry) {
+
+	phase := func(start float64, end float64) float64 {
+		if start < 0 || end < 0 {
+			return -1
+		}
+		return end - start
+	}
+	blocked := t.DNSStart
+	if blocked < 0 {
+		blocked = t.ConnectStart
+	}
+	if blocked < 0 {
+		blocked = t.SendStart
+	}
+	entry.Timings.Blocked = blocked
+	entry.Timings.DNS = phase(t.DNSStart, t.DNSEnd)
+	entry.Timings.Connect = phase(t.ConnectStart, t.ConnectEnd)
+	entry.Timings.Ssl = phase(t.SslStart, t.SslEnd)
+	entry.Timings.Send = phase(t.SendStart, t.SendEnd)
+	entry.Timings.Wait = phase(t.SendEnd, t.ReceiveHeadersEnd)
+	entry.Timings.Receive = 0
+	if !e.finished.IsZero() {
+		// RequestTime is expressed in seconds since the monotonic epoch, the offsets in milliseconds
+		end := float64(e.finished.Sub(*cdp.MonotonicTimeEpoch))/float64(time.Millisecond) - t.RequestTime*1000
+		if receive := end - t.ReceiveHeadersEnd; receive > 0 {
+			entry.Timings.Receive = receive
+		}
+		entry.Time = end
+	}
+}
+
+// sensitiveHeaders are not written to the archive because it is served by the history UI
+var sensitiveHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"set-cookie":          true,
+}
+
+// sensitiveHeader tells whether the header carries credentials, e.g. X-Api-Key or X-Auth-Token
+func sensitiveHeader(name string) bool {
+
+	name = strings.ToLower(name)
+	if sensitiveHeaders[name] {
+		return true
+	}
+	for _, s := range []string{"api-key", "apikey", "token", "secret", "password", "session"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func harHeaders(h network.Headers) []*har.NameValuePair {
+
+	pairs := []*har.NameValuePair{}
+	for k, v := range h {
+		value, _ := v.(string)
+		if sensitiveHeader(k) {
+			value = "[redacted]"
+		} else {
+			// e.g. the Location and Referer headers carry the tokens of the URLs
+			value = redact.String(value)
+		}
+		pairs = append(pairs, &har.NameValuePair{Name: k, Value: value})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Name < pairs[j].Name
+	})
+
+	return pairs
+}
+
+func harQueryString(raw string) []*har.NameValuePair {
+
+	pairs := []*har.NameValuePair{}
+	if u, err := url.Parse(raw); err == nil {
+		for k, values := range u.Query() {
+			for _, v := range values {
+				pairs = append(pairs, &har.NameValuePair{Name: k, Value: redact.Param(k, v)})
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Name < pairs[j].Name
+	})
+
+	return pairs
+}
+
+func header(h network.Headers, name string) string {
+
+	for k, v := range h {
+		if strings.EqualFold(k, name) {
+			s, _ := v.(string)
+			return s
+		}
+	}
+
+	return ""
+}
+
+func monotonic(t *cdp.MonotonicTime) time.Time {
+
+	if t == nil {
+		return time.Time{}
+	}
+
+	return t.Time()
+}
+
+// recordNetwork attaches the HAR of the run and its network summary to the run
+func (c *cucumberHandler) recordNetwork(run *ProbeRun, rec *networkRecorder) error {
+
+	run.setNetwork(rec.stats())
+	if c.store == nil {
+		return nil
+	}
+	content, err := json.Marshal(rec.har())
+	if err != nil {
+		return err
+	}
+	// The request context may be already done, the archive must be saved anyway
+	ctx := context.WithValue(context.Background(), ContextKeyRun, run)
+
+	return SaveArtifact(ctx, c.store, Artifact{
+		Name:        harArtifactName,
+		ContentType: "application/json",
+	}, content)
+}
@@ -0,0 +1,184 @@
+package exporters
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+)
+
+func TestNetworkEntryHARRedaction(t *testing.T) {
+
+	e := &networkEntry{
+		scenario: "Login",
+		request: &network.Request{
+			Method: "GET",
+			URL:    "https://app.example.com/callback?state=xyz&code=0.AXk-code",
+			Headers: network.Headers{
+				"Accept":              "text/html",
+				"Authorization":       "Basic dXNlcjpwdw==",
+				"Proxy-Authorization": "Basic cHJveHk6cHc=",
+				"X-Api-Key":           "key-123456",
+				"X-Auth-Token":        "token-123456",
+				"Referer":             "https://login.example.com/?state=xyz&code=0.AXk-code",
+			},
+		},
+		response: &network.Response{
+			Status: 302,
+			Headers: network.Headers{
+				"Location":   "https://app.example.com/#id_token=eyJh.eyJz.sig&access_token=at-123456",
+				"Set-Cookie": "session=cookie-123456",
+			},
+		},
+		wallTime: time.Now(),
+	}
+	entry := e.har()
+
+	if got := entry.Request.Headers; len(got) != 6 {
+		t.Fatalf("%d request headers, want 6", len(got))
+	}
+	for _, h := range entry.Request.Headers {
+		if h.Name == "Accept" && h.Value != "text/html" {
+			t.Errorf("Accept = %q, want it unchanged", h.Value)
+		}
+	}
+	for _, q := range entry.Request.QueryString {
+		if q.Name == "state" && q.Value != "xyz" {
+			t.Errorf("state = %q, want it unchanged", q.Value)
+		}
+	}
+	if !strings.HasPrefix(entry.Response.RedirectURL, "https://app.example.com/#id_token=") {
+		t.Errorf("redirect URL %q not kept", entry.Response.RedirectURL)
+	}
+	content, err := json.Marshal(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"0.AXk-code", "dXNlcjpwdw", "cHJveHk6cHc", "key-123456", "token-123456", "eyJh", "at-123456", "cookie-123456"} {
+		if strings.Contains(string(content), secret) {
+			t.Errorf("%s leaked in the archive", secret)
+		}
+	}
+}
+
+func TestSensitiveHeader(t *testing.T) {
+
+	tests := map[string]bool{
+		"Authorization":       true,
+		"proxy-authorization": true,
+		"Cookie":              true,
+		"X-Api-Key":           true,
+		"X-CSRF-Token":        true,
+		"X-Client-Secret":     true,
+		"Content-Type":        false,
+		"Location":            false,
+	}
+	for name, want := range tests {
+		if got := sensitiveHeader(name); got != want {
+			t.Errorf("sensitiveHeader(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestNetworkRecorderActivity(t *testing.T) {
+
+	n := newNetworkRecorder(newProbeRun("loginPage", "", "https://example.com"))
+	send := func(id network.RequestID, frame cdp.FrameID, loader cdp.LoaderID, typ network.ResourceType, at time.Time) {
+		wall := cdp.TimeSinceEpoch(at)
+		n.listen(&network.EventRequestWillBeSent{
+			RequestID: id,
+			Request:   &network.Request{URL: "https://example.com/" + string(id), Method: "GET"},
+			WallTime:  &wall,
+			FrameID:   frame,
+			LoaderID:  loader,
+			Type:      typ,
+		})
+	}
+	inflight := func() int {
+		count, _ := n.activity(5 * time.Second)
+		return count
+	}
+
+	now := time.Now()
+	send("doc", "main", "first", network.ResourceTypeDocument, now)
+	send("xhr", "main", "first", network.ResourceTypeXHR, now)
+	send("events", "main", "first", network.ResourceTypeEventSource, now)
+	send("poll", "main", "first", network.ResourceTypeXHR, now.Add(-time.Minute))
+	send("frame", "ad", "third", network.ResourceTypeScript, now)
+	if got := inflight(); got != 3 {
+		t.Errorf("%d requests in flight, want 3 without the event stream and the long poll", got)
+	}
+
+	n.listen(&network.EventLoadingFinished{RequestID: "doc"})
+	if got := inflight(); got != 2 {
+		t.Errorf("%d requests in flight once the document is loaded, want 2", got)
+	}
+	// The page navigates away before the XHR is answered
+	send("next", "main", "second", network.ResourceTypeDocument, now)
+	n.listen(&page.EventFrameNavigated{Frame: &cdp.Frame{ID: "main", LoaderID: "second"}})
+	if got := inflight(); got != 2 {
+		t.Errorf("%d requests in flight after the navigation, want the new document and the frame", got)
+	}
+	n.listen(&page.EventFrameDetached{FrameID: "ad"})
+	if got := inflight(); got != 1 {
+		t.Errorf("%d requests in flight after the frame is detached, want the new document", got)
+	}
+	// The stale requests finishing late do not restart the quiet time
+	_, before := n.activity(5 * time.Second)
+	n.listen(&network.EventLoadingFinished{RequestID: "xhr"})
+	if _, after := n.activity(5 * time.Second); !after.Equal(before) {
+		t.Error("a stale request restarted the quiet time")
+	}
+	n.abandon()
+	if got := inflight(); got != 0 {
+		t.Errorf("%d requests in flight after the reset of a retry, want 0", got)
+	}
+	// The abandoned requests are still archived
+	if got := len(n.completed()); got != 6 {
+		t.Errorf("%d requests recorded, want 6", got)
+	}
+}
+
+func TestNetworkIdle(t *testing.T) {
+
+	n := newNetworkRecorder(newProbeRun("loginPage", "", "https://example.com"))
+	ctx := context.WithValue(context.Background(), ContextKeyNetwork, n)
+	wall := cdp.TimeSinceEpoch(time.Now())
+	n.listen(&network.EventRequestWillBeSent{
+		RequestID: "xhr",
+		Request:   &network.Request{URL: "https://example.com/api", Method: "GET"},
+		WallTime:  &wall,
+		FrameID:   "main",
+		Type:      network.ResourceTypeXHR,
+	})
+	cond := NetworkIdle(20*time.Millisecond, time.Minute)
+	if err := cond.Check(ctx); err == nil || !strings.Contains(err.Error(), "1 requests in flight") {
+		t.Errorf("Check = %v, want 1 request in flight", err)
+	}
+	n.listen(&network.EventLoadingFinished{RequestID: "xhr"})
+	if err := cond.Check(ctx); err == nil {
+		t.Error("the network is idle before the quiet time")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if err := cond.Check(ctx); err != nil {
+		t.Errorf("Check = %v, want the network idle", err)
+	}
+	// A long poll does not keep the network busy for ever
+	wall = cdp.TimeSinceEpoch(time.Now().Add(-2 * time.Minute))
+	n.listen(&network.EventRequestWillBeSent{
+		RequestID: "poll",
+		Request:   &network.Request{URL: "https://example.com/poll", Method: "GET"},
+		WallTime:  &wall,
+		FrameID:   "main",
+		Type:      network.ResourceTypeXHR,
+	})
+	time.Sleep(30 * time.Millisecond)
+	if err := cond.Check(ctx); err != nil {
+		t.Errorf("Check = %v, want the long poll ignored", err)
+	}
+}
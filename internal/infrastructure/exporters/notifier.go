@@ -0,0 +1,229 @@
+package exporters
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"fry.org/cmo/cli/internal/infrastructure/notify"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/speijnik/go-errortree"
+)
+
+const (
+	// notifyTimeout bounds the delivery of a notification by a notifier
+	notifyTimeout = 30 * time.Second
+	// alertStateIdle forgets the failing scenarios not probed anymore, like the ones of a removed target
+	alertStateIdle = 24 * time.Hour
+	// maxAlertStates caps the failing scenarios tracked, the least recently probed are forgotten first
+	maxAlertStates = 10000
+)
+
+var notificationFailuresCounterVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "probe_notification_failures_total",
+	Help: "Notifications of the scenario transitions that could not be delivered",
+}, []string{"feature", "state"})
+
+func init() {
+	prometheus.MustRegister(notificationFailuresCounterVec)
+}
+
+// alerting tracks the consecutive failures of every failing scenario probed against a target, so the
+// notifiers are only called on the transitions between passing and failing. A passing scenario has no state.
+type alerting struct {
+	mutex       sync.Mutex
+	notifiers   []notify.Notifier
+	threshold   int
+	externalURL string
+	states      map[string]*alertState
+}
+
+type alertState struct {
+	failures int
+	notified bool
+	// seen is the end of the last run of the scenario
+	seen time.Time
+}
+
+// scenarioOutcome is the result of a scenario in a run
+type scenarioOutcome struct {
+	failed bool
+	step   string
+	err    string
+}
+
+// WithCucumberNotifiers notifies when a scenario fails threshold runs in a row against a target, and
+// when it passes again. The external URL of the metrics server, if any, is used for the links to the history.
+func WithCucumberNotifiers(threshold int, externalURL string, notifiers ...notify.Notifier) ExporterOption {
+
+	return ExportOptionFn(func(i interface{}) error {
+		var rcerror error
+		var c *cucumberHandler
+		var ok bool
+
+		if c, ok = i.(*cucumberHandler); ok {
+			if threshold < 1 {
+				return errortree.Add(rcerror, "WithCucumberNotifiers", errors.New("the threshold must be at least one failure"))
+			}
+			if externalURL != "" {
+				if u, err := url.Parse(externalURL); err != nil || u.Scheme == "" || u.Host == "" {
+					return errortree.Add(rcerror, "WithCucumberNotifiers", errors.New("the external url is not absolute"))
+				}
+			}
+			c.alerting = &alerting{
+				notifiers:   notifiers,
+				threshold:   threshold,
+				externalURL: strings.TrimSuffix(externalURL, "/"),
+				states:      make(map[string]*alertState),
+			}
+			return nil
+		}
+
+		return errortree.Add(rcerror, "WithCucumberNotifiers", errors.New("type mismatch, cucumberHandler expected"))
+	})
+}
+
+// outcomes returns the result of every scenario of the finished run
+func outcomes(run *ProbeRun) map[string]scenarioOutcome {
+
+	all := make(map[string]scenarioOutcome)
+	for name, item := range run.Set {
+		o := scenarioOutcome{
+			failed: scenarioFailed(item, run.Error),
+			err:    run.Error,
+		}
+		for _, st := range item.Stats {
+			if st.Result == CucumberFailure {
+				o.failed = true
+				o.step = st.Id
+				// The error of the step first, then the error tree of the run
+				if st.Error != "" && st.Error != run.Error {
+					o.err = strings.TrimSpace(st.Error + "\n" + run.Error)
+				}
+				break
+			}
+		}
+		all[name] = o
+	}
+
+	return all
+}
+
+// notify updates the state of the scenarios of the run and sends the transitions in the background
+func (c *cucumberHandler) notify(run *ProbeRun, results map[string]scenarioOutcome) {
+
+	if c.alerting == nil {
+		return
+	}
+	var pending []notify.Notification
+	now := time.Now()
+	a := c.alerting
+	a.mutex.Lock()
+	for scenario, o := range results {
+		key := strings.Join([]string{run.Feature, run.Module, run.Target, scenario}, "\x00")
+		st, ok := a.states[key]
+		if !ok {
+			if !o.failed {
+				continue
+			}
+			st = &alertState{}
+			a.states[key] = st
+		}
+		st.seen = now
+		n := notify.Notification{
+			Time:     now,
+			Feature:  run.Feature,
+			Module:   run.Module,
+			Target:   run.Target,
+			Scenario: scenario,
+			RunId:    run.Id,
+			RunURL:   a.link("history", url.Values{"id": {run.Id}}),
+		}
+		if !o.failed {
+			if st.notified {
+				n.State = notify.StateRecovered
+				pending = append(pending, n)
+			}
+			delete(a.states, key)
+			continue
+		}
+		st.failures++
+		if st.notified || st.failures < a.threshold {
+			continue
+		}
+		st.notified = true
+		n.State = notify.StateFailing
+		n.Failures = st.failures
+		n.Step = o.step
+		n.Error = o.err
+		if s, ok := run.lastSnapshot(scenario); ok {
+			n.SnapshotURL = a.link("history/artifacts", url.Values{"id": {run.Id}, "name": {s.Name}})
+		}
+		pending = append(pending, n)
+	}
+	a.evict(now)
+	a.mutex.Unlock()
+
+	for _, n := range pending {
+		for _, notifier := range a.notifiers {
+			go func(notifier notify.Notifier, n notify.Notification) {
+				ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+				defer cancel()
+				if err := notifier.Notify(ctx, n); err != nil {
+					notificationFailuresCounterVec.WithLabelValues(n.Feature, string(n.State)).Inc()
+				}
+			}(notifier, n)
+		}
+	}
+}
+
+// evict forgets the states idle for too long, then the least recently seen ones above the cap.
+// The caller holds the mutex.
+func (a *alerting) evict(now time.Time) {
+
+	for key, st := range a.states {
+		if now.Sub(st.seen) > alertStateIdle {
+			delete(a.states, key)
+		}
+	}
+	if len(a.states) <= maxAlertStates {
+		return
+	}
+	keys := make([]string, 0, len(a.states))
+	for key := range a.states {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return a.states[keys[i]].seen.Before(a.states[keys[j]].seen) })
+	for _, key := range keys[:len(keys)-maxAlertStates] {
+		delete(a.states, key)
+	}
+}
+
+// link returns the URL of the history page, empty when the external URL is not known
+func (a *alerting) link(page string, q url.Values) string {
+
+	if a.externalURL == "" {
+		return ""
+	}
+
+	return a.externalURL + "/" + page + "?" + q.Encode()
+}
+
+// lastSnapshot returns the last image attached to the scenario
+func (r *ProbeRun) lastSnapshot(scenario string) (Artifact, bool) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i := len(r.Artifacts) - 1; i >= 0; i-- {
+		if a := r.Artifacts[i]; a.Scenario == scenario && a.IsImage() {
+			return a, true
+		}
+	}
+
+	return Artifact{}, false
+}
@@ -0,0 +1,172 @@
+package exporters
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"fry.org/cmo/cli/internal/infrastructure/notify"
+)
+
+// recordingNotifier forwards the notifications sent in the background to the test
+type recordingNotifier chan notify.Notification
+
+func (r recordingNotifier) Notify(_ context.Context, n notify.Notification) error {
+
+	r <- n
+	return nil
+}
+
+// received waits for the notifications of a notify call, then checks nothing else is sent
+func (r recordingNotifier) received(t *testing.T, want int) []notify.Notification {
+
+	t.Helper()
+	var got []notify.Notification
+	for len(got) < want {
+		select {
+		case n := <-r:
+			got = append(got, n)
+		case <-time.After(time.Second):
+			t.Fatalf("received %d notifications, want %d", len(got), want)
+		}
+	}
+	select {
+	case n := <-r:
+		t.Fatalf("unexpected notification %+v", n)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	return got
+}
+
+func newAlertingHandler(t *testing.T, threshold int, r recordingNotifier) *cucumberHandler {
+
+	t.Helper()
+	c := &cucumberHandler{}
+	if err := WithCucumberNotifiers(threshold, "http://uxperi:9090/", r).Apply(c); err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func TestNotifyTransitions(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		threshold int
+		// results are the outcomes of the successive runs, true for a failure
+		results []bool
+		// want are the states notified after each run, empty for none
+		want []notify.State
+	}{
+		{
+			name:      "passing",
+			threshold: 1,
+			results:   []bool{false, false},
+			want:      []notify.State{"", ""},
+		},
+		{
+			name:      "failing at the first failure",
+			threshold: 1,
+			results:   []bool{true, true, false, false},
+			want:      []notify.State{notify.StateFailing, "", notify.StateRecovered, ""},
+		},
+		{
+			name:      "failing at the threshold",
+			threshold: 3,
+			results:   []bool{true, true, true, true, false},
+			want:      []notify.State{"", "", notify.StateFailing, "", notify.StateRecovered},
+		},
+		{
+			name:      "recovered below the threshold",
+			threshold: 3,
+			results:   []bool{true, true, false, true, true},
+			want:      []notify.State{"", "", "", "", ""},
+		},
+		{
+			name:      "failing again after a recovery",
+			threshold: 2,
+			results:   []bool{true, true, false, true, true},
+			want:      []notify.State{"", notify.StateFailing, notify.StateRecovered, "", notify.StateFailing},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := make(recordingNotifier, 4)
+			c := newAlertingHandler(t, tt.threshold, r)
+			for i, failed := range tt.results {
+				run := newProbeRun("login.feature", "", "https://app.example.com")
+				o := scenarioOutcome{failed: failed}
+				if failed {
+					o.step, o.err = "IShouldBeRedirectedToTheDashboardPage", "timeout"
+				}
+				c.notify(run, map[string]scenarioOutcome{"Login": o})
+				if tt.want[i] == "" {
+					r.received(t, 0)
+					continue
+				}
+				got := r.received(t, 1)[0]
+				if got.State != tt.want[i] {
+					t.Fatalf("run %d notified %s, want %s", i, got.State, tt.want[i])
+				}
+				if got.Feature != "login.feature" || got.Module != DefaultModuleName || got.Scenario != "Login" || got.RunId != run.Id {
+					t.Errorf("run %d notified %+v", i, got)
+				}
+				if got.RunURL != "http://uxperi:9090/history?id="+run.Id {
+					t.Errorf("run %d notified the run url %s", i, got.RunURL)
+				}
+				if got.State == notify.StateFailing && (got.Failures != tt.threshold || got.Step == "" || got.Error != "timeout") {
+					t.Errorf("run %d notified the failure %+v", i, got)
+				}
+			}
+		})
+	}
+}
+
+func TestNotifyStates(t *testing.T) {
+
+	r := make(recordingNotifier, 4)
+	c := newAlertingHandler(t, 2, r)
+	failing := map[string]scenarioOutcome{"Login": {failed: true}}
+	passing := map[string]scenarioOutcome{"Login": {}}
+
+	// Only the failing scenarios are tracked, the targets do not share their states
+	c.notify(newProbeRun("login.feature", "", "https://a.example.com"), passing)
+	c.notify(newProbeRun("login.feature", "", "https://b.example.com"), failing)
+	c.notify(newProbeRun("login.feature", "", "https://c.example.com"), failing)
+	if len(c.alerting.states) != 2 {
+		t.Fatalf("%d states, want 2", len(c.alerting.states))
+	}
+	c.notify(newProbeRun("login.feature", "", "https://b.example.com"), passing)
+	if len(c.alerting.states) != 1 {
+		t.Errorf("%d states after a pass, want 1", len(c.alerting.states))
+	}
+
+	// The scenarios not probed anymore are forgotten
+	for _, st := range c.alerting.states {
+		st.seen = time.Now().Add(-alertStateIdle - time.Minute)
+	}
+	c.notify(newProbeRun("login.feature", "", "https://d.example.com"), failing)
+	if len(c.alerting.states) != 1 {
+		t.Errorf("%d states after the idle ones are evicted, want 1", len(c.alerting.states))
+	}
+
+	// Above the cap, the least recently seen are forgotten first
+	for i := 0; i < maxAlertStates; i++ {
+		c.alerting.states[fmt.Sprint(i)] = &alertState{failures: 1, seen: time.Now().Add(-time.Hour)}
+	}
+	c.notify(newProbeRun("login.feature", "", "https://e.example.com"), failing)
+	if len(c.alerting.states) != maxAlertStates {
+		t.Errorf("%d states, want %d", len(c.alerting.states), maxAlertStates)
+	}
+	for _, target := range []string{"d", "e"} {
+		key := strings.Join([]string{"login.feature", DefaultModuleName, "https://" + target + ".example.com", "Login"}, "\x00")
+		if _, ok := c.alerting.states[key]; !ok {
+			t.Errorf("the state of the target %s was evicted", target)
+		}
+	}
+	r.received(t, 0)
+}
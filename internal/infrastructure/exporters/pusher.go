@@ -0,0 +1,49 @@
+package exporters
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"fry.org/cmo/cli/internal/infrastructure/push"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/speijnik/go-errortree"
+)
+
+// pushTimeout bounds the push of the metrics of a run, done once the probe answered
+const pushTimeout = 30 * time.Second
+
+var pushFailuresCounterVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "probe_push_failures_total",
+	Help: "Pushes of the metrics of the probe runs that failed",
+}, []string{"feature"})
+
+func init() {
+	prometheus.MustRegister(pushFailuresCounterVec)
+}
+
+// WithCucumberPusher pushes the metrics of every probe run, grouped by feature and target
+func WithCucumberPusher(p push.Pusher) ExporterOption {
+
+	return ExportOptionFn(func(i interface{}) error {
+		var rcerror error
+		var c *cucumberHandler
+		var ok bool
+
+		if c, ok = i.(*cucumberHandler); ok {
+			c.pusher = p
+			return nil
+		}
+
+		return errortree.Add(rcerror, "WithCucumberPusher", errors.New("type mismatch, cucumberHandler expected"))
+	})
+}
+
+func (c *cucumberHandler) push(g prometheus.Gatherer, grouping map[string]string) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+	defer cancel()
+	if err := c.pusher.Push(ctx, g, grouping); err != nil {
+		pushFailuresCounterVec.WithLabelValues(grouping["feature"]).Inc()
+	}
+}
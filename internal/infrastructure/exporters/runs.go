@@ -0,0 +1,325 @@
+package exporters
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"fry.org/cmo/cli/internal/infrastructure/redact"
+	"github.com/speijnik/go-errortree"
+)
+
+var (
+	ContextKeyRun = ContextKey("run")
+)
+
+type RunEventKind string
+
+const (
+	RunEventStepStarted  RunEventKind = "step-started"
+	RunEventStepFinished RunEventKind = "step-finished"
+	RunEventOutput       RunEventKind = "output"
+	RunEventFinished     RunEventKind = "finished"
+)
+
+// RunEvent is a single notification about the progress of an in-flight run
+type RunEvent struct {
+	Seq      int          `json:"seq"`
+	Kind     RunEventKind `json:"kind"`
+	Time     time.Time    `json:"time"`
+	Scenario string       `json:"scenario,omitempty"`
+	Step     string       `json:"step,omitempty"`
+	Result   string       `json:"result,omitempty"`
+	Duration string       `json:"duration,omitempty"`
+	Line     string       `json:"line,omitempty"`
+	Error    string       `json:"error,omitempty"`
+}
+
+// ProbeRun holds the state of a single execution of a cucumber plugin
+type ProbeRun struct {
+	Id string
+	// TraceId is the OpenTelemetry trace of the run, empty when tracing is disabled
+	TraceId string
+	Feature string
+	Module  string
+	Target  string
+	// NetworkProfile is the network profile emulated by the module
+	NetworkProfile string
+	Start          time.Time
+	Duration       time.Duration
+	Set            CucumberStatsSet
+	Error          string
+	Artifacts      []Artifact
+	// VisualDiffs are the results of the comparisons against baseline screenshots
+	VisualDiffs []VisualDiff
+	// Network summarizes the requests issued by the browser in each scenario
+	Network map[string]NetworkStats
+	// Pages holds the navigation timing and Core Web Vitals of the pages visited
+	Pages []PageVitals
+	// Console holds the browser console messages and the uncaught exceptions
+	Console        []ConsoleMessage
+	ConsoleDropped int
+	// Attempts are the runs of the feature when the failed scenarios are retried, Set is the last one
+	Attempts []RunAttempt
+
+	mutex       sync.Mutex
+	done        bool
+	attempt     int
+	scenario    string
+	profiles    map[string]string
+	events      []RunEvent
+	subscribers map[chan RunEvent]struct{}
+}
+
+// VisualDiff is the result of comparing a screenshot against its baseline
+type VisualDiff struct {
+	Scenario string
+	Baseline string
+	Ratio    float64
+}
+
+// AddVisualDiff records the result of a baseline comparison in the run stored in the context, if any
+func AddVisualDiff(ctx context.Context, d VisualDiff) {
+
+	run, err := RunFromContext(ctx)
+	if err != nil {
+		return
+	}
+	if d.Scenario == "" {
+		d.Scenario, _ = StringFromContext(ctx, ContextKeyScenarioName)
+	}
+
+	run.mutex.Lock()
+	defer run.mutex.Unlock()
+	run.VisualDiffs = append(run.VisualDiffs, d)
+}
+
+func newRunId() string {
+	b := make([]byte, 8)
+
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+
+	return hex.EncodeToString(b)
+}
+
+func newProbeRun(feature string, module string, target string) *ProbeRun {
+
+	if module == "" {
+		module = DefaultModuleName
+	}
+
+	return &ProbeRun{
+		Id:          newRunId(),
+		Feature:     feature,
+		Module:      module,
+		Target:      redact.String(target),
+		Start:       time.Now(),
+		Set:         make(CucumberStatsSet),
+		subscribers: make(map[chan RunEvent]struct{}),
+	}
+}
+
+// Publish appends the event to the run backlog and forwards it to every subscriber
+func (r *ProbeRun) Publish(ev RunEvent) {
+
+	ev.Line = redact.String(ev.Line)
+	ev.Error = redact.String(ev.Error)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.done {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	if ev.Scenario != "" {
+		r.scenario = ev.Scenario
+	}
+	ev.Seq = len(r.events)
+	r.events = append(r.events, ev)
+	for ch := range r.subscribers {
+		select {
+		case ch <- ev:
+		default:
+			// Slow subscriber, drop it instead of blocking the run
+			delete(r.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe returns the events already published from the sequence number from, and a channel with
+// the upcoming ones. The channel is closed when the run finishes, when the subscriber is too slow
+// or when the returned cancel function is called.
+func (r *ProbeRun) Subscribe(from int) ([]RunEvent, <-chan RunEvent, func()) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if from < 0 {
+		from = 0
+	}
+	if from > len(r.events) {
+		from = len(r.events)
+	}
+	backlog := make([]RunEvent, len(r.events)-from)
+	copy(backlog, r.events[from:])
+	ch := make(chan RunEvent, 256)
+	if r.done {
+		close(ch)
+		return backlog, ch, func() {}
+	}
+	r.subscribers[ch] = struct{}{}
+	cancel := func() {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		if _, ok := r.subscribers[ch]; ok {
+			delete(r.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	return backlog, ch, cancel
+}
+
+// CurrentScenario returns the scenario of the last published event
+func (r *ProbeRun) CurrentScenario() string {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.scenario
+}
+
+func (r *ProbeRun) setNetwork(stats map[string]NetworkStats) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.Network = stats
+}
+
+func (r *ProbeRun) setPages(pages []PageVitals) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.Pages = pages
+}
+
+// complete stores the results of the run, the subscribers are notified by finish once the run is
+// in the history
+func (r *ProbeRun) complete(set CucumberStatsSet, err error) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if set != nil {
+		r.Set = redactSet(set)
+	}
+	if err != nil {
+		r.Error = redactError(err)
+	}
+	r.Duration = time.Since(r.Start)
+}
+
+// finish notifies the subscribers the run is over and closes their channels
+func (r *ProbeRun) finish() {
+
+	r.mutex.Lock()
+	runErr := r.Error
+	r.mutex.Unlock()
+	r.Publish(RunEvent{
+		Kind:  RunEventFinished,
+		Time:  time.Now(),
+		Error: runErr,
+	})
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.done = true
+	for ch := range r.subscribers {
+		delete(r.subscribers, ch)
+		close(ch)
+	}
+	r.events = nil
+}
+
+// redactSet masks the secrets of the output and the errors of the steps, in place
+func redactSet(set CucumberStatsSet) CucumberStatsSet {
+
+	for name, item := range set {
+		item.Output = redact.String(item.Output)
+		for n := range item.Stats {
+			item.Stats[n].Error = redact.String(item.Stats[n].Error)
+		}
+		set[name] = item
+	}
+
+	return set
+}
+
+func redactError(err error) string {
+
+	return redact.String(err.Error())
+}
+
+func RunFromContext(ctx context.Context) (*ProbeRun, error) {
+	var run *ProbeRun
+	var ok bool
+	var rcerror error
+
+	if run, ok = ctx.Value(ContextKeyRun).(*ProbeRun); !ok {
+		return nil, errortree.Add(rcerror, "RunFromContext", fmt.Errorf("type mismatch with key %s", ContextKeyRun))
+	}
+
+	return run, nil
+}
+
+// PublishRunEvent sends the event to the run stored in the context, if any
+func PublishRunEvent(ctx context.Context, ev RunEvent) {
+
+	if run, err := RunFromContext(ctx); err == nil {
+		run.Publish(ev)
+	}
+}
+
+type runOutputWriter struct {
+	ctx context.Context
+	buf bytes.Buffer
+}
+
+// NewRunOutputWriter returns a writer that publishes every complete line as an output event
+func NewRunOutputWriter(ctx context.Context) io.Writer {
+
+	return &runOutputWriter{
+		ctx: ctx,
+	}
+}
+
+func (w *runOutputWriter) Write(p []byte) (int, error) {
+
+	w.buf.Write(p)
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+		line := string(w.buf.Next(i + 1))
+		PublishRunEvent(w.ctx, RunEvent{
+			Kind: RunEventOutput,
+			Line: line[:len(line)-1],
+		})
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,189 @@
+package exporters
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sethvargo/go-retry"
+	"github.com/speijnik/go-errortree"
+)
+
+var (
+	ContextKeyScenarioTags = ContextKey("scenarioTags")
+)
+
+type BackoffKind string
+
+const (
+	BackoffConstant    BackoffKind = "constant"
+	BackoffExponential BackoffKind = "exponential"
+	BackoffFibonacci   BackoffKind = "fibonacci"
+)
+
+// BackoffPolicy sets how a step retries the browser actions that did not succeed yet, e.g. waiting
+// for a page to load
+type BackoffPolicy struct {
+	Backoff BackoffKind `json:"backoff,omitempty"`
+	// IntervalMs is the wait before the first retry, the base of the exponential and fibonacci backoffs
+	IntervalMs float64 `json:"interval_ms,omitempty"`
+	// MaxIntervalMs caps every wait, 0 means no cap
+	MaxIntervalMs float64 `json:"max_interval_ms,omitempty"`
+	// MaxDurationMs bounds the time spent retrying
+	MaxDurationMs float64 `json:"max_duration_ms,omitempty"`
+	// JitterPercent randomizes every wait up to this percentage, so the probes do not retry in lockstep
+	JitterPercent uint64 `json:"jitter_percent,omitempty"`
+}
+
+// StepRetryConfig is the backoff policy of the steps, it can be overridden for the scenarios with a tag
+type StepRetryConfig struct {
+	BackoffPolicy
+	// Tags are the policies of the steps of the scenarios with the tag, e.g. "@slow". The settings
+	// missing from a tag policy are taken from the module policy.
+	Tags map[string]BackoffPolicy `json:"tags,omitempty"`
+}
+
+// DefaultBackoffPolicy retries every 500ms for 7s
+func DefaultBackoffPolicy() BackoffPolicy {
+
+	return BackoffPolicy{
+		Backoff:       BackoffConstant,
+		IntervalMs:    500,
+		MaxDurationMs: 7000,
+	}
+}
+
+func (p BackoffPolicy) validate() error {
+	var rcerror error
+
+	switch p.Backoff {
+	case BackoffConstant, BackoffExponential, BackoffFibonacci:
+	default:
+		rcerror = errortree.Add(rcerror, "backoff", fmt.Errorf("unsupported backoff %q, expected one of constant, exponential, fibonacci", p.Backoff))
+	}
+	if p.IntervalMs <= 0 {
+		rcerror = errortree.Add(rcerror, "interval_ms", fmt.Errorf("interval %v not greater than zero", p.IntervalMs))
+	}
+	if p.MaxIntervalMs < 0 {
+		rcerror = errortree.Add(rcerror, "max_interval_ms", fmt.Errorf("negative max interval %v", p.MaxIntervalMs))
+	}
+	if p.MaxDurationMs <= 0 {
+		rcerror = errortree.Add(rcerror, "max_duration_ms", fmt.Errorf("max duration %v not greater than zero", p.MaxDurationMs))
+	}
+	if p.JitterPercent > 100 {
+		rcerror = errortree.Add(rcerror, "jitter_percent", fmt.Errorf("jitter %d%% out of the [0..100] range", p.JitterPercent))
+	}
+
+	return rcerror
+}
+
+// merge fills the settings missing from the policy with the ones of the base policy
+func (p BackoffPolicy) merge(base BackoffPolicy) BackoffPolicy {
+
+	if p.Backoff == "" {
+		p.Backoff = base.Backoff
+	}
+	if p.IntervalMs == 0 {
+		p.IntervalMs = base.IntervalMs
+	}
+	if p.MaxIntervalMs == 0 {
+		p.MaxIntervalMs = base.MaxIntervalMs
+	}
+	if p.MaxDurationMs == 0 {
+		p.MaxDurationMs = base.MaxDurationMs
+	}
+	if p.JitterPercent == 0 {
+		p.JitterPercent = base.JitterPercent
+	}
+
+	return p
+}
+
+func (s StepRetryConfig) validate() error {
+	var rcerror error
+
+	if err := s.BackoffPolicy.validate(); err != nil {
+		rcerror = errortree.Add(rcerror, "policy", err)
+	}
+	for tag, p := range s.Tags {
+		if !strings.HasPrefix(tag, "@") {
+			rcerror = errortree.Add(rcerror, "tags", fmt.Errorf("tag %q does not start with @", tag))
+			continue
+		}
+		if err := p.merge(s.BackoffPolicy).validate(); err != nil {
+			rcerror = errortree.Add(rcerror, tag, err)
+		}
+	}
+
+	return rcerror
+}
+
+// Policy returns the policy of the first tag with one, the module policy otherwise
+func (s StepRetryConfig) Policy(tags []string) BackoffPolicy {
+
+	for _, tag := range tags {
+		if p, ok := s.Tags[tag]; ok {
+			return p.merge(s.BackoffPolicy)
+		}
+	}
+
+	return s.BackoffPolicy
+}
+
+// NewBackoff returns a new backoff of the policy, the max duration starts counting now
+func (p BackoffPolicy) NewBackoff() retry.Backoff {
+	var b retry.Backoff
+
+	interval := time.Duration(p.IntervalMs * float64(time.Millisecond))
+	switch p.Backoff {
+	case BackoffExponential:
+		b = retry.NewExponential(interval)
+	case BackoffFibonacci:
+		b = retry.NewFibonacci(interval)
+	default:
+		b = retry.NewConstant(interval)
+	}
+	if p.JitterPercent > 0 {
+		b = retry.WithJitterPercent(p.JitterPercent, b)
+	}
+	if p.MaxIntervalMs > 0 {
+		b = retry.WithCappedDuration(time.Duration(p.MaxIntervalMs*float64(time.Millisecond)), b)
+	}
+
+	return retry.WithMaxDuration(time.Duration(p.MaxDurationMs*float64(time.Millisecond)), b)
+}
+
+// WaitOptions returns the options of a wait standing for the retries of a step, it lasts the max
+// duration of the policy and checks the condition at its interval
+func (p BackoffPolicy) WaitOptions() WaitOptions {
+
+	return WaitOptions{
+		Timeout:  time.Duration(p.MaxDurationMs * float64(time.Millisecond)),
+		Interval: time.Duration(p.IntervalMs * float64(time.Millisecond)),
+	}
+}
+
+// StepPolicy returns the policy of the module for the tags of the scenario in the context
+func StepPolicy(ctx context.Context) BackoffPolicy {
+
+	tags, _ := ctx.Value(ContextKeyScenarioTags).([]string)
+
+	return ModuleFromContext(ctx).StepRetry.Policy(tags)
+}
+
+// RetryStep calls fn until it succeeds, following the policy of the module for the tags of the
+// scenario in the context. The retries stop as soon as the context is done, e.g. when the probe times out.
+func RetryStep(ctx context.Context, fn func(ctx context.Context) error) error {
+
+	b := StepPolicy(ctx).NewBackoff()
+
+	return retry.Do(ctx, b, func(ct context.Context) error {
+		err := fn(ct)
+		if err == nil || ct.Err() != nil {
+			return err
+		}
+		// This marks the error as retryable
+		return retry.RetryableError(err)
+	})
+}
@@ -0,0 +1,31 @@
+package exporters
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStepPolicyWaitOptions(t *testing.T) {
+
+	module := DefaultModule()
+	module.StepRetry.Tags = map[string]BackoffPolicy{
+		"@slow": {IntervalMs: 1000, MaxDurationMs: 30000},
+	}
+	ctx := context.WithValue(context.Background(), ContextKeyModule, module)
+	tests := []struct {
+		name string
+		tags []string
+		want WaitOptions
+	}{
+		{name: "module policy", want: WaitOptions{Timeout: 7 * time.Second, Interval: 500 * time.Millisecond}},
+		{name: "other tag", tags: []string{"@smoke"}, want: WaitOptions{Timeout: 7 * time.Second, Interval: 500 * time.Millisecond}},
+		{name: "tag policy", tags: []string{"@smoke", "@slow"}, want: WaitOptions{Timeout: 30 * time.Second, Interval: time.Second}},
+	}
+	for _, tt := range tests {
+		ct := context.WithValue(ctx, ContextKeyScenarioTags, tt.tags)
+		if got := StepPolicy(ct).WaitOptions(); got != tt.want {
+			t.Errorf("%s: wait options = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
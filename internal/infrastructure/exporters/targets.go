@@ -0,0 +1,252 @@
+package exporters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/speijnik/go-errortree"
+)
+
+// Reasons of the rejected targets, used as metric label
+const (
+	TargetRejectedInvalid = "invalid"
+	TargetRejectedScheme  = "scheme"
+	TargetRejectedStatic  = "static"
+	TargetRejectedHost    = "host"
+	TargetRejectedNetwork = "network"
+)
+
+// DefaultTargetSchemes are the schemes accepted when the module does not list any
+var DefaultTargetSchemes = []string{"http", "https"}
+
+// TargetsConfig restricts the targets a module navigates to. A module without hosts, networks
+// nor static targets accepts any host, its addresses are not checked.
+type TargetsConfig struct {
+	// Schemes accepted, http and https when empty
+	Schemes []string `json:"schemes,omitempty"`
+	// Hosts are globs of the host names, * matches any sequence of characters, e.g. *.example.com
+	Hosts []string `json:"hosts,omitempty"`
+	// Networks are the CIDRs every address of the target host must belong to
+	Networks []string `json:"networks,omitempty"`
+	// Static are the only URLs accepted when not empty
+	Static []string `json:"static,omitempty"`
+}
+
+var targetRejectedCounterVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "probe_target_rejected_total",
+	Help: "Probes rejected because their target is not allowed by the module",
+}, []string{"module", "reason"})
+
+func init() {
+	prometheus.MustRegister(targetRejectedCounterVec)
+}
+
+// TargetError tells why a target is not allowed
+type TargetError struct {
+	Reason string
+	Err    error
+}
+
+func (e *TargetError) Error() string {
+
+	return e.Err.Error()
+}
+
+func (e *TargetError) Unwrap() error {
+
+	return e.Err
+}
+
+func (t TargetsConfig) validate() error {
+	var rcerror error
+
+	for n, s := range t.Schemes {
+		if s == "" {
+			rcerror = errortree.Add(rcerror, fmt.Sprintf("schemes[%d]", n), errors.New("empty scheme"))
+		}
+	}
+	for n, h := range t.Hosts {
+		if h == "" {
+			rcerror = errortree.Add(rcerror, fmt.Sprintf("hosts[%d]", n), errors.New("empty host pattern"))
+		}
+	}
+	for n, c := range t.Networks {
+		if _, _, err := net.ParseCIDR(c); err != nil {
+			rcerror = errortree.Add(rcerror, fmt.Sprintf("networks[%d]", n), err)
+		}
+	}
+	for n, s := range t.Static {
+		if u, err := url.Parse(s); err != nil {
+			rcerror = errortree.Add(rcerror, fmt.Sprintf("static[%d]", n), err)
+		} else if u.Scheme == "" || u.Host == "" {
+			rcerror = errortree.Add(rcerror, fmt.Sprintf("static[%d]", n), fmt.Errorf("%q is not an absolute URL", s))
+		}
+	}
+
+	return rcerror
+}
+
+// restricted tells whether the module lists the hosts, the networks or the static targets it accepts
+func (t TargetsConfig) restricted() bool {
+
+	return len(t.Hosts) > 0 || len(t.Networks) > 0 || len(t.Static) > 0
+}
+
+// lookupIPAddr resolves the host names of the targets
+var lookupIPAddr = net.DefaultResolver.LookupIPAddr
+
+// Allow checks the target against the lists. When the module sets any of them, the host names
+// are resolved, every address must belong to the networks when set, and the loopback, link-local
+// and unspecified addresses are denied unless they do.
+func (t TargetsConfig) Allow(ctx context.Context, target string) error {
+
+	u, err := t.parse(target)
+	if err != nil {
+		return err
+	}
+	if len(t.Static) > 0 {
+		allowed := false
+		for _, s := range t.Static {
+			if su, err := url.Parse(s); err == nil && sameURL(su, u) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return &TargetError{Reason: TargetRejectedStatic, Err: errors.New("the target is not one of the static targets")}
+		}
+		return t.allowAddresses(ctx, strings.ToLower(u.Hostname()))
+	}
+
+	return t.allowHost(ctx, u)
+}
+
+// allowNavigation checks a document loaded by the browser, e.g. after a redirection. The static
+// targets are only checked by Allow, the other lists apply to every navigation.
+func (t TargetsConfig) allowNavigation(ctx context.Context, target string) error {
+
+	u, err := t.parse(target)
+	if err != nil {
+		return err
+	}
+	if len(t.Static) > 0 {
+		return t.allowAddresses(ctx, strings.ToLower(u.Hostname()))
+	}
+
+	return t.allowHost(ctx, u)
+}
+
+// parse checks the target is an absolute URL with an allowed scheme
+func (t TargetsConfig) parse(target string) (*url.URL, error) {
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, &TargetError{Reason: TargetRejectedInvalid, Err: err}
+	}
+	if u.Scheme == "" || u.Hostname() == "" {
+		return nil, &TargetError{Reason: TargetRejectedInvalid, Err: errors.New("the target is not an absolute URL")}
+	}
+	schemes := t.Schemes
+	if len(schemes) == 0 {
+		schemes = DefaultTargetSchemes
+	}
+	if !containsFold(schemes, u.Scheme) {
+		return nil, &TargetError{Reason: TargetRejectedScheme, Err: fmt.Errorf("scheme %q is not allowed", u.Scheme)}
+	}
+
+	return u, nil
+}
+
+// allowHost checks the host against the globs, when set, and then its addresses
+func (t TargetsConfig) allowHost(ctx context.Context, u *url.URL) error {
+
+	host := strings.ToLower(u.Hostname())
+	if len(t.Hosts) > 0 {
+		matched := false
+		for _, h := range t.Hosts {
+			if hostRe(h).MatchString(host) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return &TargetError{Reason: TargetRejectedHost, Err: fmt.Errorf("host %q is not allowed", host)}
+		}
+	}
+
+	return t.allowAddresses(ctx, host)
+}
+
+// allowAddresses resolves the host and checks every address, a single address out of the networks
+// is enough to reject a host resolving to several ones. The addresses of the modules without any
+// list are not checked.
+func (t TargetsConfig) allowAddresses(ctx context.Context, host string) error {
+
+	if !t.restricted() {
+		return nil
+	}
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		addrs, err := lookupIPAddr(ctx, host)
+		if err != nil {
+			return &TargetError{Reason: TargetRejectedNetwork, Err: fmt.Errorf("host %q can not be resolved: %w", host, err)}
+		}
+		for _, a := range addrs {
+			ips = append(ips, a.IP)
+		}
+	}
+	for _, ip := range ips {
+		switch {
+		case t.inNetworks(ip):
+		case len(t.Networks) > 0:
+			return &TargetError{Reason: TargetRejectedNetwork, Err: fmt.Errorf("address %s of host %q is out of the allowed networks", ip, host)}
+		case ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified():
+			return &TargetError{Reason: TargetRejectedNetwork, Err: fmt.Errorf("address %s of host %q is a loopback or link-local address", ip, host)}
+		}
+	}
+
+	return nil
+}
+
+func (t TargetsConfig) inNetworks(ip net.IP) bool {
+
+	for _, c := range t.Networks {
+		if _, n, err := net.ParseCIDR(c); err == nil && n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hostRe converts a host glob to a case insensitive regular expression
+func hostRe(glob string) *regexp.Regexp {
+
+	return regexp.MustCompile("(?i)" + globRe(glob).String())
+}
+
+func containsFold(list []string, s string) bool {
+
+	for _, e := range list {
+		if strings.EqualFold(e, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sameURL compares the URLs ignoring the case of the scheme and the host
+func sameURL(a, b *url.URL) bool {
+
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(a.Host, b.Host) &&
+		a.EscapedPath() == b.EscapedPath() && a.RawQuery == b.RawQuery && a.Fragment == b.Fragment
+}
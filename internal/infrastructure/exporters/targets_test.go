@@ -0,0 +1,175 @@
+package exporters
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+)
+
+// stubResolver answers the lookups from the table, the other hosts are not found
+func stubResolver(t *testing.T, hosts map[string][]string) {
+
+	lookup := lookupIPAddr
+	lookupIPAddr = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		addrs, ok := hosts[host]
+		if !ok {
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		var all []net.IPAddr
+		for _, a := range addrs {
+			all = append(all, net.IPAddr{IP: net.ParseIP(a)})
+		}
+		return all, nil
+	}
+	t.Cleanup(func() { lookupIPAddr = lookup })
+}
+
+func TestTargetsAllow(t *testing.T) {
+
+	stubResolver(t, map[string][]string{
+		"example.com":      {"93.184.216.34"},
+		"localhost":        {"127.0.0.1", "::1"},
+		"app.example.com":  {"10.20.1.1"},
+		"meta.example.com": {"169.254.169.254"},
+		// A name resolving to a public and a private address, e.g. while rebinding
+		"rebind.example.com": {"10.20.1.2", "127.0.0.1"},
+		"other.example.com":  {"10.30.1.1"},
+	})
+	// Any host name is accepted, the addresses are checked
+	anyHost := TargetsConfig{Hosts: []string{"*"}}
+	tests := []struct {
+		name    string
+		targets TargetsConfig
+		target  string
+		reason  string
+	}{
+		{name: "any public host", target: "https://example.com/"},
+		{name: "invalid", target: "https://exa mple.com/", reason: TargetRejectedInvalid},
+		{name: "relative", target: "/login", reason: TargetRejectedInvalid},
+		{name: "scheme", target: "file://example.com/etc/passwd", reason: TargetRejectedScheme},
+		{name: "module scheme", targets: TargetsConfig{Schemes: []string{"https"}}, target: "http://example.com/", reason: TargetRejectedScheme},
+		{name: "loopback", targets: anyHost, target: "http://127.0.0.1:9090/", reason: TargetRejectedNetwork},
+		{name: "loopback v6", targets: anyHost, target: "http://[::1]/", reason: TargetRejectedNetwork},
+		{name: "mapped loopback", targets: anyHost, target: "http://[::ffff:127.0.0.1]/", reason: TargetRejectedNetwork},
+		{name: "unspecified", targets: anyHost, target: "http://0.0.0.0/", reason: TargetRejectedNetwork},
+		{name: "metadata", targets: anyHost, target: "http://169.254.169.254/latest/meta-data/", reason: TargetRejectedNetwork},
+		{name: "resolved loopback", targets: anyHost, target: "http://localhost:8080/", reason: TargetRejectedNetwork},
+		{name: "resolved metadata", targets: anyHost, target: "http://meta.example.com/", reason: TargetRejectedNetwork},
+		{name: "unresolved", targets: anyHost, target: "https://missing.example.com/", reason: TargetRejectedNetwork},
+		{name: "loopback network", targets: TargetsConfig{Networks: []string{"127.0.0.0/8", "::1/128"}}, target: "http://localhost:8080/"},
+		{name: "host", targets: TargetsConfig{Hosts: []string{"*.example.com"}}, target: "https://APP.example.com/"},
+		{name: "host mismatch", targets: TargetsConfig{Hosts: []string{"*.example.com"}}, target: "https://example.org/", reason: TargetRejectedHost},
+		{name: "host resolving to metadata", targets: TargetsConfig{Hosts: []string{"*.example.com"}}, target: "http://meta.example.com/", reason: TargetRejectedNetwork},
+		{name: "network", targets: TargetsConfig{Networks: []string{"10.20.0.0/16"}}, target: "https://app.example.com/"},
+		{name: "network mismatch", targets: TargetsConfig{Networks: []string{"10.20.0.0/16"}}, target: "https://other.example.com/", reason: TargetRejectedNetwork},
+		{name: "one address out of the network", targets: TargetsConfig{Networks: []string{"10.20.0.0/16"}}, target: "https://rebind.example.com/", reason: TargetRejectedNetwork},
+		{name: "host and network", targets: TargetsConfig{Hosts: []string{"*.example.com"}, Networks: []string{"10.20.0.0/16"}}, target: "https://app.example.com/"},
+		{name: "host out of the network", targets: TargetsConfig{Hosts: []string{"*.example.com"}, Networks: []string{"10.20.0.0/16"}}, target: "https://other.example.com/", reason: TargetRejectedNetwork},
+		{name: "static", targets: TargetsConfig{Static: []string{"https://example.com/login"}}, target: "HTTPS://EXAMPLE.COM/login"},
+		{name: "static mismatch", targets: TargetsConfig{Static: []string{"https://example.com/login"}}, target: "https://example.com/admin", reason: TargetRejectedStatic},
+		{name: "static loopback", targets: TargetsConfig{Static: []string{"http://localhost:8080/"}}, target: "http://localhost:8080/", reason: TargetRejectedNetwork},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.targets.Allow(context.Background(), tt.target)
+			if tt.reason == "" {
+				if err != nil {
+					t.Errorf("Allow(%q) = %v", tt.target, err)
+				}
+				return
+			}
+			var te *TargetError
+			if !errors.As(err, &te) || te.Reason != tt.reason {
+				t.Errorf("Allow(%q) = %v, want a %s rejection", tt.target, err, tt.reason)
+			}
+		})
+	}
+}
+
+func TestTargetsAllowDefaultModule(t *testing.T) {
+
+	stubResolver(t, map[string][]string{"localhost": {"127.0.0.1", "::1"}})
+	targets := DefaultModule().Targets
+	// Without any list, the hosts are not resolved nor their addresses checked
+	for _, target := range []string{
+		"https://example.com/",
+		"http://localhost:8080/",
+		"http://127.0.0.1:9090/",
+		"http://169.254.169.254/latest/meta-data/",
+		"https://internal.corp/",
+	} {
+		if err := targets.Allow(context.Background(), target); err != nil {
+			t.Errorf("Allow(%q) = %v", target, err)
+		}
+	}
+	if err := targets.Allow(context.Background(), "file:///etc/passwd"); err == nil {
+		t.Error("the default module accepted a file URL")
+	}
+	i := newInterceptor(InterceptionConfig{})
+	i.restrictNavigations(targets)
+	if i.targets != nil {
+		t.Error("the navigations of the default module are intercepted")
+	}
+}
+
+func TestTargetsAllowNavigation(t *testing.T) {
+
+	stubResolver(t, map[string][]string{
+		"portal.example.com": {"93.184.216.34"},
+		"idp.example.net":    {"93.184.216.35"},
+		"meta.example.com":   {"169.254.169.254"},
+	})
+	static := TargetsConfig{Static: []string{"https://portal.example.com/"}}
+	hosts := TargetsConfig{Hosts: []string{"*.example.com"}}
+	tests := []struct {
+		name    string
+		targets TargetsConfig
+		target  string
+		allowed bool
+	}{
+		// The static targets are the entry points, the redirections to the identity provider are followed
+		{name: "static redirection", targets: static, target: "https://idp.example.net/authorize", allowed: true},
+		{name: "static rebinding", targets: static, target: "http://meta.example.com/", allowed: false},
+		{name: "static metadata", targets: static, target: "http://169.254.169.254/", allowed: false},
+		{name: "host", targets: hosts, target: "https://portal.example.com/home", allowed: true},
+		{name: "host mismatch", targets: hosts, target: "https://idp.example.net/authorize", allowed: false},
+		{name: "scheme", targets: hosts, target: "ftp://portal.example.com/", allowed: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.targets.allowNavigation(context.Background(), tt.target); (err == nil) != tt.allowed {
+				t.Errorf("allowNavigation(%q) = %v, want allowed %v", tt.target, err, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestInterceptorNavigations(t *testing.T) {
+
+	stubResolver(t, map[string][]string{"portal.example.com": {"93.184.216.34"}})
+	i := newInterceptor(InterceptionConfig{})
+	i.restrictNavigations(TargetsConfig{Hosts: []string{"portal.example.com"}})
+	i.ctx = context.Background()
+
+	paused := func(u string, typ network.ResourceType) *fetch.EventRequestPaused {
+		return &fetch.EventRequestPaused{
+			RequestID:    "1",
+			Request:      &network.Request{URL: u, Method: "GET"},
+			ResourceType: typ,
+		}
+	}
+	if _, ok := i.resolve(paused("https://portal.example.com/", network.ResourceTypeDocument)).(*fetch.ContinueRequestParams); !ok {
+		t.Error("the navigation to an allowed host is not continued")
+	}
+	a, ok := i.resolve(paused("http://169.254.169.254/latest/meta-data/", network.ResourceTypeDocument)).(*fetch.FailRequestParams)
+	if !ok || a.ErrorReason != network.ErrorReasonBlockedByClient {
+		t.Error("the navigation to the metadata endpoint is not blocked")
+	}
+	if _, ok := i.resolve(paused("https://cdn.example.net/app.js", network.ResourceTypeScript)).(*fetch.ContinueRequestParams); !ok {
+		t.Error("a subresource is checked against the targets")
+	}
+}
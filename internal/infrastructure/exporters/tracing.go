@@ -0,0 +1,185 @@
+package exporters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"fry.org/cmo/cli/internal/infrastructure/redact"
+	"github.com/speijnik/go-errortree"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope of the probe runs spans
+const tracerName = "fry.org/cmo/cli/internal/infrastructure/exporters"
+
+// TracingConfig sets how the trace of a run reaches the applications probed
+type TracingConfig struct {
+	// Propagate are URL globs of the browser requests sent with the traceparent header of the run,
+	// so the spans of the backends join the trace of the probe
+	Propagate []string `json:"propagate,omitempty"`
+}
+
+func (t TracingConfig) validate() error {
+	var rcerror error
+
+	for n, p := range t.Propagate {
+		if p == "" {
+			rcerror = errortree.Add(rcerror, fmt.Sprintf("propagate[%d]", n), errors.New("empty URL pattern"))
+		}
+	}
+
+	return rcerror
+}
+
+// startRunSpan starts the root span of the run, child of the trace of the probe request if any.
+// Nothing is exported unless a tracer provider was installed.
+func startRunSpan(ctx context.Context, r *http.Request, run *ProbeRun) (context.Context, trace.Span) {
+
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(r.Header))
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "probe "+run.Feature,
+		trace.WithTimestamp(run.Start),
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			attribute.String("probe.run_id", run.Id),
+			attribute.String("probe.feature", run.Feature),
+			attribute.String("probe.module", run.Module),
+			attribute.String("probe.target", run.Target),
+		),
+	)
+	if sc := span.SpanContext(); sc.IsValid() {
+		run.TraceId = sc.TraceID().String()
+	}
+
+	return ctx, span
+}
+
+// traceparent returns the headers propagating the trace of the context
+func traceparent(ctx context.Context) map[string]string {
+
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+
+	return carrier
+}
+
+// endRunSpan records the scenarios, steps and browser requests of the finished run as children
+// of the root span, with the times they actually happened, and ends the root span.
+func endRunSpan(ctx context.Context, span trace.Span, run *ProbeRun, entries []*networkEntry) {
+
+	if !span.IsRecording() {
+		span.End()
+		return
+	}
+	tracer := otel.Tracer(tracerName)
+	end := run.Start.Add(run.Duration)
+
+	names := make([]string, 0, len(run.Set))
+	for name := range run.Set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	scenarios := make(map[string]context.Context)
+	failed := run.Error != ""
+	for _, name := range names {
+		item := run.Set[name]
+		if len(item.Stats) == 0 {
+			continue
+		}
+		start, finish := item.Stats[0].Start, item.Stats[0].Start
+		for _, st := range item.Stats {
+			if st.Start.Before(start) {
+				start = st.Start
+			}
+			if e := st.Start.Add(st.Duration); e.After(finish) {
+				finish = e
+			}
+		}
+		sctx, sspan := tracer.Start(ctx, "scenario "+name,
+			trace.WithTimestamp(start),
+			trace.WithAttributes(
+				attribute.String("probe.scenario", name),
+				attribute.String("probe.network_profile", run.NetworkProfileOf(name)),
+			),
+		)
+		scenarioFailed := false
+		for _, st := range item.Stats {
+			_, stspan := tracer.Start(sctx, "step "+st.Id,
+				trace.WithTimestamp(st.Start),
+				trace.WithAttributes(
+					attribute.String("probe.step", st.Id),
+					attribute.String("probe.step.result", st.Result.String()),
+				),
+			)
+			if st.Result == CucumberFailure {
+				scenarioFailed = true
+				stspan.SetStatus(codes.Error, st.Error)
+				if st.Error != "" {
+					stspan.RecordError(errors.New(st.Error), trace.WithTimestamp(st.Start.Add(st.Duration)))
+				}
+			}
+			stspan.End(trace.WithTimestamp(st.Start.Add(st.Duration)))
+		}
+		if scenarioFailed {
+			failed = true
+			sspan.SetStatus(codes.Error, "scenario failed")
+		}
+		sspan.End(trace.WithTimestamp(finish))
+		scenarios[name] = sctx
+	}
+
+	for _, e := range entries {
+		parent, ok := scenarios[e.scenario]
+		if !ok {
+			parent = ctx
+		}
+		finish := end
+		if !e.finished.IsZero() {
+			finish = e.wallTime.Add(e.finished.Sub(e.started))
+		}
+		attrs := []attribute.KeyValue{
+			semconv.HTTPMethodKey.String(e.request.Method),
+			semconv.HTTPURLKey.String(redact.String(e.request.URL)),
+		}
+		if e.response != nil {
+			attrs = append(attrs, semconv.HTTPStatusCodeKey.Int64(e.response.Status))
+		}
+		if e.bytes > 0 {
+			attrs = append(attrs, attribute.Int64("http.response_content_length", e.bytes))
+		}
+		if e.blocked {
+			attrs = append(attrs, attribute.Bool("probe.request.blocked", true))
+		}
+		_, rspan := tracer.Start(parent, "HTTP "+e.request.Method,
+			trace.WithTimestamp(e.wallTime),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attrs...),
+		)
+		if e.failed() {
+			msg := e.errorText
+			if msg == "" && e.response != nil {
+				msg = fmt.Sprintf("HTTP status %d", e.response.Status)
+			}
+			rspan.SetStatus(codes.Error, msg)
+		}
+		rspan.End(trace.WithTimestamp(finish))
+	}
+
+	if len(run.Attempts) > 1 {
+		span.SetAttributes(attribute.Int("probe.attempts", len(run.Attempts)))
+	}
+	if run.Error != "" {
+		span.RecordError(errors.New(run.Error), trace.WithTimestamp(end))
+	}
+	if failed {
+		span.SetStatus(codes.Error, "probe failed")
+	}
+	span.End(trace.WithTimestamp(end))
+}
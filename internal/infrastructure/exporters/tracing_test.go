@@ -0,0 +1,148 @@
+package exporters
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+func TestRunSpans(t *testing.T) {
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tp, prop := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(tp)
+		otel.SetTextMapPropagator(prop)
+	})
+
+	run := newProbeRun("loginPage", "portal", "https://portal.example.com/?code=secret-code")
+	start := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	run.Start = start
+	run.Duration = 10 * time.Second
+	run.Set = CucumberStatsSet{
+		"SuccessfulLogin": {Stats: []CucumberStats{
+			{Id: "IAmOnTheLoginPage", Start: start.Add(1 * time.Second), Duration: 2 * time.Second, Result: CucumberSuccess},
+			{Id: "IClickTheLoginButton", Start: start.Add(3 * time.Second), Duration: 4 * time.Second, Result: CucumberFailure, Error: "no consent button"},
+		}},
+	}
+	// The probe request is part of the trace of the scheduler
+	req := httptest.NewRequest("GET", "/probes", nil)
+	req.Header.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	ctx, span := startRunSpan(context.Background(), req, run)
+	if run.TraceId != "0af7651916cd43dd8448eb211c80319c" {
+		t.Errorf("trace id = %s, want the one of the probe request", run.TraceId)
+	}
+
+	requested := start.Add(1500 * time.Millisecond)
+	monoStart := time.Unix(1000, 0)
+	entries := []*networkEntry{
+		{
+			scenario: "SuccessfulLogin",
+			request:  &network.Request{Method: "GET", URL: "https://portal.example.com/callback?code=secret-code&state=xyz"},
+			response: &network.Response{Status: 200},
+			wallTime: requested,
+			started:  monoStart,
+			finished: monoStart.Add(300 * time.Millisecond),
+			bytes:    1024,
+		},
+		{
+			request:   &network.Request{Method: "POST", URL: "https://portal.example.com/token#access_token=secret-token"},
+			wallTime:  start,
+			errorText: "net::ERR_CONNECTION_RESET",
+		},
+	}
+	endRunSpan(ctx, span, run, entries)
+
+	spans := recorder.Ended()
+	byName := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range spans {
+		byName[s.Name()] = s
+	}
+	if len(spans) != 6 || len(byName) != 6 {
+		names := make([]string, 0, len(spans))
+		for _, s := range spans {
+			names = append(names, s.Name())
+		}
+		t.Fatalf("spans = %v, want the root, the scenario, its 2 steps and the 2 requests", names)
+	}
+
+	root := byName["probe loginPage"]
+	if root.Parent().SpanID().String() != "b7ad6b7169203331" || !root.Parent().IsRemote() {
+		t.Errorf("root parent = %s, want the span of the probe request", root.Parent().SpanID())
+	}
+	if !root.StartTime().Equal(start) || !root.EndTime().Equal(start.Add(10*time.Second)) {
+		t.Errorf("root span from %s to %s, want the run times", root.StartTime(), root.EndTime())
+	}
+	if root.Status().Code != codes.Error {
+		t.Errorf("root status = %v, want an error", root.Status())
+	}
+	for _, kv := range root.Attributes() {
+		if kv.Key == "probe.target" && strings.Contains(kv.Value.AsString(), "secret") {
+			t.Errorf("root target %s is not redacted", kv.Value.AsString())
+		}
+	}
+
+	scenario := byName["scenario SuccessfulLogin"]
+	if scenario.Parent().SpanID() != root.SpanContext().SpanID() {
+		t.Error("the scenario is not a child of the root span")
+	}
+	if !scenario.StartTime().Equal(start.Add(time.Second)) || !scenario.EndTime().Equal(start.Add(7*time.Second)) {
+		t.Errorf("scenario span from %s to %s, want from its first step to its last one", scenario.StartTime(), scenario.EndTime())
+	}
+	if scenario.Status().Code != codes.Error {
+		t.Errorf("scenario status = %v, want an error", scenario.Status())
+	}
+
+	step := byName["step IClickTheLoginButton"]
+	if step.Parent().SpanID() != scenario.SpanContext().SpanID() {
+		t.Error("the step is not a child of the scenario span")
+	}
+	if !step.StartTime().Equal(start.Add(3*time.Second)) || !step.EndTime().Equal(start.Add(7*time.Second)) {
+		t.Errorf("step span from %s to %s", step.StartTime(), step.EndTime())
+	}
+	if step.Status().Code != codes.Error || step.Status().Description != "no consent button" || len(step.Events()) != 1 {
+		t.Errorf("step status = %v with %d events, want the error of the step", step.Status(), len(step.Events()))
+	}
+	if byName["step IAmOnTheLoginPage"].Status().Code == codes.Error {
+		t.Error("the passed step is failed")
+	}
+
+	get := byName["HTTP GET"]
+	if get.Parent().SpanID() != scenario.SpanContext().SpanID() {
+		t.Error("the request of the scenario is not a child of its span")
+	}
+	if !get.StartTime().Equal(requested) || !get.EndTime().Equal(requested.Add(300*time.Millisecond)) {
+		t.Errorf("request span from %s to %s, want the wall time plus the monotonic duration", get.StartTime(), get.EndTime())
+	}
+	post := byName["HTTP POST"]
+	if post.Parent().SpanID() != root.SpanContext().SpanID() {
+		t.Error("the request issued before the first step is not a child of the root span")
+	}
+	if !post.EndTime().Equal(start.Add(10*time.Second)) || post.Status().Code != codes.Error {
+		t.Errorf("unfinished request span ends at %s with %v, want the end of the run and an error", post.EndTime(), post.Status())
+	}
+	for _, s := range []sdktrace.ReadOnlySpan{get, post} {
+		url := ""
+		for _, kv := range s.Attributes() {
+			if kv.Key == semconv.HTTPURLKey {
+				url = kv.Value.AsString()
+			}
+		}
+		if url == "" || strings.Contains(url, "secret") {
+			t.Errorf("request URL %q is missing or not redacted", url)
+		}
+	}
+}
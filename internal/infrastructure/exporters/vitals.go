@@ -0,0 +1,245 @@
+package exporters
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const vitalsBinding = "__uxperiReport"
+
+// vitalsScript observes the Core Web Vitals of every document and reports them, along with
+// the navigation timing, through the binding when the page is left
+const vitalsScript = `(() => {
+	if (window.__uxperiSnapshot) {
+		return;
+	}
+	const v = {lcp: -1, cls: 0, inp: -1, fid: -1};
+	const observe = (type, cb, opts) => {
+		try {
+			new PerformanceObserver(l => l.getEntries().forEach(cb)).observe(Object.assign({type: type, buffered: true}, opts || {}));
+		} catch (e) {}
+	};
+	observe('largest-contentful-paint', e => { v.lcp = e.renderTime || e.loadTime || e.startTime; });
+	observe('layout-shift', e => { if (!e.hadRecentInput) { v.cls += e.value; } });
+	observe('first-input', e => { v.fid = e.processingStart - e.startTime; });
+	observe('event', e => { if (e.interactionId && e.duration > v.inp) { v.inp = e.duration; } }, {durationThreshold: 16});
+	window.__uxperiSnapshot = () => {
+		const n = performance.getEntriesByType('navigation')[0];
+		const r = {url: location.href, lcp: v.lcp, cls: v.cls, inp: v.inp, fid: v.fid, dns: -1, tls: -1, ttfb: -1, dcl: -1, load: -1};
+		if (n) {
+			r.dns = n.domainLookupEnd - n.domainLookupStart;
+			r.tls = n.secureConnectionStart > 0 ? n.connectEnd - n.secureConnectionStart : -1;
+			r.ttfb = n.responseStart;
+			r.dcl = n.domContentLoadedEventEnd > 0 ? n.domContentLoadedEventEnd : -1;
+			r.load = n.loadEventEnd > 0 ? n.loadEventEnd : -1;
+		}
+		return JSON.stringify(r);
+	};
+	addEventListener('pagehide', () => {
+		if (window.` + vitalsBinding + `) {
+			window.` + vitalsBinding + `(window.__uxperiSnapshot());
+		}
+	});
+})()`
+
+// PageVitals holds the navigation timing and the Core Web Vitals of a page visited by a scenario.
+// Durations are expressed in seconds, negative values mean the browser did not report the metric.
+type PageVitals struct {
+	Scenario string
+	Path     string
+	DNS      float64
+	TLS      float64
+	TTFB     float64
+	DCL      float64
+	Load     float64
+	LCP      float64
+	CLS      float64
+	INP      float64
+	FID      float64
+}
+
+// Phases returns the navigation timing phases reported by the browser
+func (p PageVitals) Phases() map[string]float64 {
+
+	phases := make(map[string]float64)
+	for phase, d := range map[string]float64{
+		"dns":                p.DNS,
+		"tls":                p.TLS,
+		"ttfb":               p.TTFB,
+		"dom_content_loaded": p.DCL,
+		"load":               p.Load,
+	} {
+		if d >= 0 {
+			phases[phase] = d
+		}
+	}
+
+	return phases
+}
+
+// vitalsReport is the payload sent by vitalsScript, durations in milliseconds
+type vitalsReport struct {
+	URL  string  `json:"url"`
+	DNS  float64 `json:"dns"`
+	TLS  float64 `json:"tls"`
+	TTFB float64 `json:"ttfb"`
+	DCL  float64 `json:"dcl"`
+	Load float64 `json:"load"`
+	LCP  float64 `json:"lcp"`
+	CLS  float64 `json:"cls"`
+	INP  float64 `json:"inp"`
+	FID  float64 `json:"fid"`
+}
+
+var (
+	navigationTimingHistogramVec = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "page_navigation_timing_seconds",
+		Help:    "Navigation timing phases of the pages visited by the probes",
+		Buckets: []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"feature_name", "scenario_name", "page", "phase"})
+
+	webVitalsHistogramVec = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "page_web_vitals_seconds",
+		Help:    "Largest Contentful Paint, Interaction to Next Paint and First Input Delay of the pages visited by the probes",
+		Buckets: []float64{.05, .1, .2, .5, 1, 2.5, 4, 10, 30},
+	}, []string{"feature_name", "scenario_name", "page", "vital"})
+
+	layoutShiftHistogramVec = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "page_cumulative_layout_shift",
+		Help:    "Cumulative Layout Shift score of the pages visited by the probes",
+		Buckets: []float64{.01, .05, .1, .15, .25, .5, 1},
+	}, []string{"feature_name", "scenario_name", "page"})
+)
+
+func init() {
+	prometheus.MustRegister(navigationTimingHistogramVec)
+	prometheus.MustRegister(webVitalsHistogramVec)
+	prometheus.MustRegister(layoutShiftHistogramVec)
+}
+
+// vitalsRecorder gathers the reports of the pages visited during a run
+type vitalsRecorder struct {
+	mutex sync.Mutex
+	run   *ProbeRun
+	pages []PageVitals
+}
+
+func newVitalsRecorder(run *ProbeRun) *vitalsRecorder {
+
+	return &vitalsRecorder{
+		run: run,
+	}
+}
+
+// install registers the script and the binding used to report the metrics of every page
+func (v *vitalsRecorder) install() chromedp.Action {
+
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if err := runtime.AddBinding(vitalsBinding).Do(ctx); err != nil {
+			return err
+		}
+		_, err := page.AddScriptToEvaluateOnNewDocument(vitalsScript).Do(ctx)
+
+		return err
+	})
+}
+
+// listen is the chromedp target listener, it must not block
+func (v *vitalsRecorder) listen(ev interface{}) {
+
+	if ev, ok := ev.(*runtime.EventBindingCalled); ok && ev.Name == vitalsBinding {
+		v.add(ev.Payload)
+	}
+}
+
+// flush collects the metrics of the page being displayed, which has not been left yet
+func (v *vitalsRecorder) flush(ctx context.Context) error {
+	var payload string
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if err := chromedp.Run(ctx, chromedp.Evaluate(`window.__uxperiSnapshot ? window.__uxperiSnapshot() : ""`, &payload)); err != nil {
+		return err
+	}
+	v.add(payload)
+
+	return nil
+}
+
+func (v *vitalsRecorder) add(payload string) {
+	var r vitalsReport
+
+	if payload == "" || json.Unmarshal([]byte(payload), &r) != nil {
+		return
+	}
+	u, err := url.Parse(r.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return
+	}
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	seconds := func(ms float64) float64 {
+		if ms < 0 {
+			return -1
+		}
+		return ms / 1000
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.pages = append(v.pages, PageVitals{
+		Scenario: v.run.CurrentScenario(),
+		Path:     path,
+		DNS:      seconds(r.DNS),
+		TLS:      seconds(r.TLS),
+		TTFB:     seconds(r.TTFB),
+		DCL:      seconds(r.DCL),
+		Load:     seconds(r.Load),
+		LCP:      seconds(r.LCP),
+		CLS:      r.CLS,
+		INP:      seconds(r.INP),
+		FID:      seconds(r.FID),
+	})
+}
+
+func (v *vitalsRecorder) collected() []PageVitals {
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	pages := make([]PageVitals, len(v.pages))
+	copy(pages, v.pages)
+
+	return pages
+}
+
+// observeVitals feeds the process wide histograms with the pages of a run
+func observeVitals(feature string, run *ProbeRun) {
+
+	for _, p := range run.Pages {
+		for phase, d := range p.Phases() {
+			observeWithExemplar(navigationTimingHistogramVec.WithLabelValues(feature, p.Scenario, p.Path, phase), d, run)
+		}
+		vitals := map[string]float64{
+			"lcp": p.LCP,
+			"inp": p.INP,
+			"fid": p.FID,
+		}
+		for vital, d := range vitals {
+			if d >= 0 {
+				observeWithExemplar(webVitalsHistogramVec.WithLabelValues(feature, p.Scenario, p.Path, vital), d, run)
+			}
+		}
+		observeWithExemplar(layoutShiftHistogramVec.WithLabelValues(feature, p.Scenario, p.Path), p.CLS, run)
+	}
+}
@@ -0,0 +1,62 @@
+package exporters
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPageVitalsPhases(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		vital PageVitals
+		want  map[string]float64
+	}{
+		{
+			name:  "all reported",
+			vital: PageVitals{DNS: 0.01, TLS: 0.02, TTFB: 0.1, DCL: 0.5, Load: 1.2},
+			want:  map[string]float64{"dns": 0.01, "tls": 0.02, "ttfb": 0.1, "dom_content_loaded": 0.5, "load": 1.2},
+		},
+		{
+			// A reused connection resolves nothing, the zero durations are kept
+			name:  "reused connection",
+			vital: PageVitals{DNS: 0, TLS: -1, TTFB: 0.1, DCL: 0.5, Load: -1},
+			want:  map[string]float64{"dns": 0, "ttfb": 0.1, "dom_content_loaded": 0.5},
+		},
+		{
+			name:  "nothing reported",
+			vital: PageVitals{DNS: -1, TLS: -1, TTFB: -1, DCL: -1, Load: -1, LCP: 2, CLS: 0.1},
+			want:  map[string]float64{},
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.vital.Phases(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Phases() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestVitalsRecorderAdd(t *testing.T) {
+
+	run := newProbeRun("login.feature", "", "https://app.example.com")
+	run.Publish(RunEvent{Kind: RunEventStepStarted, Scenario: "Login"})
+	v := newVitalsRecorder(run)
+	for _, payload := range []string{
+		`{"url":"https://app.example.com/login?next=%2F","dns":12,"tls":-1,"ttfb":150,"dcl":400,"load":-1,"lcp":900,"cls":0.05,"inp":-1,"fid":8}`,
+		`{"url":"https://app.example.com","dns":-1,"tls":-1,"ttfb":-1,"dcl":-1,"load":-1,"lcp":-1,"cls":0,"inp":-1,"fid":-1}`,
+		// The reports of other documents nor the invalid ones are kept
+		`{"url":"about:blank","dns":1}`,
+		`{"url":`,
+		``,
+	} {
+		v.add(payload)
+	}
+
+	want := []PageVitals{
+		{Scenario: "Login", Path: "/login", DNS: 0.012, TLS: -1, TTFB: 0.15, DCL: 0.4, Load: -1, LCP: 0.9, CLS: 0.05, INP: -1, FID: 0.008},
+		{Scenario: "Login", Path: "/", DNS: -1, TLS: -1, TTFB: -1, DCL: -1, Load: -1, LCP: -1, CLS: 0, INP: -1, FID: -1},
+	}
+	if got := v.collected(); !reflect.DeepEqual(got, want) {
+		t.Errorf("collected %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,318 @@
+package exporters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+	"github.com/speijnik/go-errortree"
+)
+
+var (
+	ContextKeyNetwork = ContextKey("network")
+)
+
+// WaitConfig bounds the waits of the steps for the page to reach a state
+type WaitConfig struct {
+	// TimeoutMs is the longest a wait lasts before failing the step
+	TimeoutMs float64 `json:"timeout_ms"`
+	// IntervalMs is the time between two checks of the condition
+	IntervalMs float64 `json:"interval_ms"`
+	// NetworkIdleMs is how long the browser must not issue any request for the network to be idle
+	NetworkIdleMs float64 `json:"network_idle_ms"`
+	// LongRequestMs is how long a request stays in flight before it is deemed a long poll, it does
+	// not keep the network busy anymore
+	LongRequestMs float64 `json:"long_request_ms"`
+}
+
+// DefaultWaitConfig checks every 250ms for 7s, as long as the default step retries
+func DefaultWaitConfig() WaitConfig {
+
+	return WaitConfig{
+		TimeoutMs:     7000,
+		IntervalMs:    250,
+		NetworkIdleMs: 500,
+		LongRequestMs: 5000,
+	}
+}
+
+func (w WaitConfig) validate() error {
+	var rcerror error
+
+	if w.TimeoutMs <= 0 {
+		rcerror = errortree.Add(rcerror, "timeout_ms", fmt.Errorf("timeout %v not greater than zero", w.TimeoutMs))
+	}
+	if w.IntervalMs <= 0 {
+		rcerror = errortree.Add(rcerror, "interval_ms", fmt.Errorf("interval %v not greater than zero", w.IntervalMs))
+	} else if w.IntervalMs > w.TimeoutMs {
+		rcerror = errortree.Add(rcerror, "interval_ms", fmt.Errorf("interval %v greater than the timeout", w.IntervalMs))
+	}
+	if w.NetworkIdleMs <= 0 {
+		rcerror = errortree.Add(rcerror, "network_idle_ms", fmt.Errorf("idle time %v not greater than zero", w.NetworkIdleMs))
+	}
+	if w.LongRequestMs <= 0 {
+		rcerror = errortree.Add(rcerror, "long_request_ms", fmt.Errorf("long request time %v not greater than zero", w.LongRequestMs))
+	}
+
+	return rcerror
+}
+
+// Options returns the options of the waits of the module, they do not reload the page
+func (w WaitConfig) Options() WaitOptions {
+
+	return WaitOptions{
+		Timeout:  time.Duration(w.TimeoutMs * float64(time.Millisecond)),
+		Interval: time.Duration(w.IntervalMs * float64(time.Millisecond)),
+	}
+}
+
+// NetworkIdle returns the quiet time of the network idle condition
+func (w WaitConfig) NetworkIdle() time.Duration {
+
+	return time.Duration(w.NetworkIdleMs * float64(time.Millisecond))
+}
+
+// LongRequest returns how long a request stays in flight before the network idle condition ignores it
+func (w WaitConfig) LongRequest() time.Duration {
+
+	return time.Duration(w.LongRequestMs * float64(time.Millisecond))
+}
+
+// WaitOptions bound a wait
+type WaitOptions struct {
+	Timeout  time.Duration
+	Interval time.Duration
+	// ReloadEvery reloads the page when the condition has not been met since the last load
+	// for this long, 0 never reloads
+	ReloadEvery time.Duration
+}
+
+// WaitCondition is a state of the page awaited by Wait
+type WaitCondition struct {
+	// Description completes "waiting for", e.g. `selector "h3" to be visible`
+	Description string
+	// Check returns nil once the condition is met, otherwise an error telling why it is not
+	Check func(ctx context.Context) error
+}
+
+// WaitTimeoutError is returned when a condition was not met before the timeout
+type WaitTimeoutError struct {
+	Condition string
+	Timeout   time.Duration
+	Checks    int
+	Reloads   int
+	// Last is the reason the condition was not met at the last check
+	Last error
+}
+
+func (e *WaitTimeoutError) Error() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "timed out after %s waiting for %s", e.Timeout, e.Condition)
+	if e.Last != nil {
+		fmt.Fprintf(&b, ": %s", e.Last.Error())
+	}
+	fmt.Fprintf(&b, " (%d checks", e.Checks)
+	if e.Reloads > 0 {
+		fmt.Fprintf(&b, ", %d reloads", e.Reloads)
+	}
+	b.WriteString(")")
+
+	return b.String()
+}
+
+func (e *WaitTimeoutError) Unwrap() error {
+
+	return e.Last
+}
+
+// Wait checks the condition every interval until it is met or the timeout expires. The errors of the
+// checks, e.g. an evaluation interrupted by a navigation, do not end the wait. The wait ends early with
+// the error of the context when the context is done, e.g. when the probe times out.
+func Wait(ctx context.Context, cond WaitCondition, opts WaitOptions) error {
+	var checks, reloads int
+	var last error
+
+	if opts.Timeout <= 0 || opts.Interval <= 0 {
+		return fmt.Errorf("waiting for %s: the timeout and the interval must be greater than zero", cond.Description)
+	}
+	wctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	loaded := time.Now()
+	for {
+		checks++
+		err := cond.Check(wctx)
+		if err == nil {
+			return nil
+		}
+		if wctx.Err() == nil {
+			last = err
+		}
+		if opts.ReloadEvery > 0 && time.Since(loaded) >= opts.ReloadEvery && wctx.Err() == nil {
+			if err = chromedp.Run(wctx, chromedp.Reload()); err != nil && wctx.Err() == nil {
+				last = fmt.Errorf("reload failed: %w", err)
+			}
+			reloads++
+			loaded = time.Now()
+		}
+		select {
+		case <-wctx.Done():
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return &WaitTimeoutError{
+				Condition: cond.Description,
+				Timeout:   opts.Timeout,
+				Checks:    checks,
+				Reloads:   reloads,
+				Last:      last,
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+// visibleJS tells whether the element of the selector is rendered, like chromedp.WaitVisible
+const visibleJS = `(() => {
+	const e = document.querySelector(%q);
+	if (e === null) {
+		return "missing";
+	}
+	if (!(e.offsetWidth || e.offsetHeight || e.getClientRects().length) || getComputedStyle(e).visibility === "hidden") {
+		return "hidden";
+	}
+	return "visible";
+})()`
+
+func selectorState(ctx context.Context, selector string) (string, error) {
+	var state string
+
+	err := chromedp.Run(ctx, chromedp.Evaluate(fmt.Sprintf(visibleJS, selector), &state))
+
+	return state, err
+}
+
+// SelectorVisible waits for the element of the CSS selector to be rendered, XPath expressions are
+// not supported
+func SelectorVisible(selector string) WaitCondition {
+
+	return WaitCondition{
+		Description: fmt.Sprintf("selector %q to be visible", selector),
+		Check: func(ctx context.Context) error {
+			state, err := selectorState(ctx, selector)
+			switch {
+			case err != nil:
+				return err
+			case state == "missing":
+				return errors.New("no element matches the selector")
+			case state == "hidden":
+				return errors.New("the element is hidden")
+			}
+			return nil
+		},
+	}
+}
+
+// SelectorHidden waits for the element of the CSS selector to be hidden or removed from the page
+func SelectorHidden(selector string) WaitCondition {
+
+	return WaitCondition{
+		Description: fmt.Sprintf("selector %q to be hidden", selector),
+		Check: func(ctx context.Context) error {
+			state, err := selectorState(ctx, selector)
+			if err != nil {
+				return err
+			}
+			if state == "visible" {
+				return errors.New("the element is visible")
+			}
+			return nil
+		},
+	}
+}
+
+// URLMatches waits for the location of the page to match the regular expression
+func URLMatches(re *regexp.Regexp) WaitCondition {
+
+	return WaitCondition{
+		Description: fmt.Sprintf("the URL to match %q", re.String()),
+		Check: func(ctx context.Context) error {
+			var location string
+
+			if err := chromedp.Run(ctx, chromedp.Location(&location)); err != nil {
+				return err
+			}
+			if !re.MatchString(location) {
+				return fmt.Errorf("the URL is %s", location)
+			}
+			return nil
+		},
+	}
+}
+
+// NetworkIdle waits for the browser to have no request in flight and to issue none for the quiet
+// time. The event streams, the requests of the documents gone and the ones in flight for longer than
+// long, e.g. long polls, are ignored. Without the network recorder of the run, it waits for the
+// document to be loaded.
+func NetworkIdle(quiet time.Duration, long time.Duration) WaitCondition {
+
+	return WaitCondition{
+		Description: fmt.Sprintf("the network to be idle for %s", quiet),
+		Check: func(ctx context.Context) error {
+			n, ok := ctx.Value(ContextKeyNetwork).(*networkRecorder)
+			if !ok {
+				var state string
+				if err := chromedp.Run(ctx, chromedp.Evaluate(`document.readyState`, &state)); err != nil {
+					return err
+				}
+				if state != "complete" {
+					return fmt.Errorf("the document is %s", state)
+				}
+				return nil
+			}
+			inflight, last := n.activity(long)
+			if inflight > 0 {
+				return fmt.Errorf("%d requests in flight", inflight)
+			}
+			if since := time.Since(last); since < quiet {
+				return fmt.Errorf("last request finished %s ago", since.Round(time.Millisecond))
+			}
+			return nil
+		},
+	}
+}
+
+// JSPredicate waits for the JavaScript expression to be truthy, the promises are awaited
+func JSPredicate(expression string) WaitCondition {
+
+	desc := strings.Join(strings.Fields(expression), " ")
+	if len(desc) > 60 {
+		desc = desc[:57] + "..."
+	}
+
+	return WaitCondition{
+		Description: fmt.Sprintf("the script %q to return true", desc),
+		Check: func(ctx context.Context) error {
+			var ok bool
+
+			js := fmt.Sprintf("(async () => !!(await (%s)))()", expression)
+			if err := chromedp.Run(ctx, chromedp.Evaluate(js, &ok, func(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+				return p.WithAwaitPromise(true)
+			})); err != nil {
+				return err
+			}
+			if !ok {
+				return errors.New("the script returned false")
+			}
+			return nil
+		},
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"fry.org/cmo/cli/internal/application/logger"
+	"fry.org/cmo/cli/internal/infrastructure/redact"
 	"github.com/sirupsen/logrus"
 	"github.com/sirupsen/logrus/hooks/writer"
 	"github.com/speijnik/go-errortree"
@@ -46,11 +47,11 @@ func (l *Logger) SetFormat(format string) error {
 	}
 	output := u.Query().Get("output")
 	if output == "json" {
-		l.log.SetFormatter(&logrus.JSONFormatter{})
-		l.entry.Logger.Formatter = &logrus.JSONFormatter{}
+		l.log.SetFormatter(redactedFormatter{&logrus.JSONFormatter{}})
+		l.entry.Logger.Formatter = redactedFormatter{&logrus.JSONFormatter{}}
 	} else {
-		l.log.SetFormatter(&logrus.TextFormatter{})
-		l.entry.Logger.Formatter = &logrus.TextFormatter{}
+		l.log.SetFormatter(redactedFormatter{&logrus.TextFormatter{}})
+		l.entry.Logger.Formatter = redactedFormatter{&logrus.TextFormatter{}}
 	}
 
 	switch u.Opaque {
@@ -83,10 +84,48 @@ func (l *Logger) SetFormat(format string) error {
 	return nil
 }
 
+// redactedFormatter masks the secrets in the entries, whatever the message or the field they are in. The
+// entries are masked before being formatted, the escapes of the formatters would hide the secrets.
+type redactedFormatter struct {
+	logrus.Formatter
+}
+
+func (f redactedFormatter) Format(e *logrus.Entry) ([]byte, error) {
+
+	r := *e
+	r.Message = redact.String(e.Message)
+	r.Data = make(logrus.Fields, len(e.Data))
+	for k, v := range e.Data {
+		r.Data[k] = redactValue(v)
+	}
+
+	return f.Formatter.Format(&r)
+}
+
+// redactValue returns the value, or its masked text when it holds a secret
+func redactValue(v interface{}) interface{} {
+
+	var s string
+	switch v := v.(type) {
+	case string:
+		return redact.String(v)
+	case error:
+		s = v.Error()
+	default:
+		s = fmt.Sprint(v)
+	}
+	if masked := redact.String(s); masked != s {
+		return masked
+	}
+
+	return v
+}
+
 // NewLogger creates a new `log.Logger` from the provided entry
 func NewLogger() logger.Logger {
 
 	l := logrus.New()
+	l.SetFormatter(redactedFormatter{l.Formatter})
 
 	out := Logger{
 		log:   l,
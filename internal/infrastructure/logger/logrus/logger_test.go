@@ -0,0 +1,64 @@
+package logrus
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"fry.org/cmo/cli/internal/infrastructure/redact"
+	"github.com/sirupsen/logrus"
+)
+
+func TestRedactedFormatter(t *testing.T) {
+
+	redact.AddSecret("p&ss<w\"rd")
+	tests := []struct {
+		name      string
+		formatter logrus.Formatter
+	}{
+		{"json", &logrus.JSONFormatter{}},
+		{"text", &logrus.TextFormatter{DisableColors: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			l := logrus.New()
+			l.Out = &out
+			l.SetFormatter(redactedFormatter{tt.formatter})
+			l.WithFields(logrus.Fields{
+				"url":      "https://app/cb?state=xyz&code=0.AXk",
+				"error":    errors.New("login with p&ss<w\"rd failed"),
+				"attempts": 3,
+			}).Info(`redirected to https://app/#id_token=abc&state=xyz with p&ss<w"rd`)
+
+			for _, secret := range []string{"0.AXk", "abc&", "p&ss", `p\u0026ss`} {
+				if strings.Contains(out.String(), secret) {
+					t.Errorf("%s leaked in %s", secret, out.String())
+				}
+			}
+			if !strings.Contains(out.String(), redact.Mask) {
+				t.Errorf("nothing masked in %s", out.String())
+			}
+		})
+	}
+}
+
+func TestRedactedFormatterKeepsTypes(t *testing.T) {
+	var out bytes.Buffer
+	var entry map[string]interface{}
+
+	l := logrus.New()
+	l.Out = &out
+	l.SetFormatter(redactedFormatter{&logrus.JSONFormatter{}})
+	l.WithField("attempts", 3).Info("done")
+
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["attempts"] != float64(3) {
+		t.Errorf("attempts = %#v, want 3", entry["attempts"])
+	}
+}
@@ -0,0 +1,108 @@
+// Package notify tells the operators when a probed scenario starts failing and when it recovers.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/speijnik/go-errortree"
+)
+
+type State string
+
+const (
+	// StateFailing is sent when the scenario reached the consecutive failures threshold
+	StateFailing State = "failing"
+	// StateRecovered is sent when a scenario notified as failing passes again
+	StateRecovered State = "recovered"
+)
+
+// Notification describes a transition of a scenario probed against a target
+type Notification struct {
+	State    State     `json:"state"`
+	Time     time.Time `json:"time"`
+	Feature  string    `json:"feature"`
+	Module   string    `json:"module"`
+	Target   string    `json:"target"`
+	Scenario string    `json:"scenario"`
+	// Failures is the number of consecutive failed runs of the scenario
+	Failures int `json:"failures,omitempty"`
+	// Step is the failed step, if any
+	Step string `json:"step,omitempty"`
+	// Error is the error of the step, or the error tree of the run
+	Error  string `json:"error,omitempty"`
+	RunId  string `json:"run_id"`
+	RunURL string `json:"run_url,omitempty"`
+	// SnapshotURL is the last snapshot taken by the scenario, if any
+	SnapshotURL string `json:"snapshot_url,omitempty"`
+}
+
+// Summary is a one line description of the notification
+func (n Notification) Summary() string {
+
+	if n.State == StateRecovered {
+		return fmt.Sprintf("RECOVERED %s/%s on %s", n.Feature, n.Scenario, n.Target)
+	}
+
+	return fmt.Sprintf("FAILING %s/%s on %s", n.Feature, n.Scenario, n.Target)
+}
+
+// Notifier sends the notifications to a channel
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// ParseNotifier creates a notifier from an URI like
+//
+//	notify:webhook?url=<url>
+//	notify:slack?url=<url>
+//	notify:smtp?url=smtp[s]://[<user>:<password>@]<host>:<port>&from=<address>&to=<address>[,<address>]
+//
+// The webhook gets the notification as JSON, the slack notifier posts a message to an incoming webhook
+// of Slack or of a compatible chat. The smtp scheme upgrades the connection with STARTTLS when the
+// server offers it, the smtps one uses TLS from the start.
+func ParseNotifier(URI string) (Notifier, error) {
+	var n Notifier
+	var rcerror error
+
+	u, err := url.Parse(URI)
+	if err != nil {
+		return nil, errortree.Add(rcerror, "ParseNotifier", err)
+	}
+	if u.Scheme != "notify" {
+		return nil, errortree.Add(rcerror, "ParseNotifier", fmt.Errorf("invalid scheme %s", URI))
+	}
+	q := u.Query()
+	endpoint := q.Get("url")
+	if endpoint == "" {
+		return nil, errortree.Add(rcerror, "ParseNotifier", errors.New("missing url query argument"))
+	}
+	switch u.Opaque {
+	case "webhook":
+		if n, err = NewWebhook(endpoint); err != nil {
+			return nil, errortree.Add(rcerror, "ParseNotifier", err)
+		}
+	case "slack":
+		if n, err = NewSlack(endpoint); err != nil {
+			return nil, errortree.Add(rcerror, "ParseNotifier", err)
+		}
+	case "smtp":
+		var to []string
+		for _, addr := range strings.Split(q.Get("to"), ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				to = append(to, addr)
+			}
+		}
+		if n, err = NewSMTP(endpoint, q.Get("from"), to); err != nil {
+			return nil, errortree.Add(rcerror, "ParseNotifier", err)
+		}
+	default:
+		return nil, errortree.Add(rcerror, "ParseNotifier", fmt.Errorf("unsupported notifier implementation %q", u.Opaque))
+	}
+
+	return n, nil
+}
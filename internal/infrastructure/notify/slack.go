@@ -0,0 +1,78 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/speijnik/go-errortree"
+)
+
+// Slack posts the notifications to an incoming webhook of Slack, or of a chat accepting its
+// payload like Mattermost or Rocket.Chat
+type Slack struct {
+	url    string
+	client *http.Client
+}
+
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+func NewSlack(endpoint string) (*Slack, error) {
+	var rcerror error
+
+	if err := absoluteURL(endpoint); err != nil {
+		return nil, errortree.Add(rcerror, "NewSlack", err)
+	}
+
+	return &Slack{
+		url:    endpoint,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (s *Slack) Notify(ctx context.Context, n Notification) error {
+	var rcerror error
+
+	if err := postJSON(ctx, s.client, s.url, slackMessage{Text: slackText(n)}); err != nil {
+		return errortree.Add(rcerror, "Notify", err)
+	}
+
+	return nil
+}
+
+// slackText formats the notification with the mrkdwn syntax
+func slackText(n Notification) string {
+	var b strings.Builder
+
+	if n.State == StateRecovered {
+		fmt.Fprintf(&b, ":large_green_circle: *%s* scenario *%s* recovered on %s", n.Feature, n.Scenario, n.Target)
+	} else {
+		fmt.Fprintf(&b, ":red_circle: *%s* scenario *%s* failing on %s", n.Feature, n.Scenario, n.Target)
+		if n.Failures > 1 {
+			fmt.Fprintf(&b, " (%d consecutive failures)", n.Failures)
+		}
+	}
+	fmt.Fprintf(&b, "\nModule: %s", n.Module)
+	if n.Step != "" {
+		fmt.Fprintf(&b, "\nStep: %s", n.Step)
+	}
+	if n.Error != "" {
+		fmt.Fprintf(&b, "\n```%s```", strings.ReplaceAll(n.Error, "```", "'''"))
+	}
+	var links []string
+	if n.RunURL != "" {
+		links = append(links, fmt.Sprintf("<%s|Run %s>", n.RunURL, n.RunId))
+	}
+	if n.SnapshotURL != "" {
+		links = append(links, fmt.Sprintf("<%s|Snapshot>", n.SnapshotURL))
+	}
+	if len(links) > 0 {
+		fmt.Fprintf(&b, "\n%s", strings.Join(links, " · "))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,63 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestSlackPayload(t *testing.T) {
+
+	tests := []struct {
+		name string
+		n    Notification
+		want string
+	}{
+		{
+			name: "failing",
+			n:    failing,
+			want: ":red_circle: *login.feature* scenario *Login* failing on https://app.example.com (3 consecutive failures)\n" +
+				"Module: default\n" +
+				"Step: IShouldBeRedirectedToTheDashboardPage\n" +
+				"```timeout```\n" +
+				"<http://uxperi:9090/history?id=run1|Run run1> · <http://uxperi:9090/history/artifacts?id=run1&name=shot.png|Snapshot>",
+		},
+		{
+			name: "first failure",
+			n: Notification{State: StateFailing, Feature: "login.feature", Module: "default", Target: "https://app.example.com",
+				Scenario: "Login", Failures: 1, Error: "a ``` fence", RunId: "run1"},
+			want: ":red_circle: *login.feature* scenario *Login* failing on https://app.example.com\n" +
+				"Module: default\n" +
+				"```a ''' fence```",
+		},
+		{
+			name: "recovered",
+			n: Notification{State: StateRecovered, Feature: "login.feature", Module: "default", Target: "https://app.example.com",
+				Scenario: "Login", RunId: "run2", RunURL: "http://uxperi:9090/history?id=run2"},
+			want: ":large_green_circle: *login.feature* scenario *Login* recovered on https://app.example.com\n" +
+				"Module: default\n" +
+				"<http://uxperi:9090/history?id=run2|Run run2>",
+		},
+	}
+	c := &capture{status: http.StatusOK}
+	srv := c.server(t)
+	s, err := NewSlack(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.Notify(context.Background(), tt.n); err != nil {
+				t.Fatal(err)
+			}
+			var got map[string]string
+			if err := json.Unmarshal(c.body, &got); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 1 || got["text"] != tt.want {
+				t.Errorf("payload = %s, want the text\n%s", c.body, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/speijnik/go-errortree"
+)
+
+// SMTP mails the notifications
+type SMTP struct {
+	host     string
+	addr     string
+	implicit bool
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+// NewSMTP sends the mails through the server of the url, with the credentials of its user info
+func NewSMTP(endpoint string, from string, to []string) (*SMTP, error) {
+	var rcerror error
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, errortree.Add(rcerror, "NewSMTP", err)
+	}
+	s := &SMTP{
+		host: u.Hostname(),
+		addr: u.Host,
+		from: from,
+		to:   to,
+	}
+	switch u.Scheme {
+	case "smtp":
+		if u.Port() == "" {
+			s.addr = net.JoinHostPort(s.host, "587")
+		}
+	case "smtps":
+		s.implicit = true
+		if u.Port() == "" {
+			s.addr = net.JoinHostPort(s.host, "465")
+		}
+	default:
+		return nil, errortree.Add(rcerror, "NewSMTP", fmt.Errorf("unsupported scheme %q, smtp or smtps expected", u.Scheme))
+	}
+	if s.host == "" {
+		return nil, errortree.Add(rcerror, "NewSMTP", errors.New("missing host"))
+	}
+	if _, err = mail.ParseAddress(from); err != nil {
+		return nil, errortree.Add(rcerror, "NewSMTP", fmt.Errorf("invalid from address: %w", err))
+	}
+	if len(to) == 0 {
+		return nil, errortree.Add(rcerror, "NewSMTP", errors.New("missing to addresses"))
+	}
+	for _, addr := range to {
+		if _, err = mail.ParseAddress(addr); err != nil {
+			return nil, errortree.Add(rcerror, "NewSMTP", fmt.Errorf("invalid to address: %w", err))
+		}
+	}
+	if u.User != nil {
+		s.username = u.User.Username()
+		s.password, _ = u.User.Password()
+	}
+
+	return s, nil
+}
+
+func (s *SMTP) Notify(ctx context.Context, n Notification) error {
+	var rcerror error
+
+	if err := s.send(ctx, s.message(n)); err != nil {
+		return errortree.Add(rcerror, "Notify", err)
+	}
+
+	return nil
+}
+
+// send delivers the message, the deadline of the context bounds the whole SMTP conversation
+func (s *SMTP) send(ctx context.Context, msg []byte) error {
+	var conn net.Conn
+	var err error
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	if s.implicit {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: s.host}}).DialContext(ctx, "tcp", s.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", s.addr)
+	}
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if !s.implicit {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err = c.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+				return err
+			}
+		}
+	}
+	if s.username != "" {
+		// PlainAuth refuses to send the credentials over an unencrypted connection but to localhost
+		if err = c.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return err
+		}
+	}
+	if err = c.Mail(s.from); err != nil {
+		return err
+	}
+	for _, addr := range s.to {
+		if err = c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(msg); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// message writes the mail with a plain text body
+func (s *SMTP) message(n Notification) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "From: %s\r\n", s.from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(s.to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "[uxperi] "+n.Summary()))
+	fmt.Fprintf(&b, "Date: %s\r\n", n.Time.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+
+	lines := []string{
+		n.Summary(),
+		"",
+		"Feature:  " + n.Feature,
+		"Scenario: " + n.Scenario,
+		"Module:   " + n.Module,
+		"Target:   " + n.Target,
+		"Run:      " + n.RunId,
+	}
+	if n.Failures > 1 {
+		lines = append(lines, fmt.Sprintf("Failures: %d consecutive", n.Failures))
+	}
+	if n.Step != "" {
+		lines = append(lines, "Step:     "+n.Step)
+	}
+	if n.RunURL != "" {
+		lines = append(lines, "", "Run details: "+n.RunURL)
+	}
+	if n.SnapshotURL != "" {
+		lines = append(lines, "Snapshot:    "+n.SnapshotURL)
+	}
+	if n.Error != "" {
+		lines = append(lines, "", n.Error)
+	}
+	for _, l := range lines {
+		// Bare LF are not allowed in the DATA of SMTP
+		b.WriteString(strings.ReplaceAll(strings.ReplaceAll(l, "\r\n", "\n"), "\n", "\r\n"))
+		b.WriteString("\r\n")
+	}
+
+	return b.Bytes()
+}
@@ -0,0 +1,97 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/speijnik/go-errortree"
+)
+
+// Webhook posts the notifications as JSON
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhook posts to the url, the user info of the url is used for the basic auth
+func NewWebhook(endpoint string) (*Webhook, error) {
+	var rcerror error
+
+	if err := absoluteURL(endpoint); err != nil {
+		return nil, errortree.Add(rcerror, "NewWebhook", err)
+	}
+
+	return &Webhook{
+		url:    endpoint,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (w *Webhook) Notify(ctx context.Context, n Notification) error {
+	var rcerror error
+
+	if err := postJSON(ctx, w.client, w.url, n); err != nil {
+		return errortree.Add(rcerror, "Notify", err)
+	}
+
+	return nil
+}
+
+func absoluteURL(endpoint string) error {
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return errors.New("the url is not absolute")
+	}
+
+	return nil
+}
+
+// postJSON sends the payload and fails on any status code but 2xx
+func postJSON(ctx context.Context, client *http.Client, endpoint string, payload interface{}) error {
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+	username, password := "", ""
+	if u.User != nil {
+		username = u.User.Username()
+		password, _ = u.User.Password()
+		u.User = nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "uxperi")
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status code %d while posting to %s: %s", resp.StatusCode, u.Host, bytes.TrimSpace(msg))
+	}
+
+	return nil
+}
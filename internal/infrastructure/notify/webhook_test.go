@@ -0,0 +1,136 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// capture records the last request posted to the server, and answers with the status
+type capture struct {
+	status   int
+	header   http.Header
+	username string
+	password string
+	body     []byte
+}
+
+func (c *capture) server(t *testing.T) *httptest.Server {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("method = %s, want POST", r.Method)
+		}
+		c.header = r.Header
+		c.username, c.password, _ = r.BasicAuth()
+		c.body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(c.status)
+		if c.status/100 != 2 {
+			io.WriteString(w, "rejected\n")
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+var failing = Notification{
+	State:       StateFailing,
+	Time:        time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC),
+	Feature:     "login.feature",
+	Module:      "default",
+	Target:      "https://app.example.com",
+	Scenario:    "Login",
+	Failures:    3,
+	Step:        "IShouldBeRedirectedToTheDashboardPage",
+	Error:       "timeout",
+	RunId:       "run1",
+	RunURL:      "http://uxperi:9090/history?id=run1",
+	SnapshotURL: "http://uxperi:9090/history/artifacts?id=run1&name=shot.png",
+}
+
+func TestWebhookPayload(t *testing.T) {
+
+	c := &capture{status: http.StatusNoContent}
+	srv := c.server(t)
+	w, err := NewWebhook(strings.Replace(srv.URL, "http://", "http://alice:secret@", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Notify(context.Background(), failing); err != nil {
+		t.Fatal(err)
+	}
+	if c.header.Get("Content-Type") != "application/json" {
+		t.Errorf("content type = %s", c.header.Get("Content-Type"))
+	}
+	if c.username != "alice" || c.password != "secret" {
+		t.Errorf("basic auth = %s:%s, want alice:secret", c.username, c.password)
+	}
+	var got map[string]interface{}
+	if err = json.Unmarshal(c.body, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"state":        "failing",
+		"time":         "2023-05-01T12:00:00Z",
+		"feature":      "login.feature",
+		"module":       "default",
+		"target":       "https://app.example.com",
+		"scenario":     "Login",
+		"failures":     float64(3),
+		"step":         "IShouldBeRedirectedToTheDashboardPage",
+		"error":        "timeout",
+		"run_id":       "run1",
+		"run_url":      "http://uxperi:9090/history?id=run1",
+		"snapshot_url": "http://uxperi:9090/history/artifacts?id=run1&name=shot.png",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %v, want %v", k, got[k], v)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("payload = %s", c.body)
+	}
+
+	// The empty fields of a recovery are omitted
+	recovered := Notification{State: StateRecovered, Feature: "login.feature", Scenario: "Login", RunId: "run2"}
+	if err = w.Notify(context.Background(), recovered); err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	if err = json.Unmarshal(c.body, &got); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"failures", "step", "error", "run_url", "snapshot_url"} {
+		if _, ok := got[k]; ok {
+			t.Errorf("%s is sent with the recovery", k)
+		}
+	}
+	if got["state"] != "recovered" {
+		t.Errorf("state = %v, want recovered", got["state"])
+	}
+}
+
+func TestWebhookErrors(t *testing.T) {
+
+	c := &capture{status: http.StatusBadGateway}
+	srv := c.server(t)
+	w, err := NewWebhook(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Notify(context.Background(), failing); err == nil || !strings.Contains(err.Error(), "502") || !strings.Contains(err.Error(), "rejected") {
+		t.Errorf("Notify = %v, want the status and the body", err)
+	}
+	for _, endpoint := range []string{"", "/hook", "hooks.example.com/hook"} {
+		if _, err := NewWebhook(endpoint); err == nil {
+			t.Errorf("NewWebhook(%q) succeeded", endpoint)
+		}
+	}
+}
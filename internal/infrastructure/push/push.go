@@ -0,0 +1,64 @@
+// Package push sends the metrics of the probe runs to the endpoints that can not scrape the exporter.
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"fry.org/cmo/cli/internal/infrastructure/push/pushgateway"
+	"fry.org/cmo/cli/internal/infrastructure/push/remotewrite"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/speijnik/go-errortree"
+)
+
+// DefaultJob is the job label of the pushed metrics when the URI does not set one
+const DefaultJob = "uxperi"
+
+// Pusher sends the gathered metrics, the grouping labels tell apart the metrics of every feature and target
+type Pusher interface {
+	Push(ctx context.Context, g prometheus.Gatherer, grouping map[string]string) error
+}
+
+// ParsePusher creates a pusher from an URI like
+//
+//	push:pushgateway?url=<url>[&job=<name>]
+//	push:remote-write?url=<url>[&job=<name>]
+//
+// The credentials of the basic auth are taken from the user info of the url.
+func ParsePusher(URI string) (Pusher, error) {
+	var p Pusher
+	var rcerror error
+
+	u, err := url.Parse(URI)
+	if err != nil {
+		return nil, errortree.Add(rcerror, "ParsePusher", err)
+	}
+	if u.Scheme != "push" {
+		return nil, errortree.Add(rcerror, "ParsePusher", fmt.Errorf("invalid scheme %s", URI))
+	}
+	q := u.Query()
+	endpoint := q.Get("url")
+	if endpoint == "" {
+		return nil, errortree.Add(rcerror, "ParsePusher", errors.New("missing url query argument"))
+	}
+	job := q.Get("job")
+	if job == "" {
+		job = DefaultJob
+	}
+	switch u.Opaque {
+	case "pushgateway":
+		if p, err = pushgateway.NewPushgateway(endpoint, job); err != nil {
+			return nil, errortree.Add(rcerror, "ParsePusher", err)
+		}
+	case "remote-write":
+		if p, err = remotewrite.NewRemoteWrite(endpoint, job); err != nil {
+			return nil, errortree.Add(rcerror, "ParsePusher", err)
+		}
+	default:
+		return nil, errortree.Add(rcerror, "ParsePusher", fmt.Errorf("unsupported pusher implementation %q", u.Opaque))
+	}
+
+	return p, nil
+}
@@ -0,0 +1,71 @@
+// Package pushgateway pushes the metrics to a Prometheus Pushgateway.
+package pushgateway
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"github.com/speijnik/go-errortree"
+)
+
+type Pushgateway struct {
+	url      string
+	job      string
+	username string
+	password string
+	client   *http.Client
+}
+
+// NewPushgateway pushes to the Pushgateway at the url, the user info of the url is used for the basic auth
+func NewPushgateway(endpoint string, job string) (*Pushgateway, error) {
+	var rcerror error
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, errortree.Add(rcerror, "NewPushgateway", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, errortree.Add(rcerror, "NewPushgateway", errors.New("the url is not absolute"))
+	}
+	p := &Pushgateway{
+		job:    job,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+	if u.User != nil {
+		p.username = u.User.Username()
+		p.password, _ = u.User.Password()
+		u.User = nil
+	}
+	p.url = u.String()
+
+	return p, nil
+}
+
+// Push replaces the metrics of the group, so the metrics of a previous run do not linger
+func (p *Pushgateway) Push(ctx context.Context, g prometheus.Gatherer, grouping map[string]string) error {
+	var rcerror error
+
+	pusher := push.New(p.url, p.job).Gatherer(g).Client(p.client)
+	if p.username != "" {
+		pusher = pusher.BasicAuth(p.username, p.password)
+	}
+	names := make([]string, 0, len(grouping))
+	for name := range grouping {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pusher = pusher.Grouping(name, grouping[name])
+	}
+	if err := pusher.PushContext(ctx); err != nil {
+		return errortree.Add(rcerror, "Push", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package pushgateway
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+func TestPush(t *testing.T) {
+
+	var method, path, username, password string
+	var families []*dto.MetricFamily
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.EscapedPath()
+		username, password, _ = r.BasicAuth()
+		dec := expfmt.NewDecoder(r.Body, expfmt.ResponseFormat(r.Header))
+		for {
+			var f dto.MetricFamily
+			if err := dec.Decode(&f); err != nil {
+				if !errors.Is(err, io.EOF) {
+					t.Errorf("decode: %v", err)
+				}
+				break
+			}
+			families = append(families, &f)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	registry := prometheus.NewRegistry()
+	g := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "scenario_success"}, []string{"scenario_name"})
+	g.WithLabelValues("login").Set(1)
+	registry.MustRegister(g)
+
+	p, err := NewPushgateway(strings.Replace(srv.URL, "http://", "http://user:secret@", 1), "uxperi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Push(context.Background(), registry, map[string]string{
+		"feature": "loginPage",
+		"module":  "default",
+		"target":  "https://example.com/app",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if method != http.MethodPut {
+		t.Errorf("method = %s, want PUT", method)
+	}
+	// The values with a slash are base64 encoded in the path
+	target := base64.RawURLEncoding.EncodeToString([]byte("https://example.com/app"))
+	if want := "/metrics/job/uxperi/feature/loginPage/module/default/target@base64/" + target; path != want {
+		t.Errorf("path = %s, want %s", path, want)
+	}
+	if username != "user" || password != "secret" {
+		t.Errorf("basic auth = %s:%s, want user:secret", username, password)
+	}
+	if len(families) != 1 || families[0].GetName() != "scenario_success" {
+		t.Fatalf("families = %v, want scenario_success", families)
+	}
+	m := families[0].GetMetric()
+	if len(m) != 1 || m[0].GetGauge().GetValue() != 1 || m[0].GetLabel()[0].GetValue() != "login" {
+		t.Errorf("metrics = %v", m)
+	}
+}
+
+func TestPushError(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad grouping", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	p, err := NewPushgateway(srv.URL, "uxperi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Push(context.Background(), prometheus.NewRegistry(), map[string]string{"feature": "loginPage"}); err == nil {
+		t.Error("Push succeeded on a bad request")
+	}
+}
+
+func TestNewPushgateway(t *testing.T) {
+
+	for _, endpoint := range []string{"", "pushgateway:9091", "://"} {
+		if _, err := NewPushgateway(endpoint, "uxperi"); err == nil {
+			t.Errorf("NewPushgateway(%q) succeeded", endpoint)
+		}
+	}
+}
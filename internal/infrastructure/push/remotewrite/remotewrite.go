@@ -0,0 +1,192 @@
+// Package remotewrite sends the metrics with the Prometheus remote write protocol, version 1.0.
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/speijnik/go-errortree"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+type RemoteWrite struct {
+	url      string
+	job      string
+	username string
+	password string
+	client   *http.Client
+}
+
+type label struct {
+	name  string
+	value string
+}
+
+// series is a TimeSeries of the remote write protocol with a single sample
+type series struct {
+	labels    []label
+	value     float64
+	timestamp int64
+}
+
+// NewRemoteWrite sends to the remote write receiver at the url, the user info of the url is used for the basic auth
+func NewRemoteWrite(endpoint string, job string) (*RemoteWrite, error) {
+	var rcerror error
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, errortree.Add(rcerror, "NewRemoteWrite", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, errortree.Add(rcerror, "NewRemoteWrite", errors.New("the url is not absolute"))
+	}
+	w := &RemoteWrite{
+		job:    job,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+	if u.User != nil {
+		w.username = u.User.Username()
+		w.password, _ = u.User.Password()
+		u.User = nil
+	}
+	w.url = u.String()
+
+	return w, nil
+}
+
+// Push sends a sample of every gathered series, labelled with the job and the grouping labels
+func (w *RemoteWrite) Push(ctx context.Context, g prometheus.Gatherer, grouping map[string]string) error {
+	var rcerror error
+
+	families, err := g.Gather()
+	if err != nil {
+		return errortree.Add(rcerror, "Push", err)
+	}
+	common := []label{{name: "job", value: w.job}}
+	for name, value := range grouping {
+		common = append(common, label{name: name, value: value})
+	}
+	now := time.Now().UnixMilli()
+	var all []series
+	for _, f := range families {
+		all = append(all, toSeries(f, common, now)...)
+	}
+	if len(all) == 0 {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(snappy.Encode(nil, encode(all))))
+	if err != nil {
+		return errortree.Add(rcerror, "Push", err)
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "uxperi")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if w.username != "" {
+		req.SetBasicAuth(w.username, w.password)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return errortree.Add(rcerror, "Push", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return errortree.Add(rcerror, "Push", fmt.Errorf("unexpected status code %d while pushing to %s: %s", resp.StatusCode, w.url, bytes.TrimSpace(body)))
+	}
+
+	return nil
+}
+
+// toSeries flattens a metric family the way Prometheus stores it, e.g. a histogram into its
+// _bucket, _sum and _count series
+func toSeries(f *dto.MetricFamily, common []label, timestamp int64) []series {
+	var all []series
+
+	name := f.GetName()
+	for _, m := range f.GetMetric() {
+		labels := append([]label(nil), common...)
+		for _, l := range m.GetLabel() {
+			labels = append(labels, label{name: l.GetName(), value: l.GetValue()})
+		}
+		add := func(suffix string, value float64, extra ...label) {
+			ls := append(append([]label{{name: "__name__", value: name + suffix}}, labels...), extra...)
+			all = append(all, series{labels: ls, value: value, timestamp: timestamp})
+		}
+		switch f.GetType() {
+		case dto.MetricType_COUNTER:
+			add("", m.GetCounter().GetValue())
+		case dto.MetricType_GAUGE:
+			add("", m.GetGauge().GetValue())
+		case dto.MetricType_UNTYPED:
+			add("", m.GetUntyped().GetValue())
+		case dto.MetricType_SUMMARY:
+			s := m.GetSummary()
+			for _, q := range s.GetQuantile() {
+				add("", q.GetValue(), label{name: "quantile", value: formatFloat(q.GetQuantile())})
+			}
+			add("_sum", s.GetSampleSum())
+			add("_count", float64(s.GetSampleCount()))
+		case dto.MetricType_HISTOGRAM:
+			h := m.GetHistogram()
+			for _, b := range h.GetBucket() {
+				add("_bucket", float64(b.GetCumulativeCount()), label{name: "le", value: formatFloat(b.GetUpperBound())})
+			}
+			if n := len(h.GetBucket()); n == 0 || !math.IsInf(h.GetBucket()[n-1].GetUpperBound(), 1) {
+				add("_bucket", float64(h.GetSampleCount()), label{name: "le", value: "+Inf"})
+			}
+			add("_sum", h.GetSampleSum())
+			add("_count", float64(h.GetSampleCount()))
+		}
+	}
+
+	return all
+}
+
+func formatFloat(f float64) string {
+
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// encode writes the WriteRequest protobuf message, the labels of every series sorted by name
+func encode(all []series) []byte {
+	var b []byte
+
+	for _, s := range all {
+		sort.Slice(s.labels, func(i, j int) bool { return s.labels[i].name < s.labels[j].name })
+		var ts []byte
+		for _, l := range s.labels {
+			var lb []byte
+			lb = protowire.AppendTag(lb, 1, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.name)
+			lb = protowire.AppendTag(lb, 2, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.value)
+			ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, lb)
+		}
+		var sb []byte
+		sb = protowire.AppendTag(sb, 1, protowire.Fixed64Type)
+		sb = protowire.AppendFixed64(sb, math.Float64bits(s.value))
+		sb = protowire.AppendTag(sb, 2, protowire.VarintType)
+		sb = protowire.AppendVarint(sb, uint64(s.timestamp))
+		ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, sb)
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, ts)
+	}
+
+	return b
+}
@@ -0,0 +1,192 @@
+package remotewrite
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// decoded is a TimeSeries read back from the WriteRequest, the labels joined as name=value
+type decoded struct {
+	labels    []string
+	value     float64
+	timestamp int64
+}
+
+// fields calls fn with every field of the protobuf message
+func fields(t *testing.T, b []byte, fn func(num protowire.Number, typ protowire.Type, v []byte, n uint64)) {
+
+	for len(b) > 0 {
+		num, typ, l := protowire.ConsumeTag(b)
+		if l < 0 {
+			t.Fatalf("invalid tag: %v", protowire.ParseError(l))
+		}
+		b = b[l:]
+		switch typ {
+		case protowire.BytesType:
+			v, l := protowire.ConsumeBytes(b)
+			if l < 0 {
+				t.Fatalf("invalid bytes: %v", protowire.ParseError(l))
+			}
+			fn(num, typ, v, 0)
+			b = b[l:]
+		case protowire.VarintType:
+			v, l := protowire.ConsumeVarint(b)
+			if l < 0 {
+				t.Fatalf("invalid varint: %v", protowire.ParseError(l))
+			}
+			fn(num, typ, nil, v)
+			b = b[l:]
+		case protowire.Fixed64Type:
+			v, l := protowire.ConsumeFixed64(b)
+			if l < 0 {
+				t.Fatalf("invalid fixed64: %v", protowire.ParseError(l))
+			}
+			fn(num, typ, nil, v)
+			b = b[l:]
+		default:
+			t.Fatalf("unexpected wire type %d", typ)
+		}
+	}
+}
+
+func decodeWriteRequest(t *testing.T, b []byte) []decoded {
+	var all []decoded
+
+	fields(t, b, func(num protowire.Number, _ protowire.Type, ts []byte, _ uint64) {
+		if num != 1 {
+			t.Fatalf("unexpected WriteRequest field %d", num)
+		}
+		var s decoded
+		fields(t, ts, func(num protowire.Number, _ protowire.Type, v []byte, _ uint64) {
+			switch num {
+			case 1:
+				var name, value string
+				fields(t, v, func(num protowire.Number, _ protowire.Type, v []byte, _ uint64) {
+					if num == 1 {
+						name = string(v)
+					} else {
+						value = string(v)
+					}
+				})
+				s.labels = append(s.labels, name+"="+value)
+			case 2:
+				fields(t, v, func(num protowire.Number, _ protowire.Type, _ []byte, n uint64) {
+					if num == 1 {
+						s.value = math.Float64frombits(n)
+					} else {
+						s.timestamp = int64(n)
+					}
+				})
+			}
+		})
+		all = append(all, s)
+	})
+
+	return all
+}
+
+func TestPush(t *testing.T) {
+
+	var body []byte
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		compressed, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		if body, err = snappy.Decode(nil, compressed); err != nil {
+			t.Errorf("snappy: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	registry := prometheus.NewRegistry()
+	g := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "scenario_success"}, []string{"scenario_name"})
+	g.WithLabelValues("login").Set(1)
+	h := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "step_duration_seconds", Buckets: []float64{1}})
+	h.Observe(0.5)
+	registry.MustRegister(g, h)
+
+	w, err := NewRemoteWrite(strings.Replace(srv.URL, "http://", "http://user:secret@", 1), "uxperi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Push(context.Background(), registry, map[string]string{
+		"feature": "loginPage",
+		"module":  "default",
+		"target":  "https://example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Get("Content-Encoding") != "snappy" || header.Get("Content-Type") != "application/x-protobuf" {
+		t.Errorf("headers = %v", header)
+	}
+	if header.Get("X-Prometheus-Remote-Write-Version") != "0.1.0" {
+		t.Errorf("version = %q, want 0.1.0", header.Get("X-Prometheus-Remote-Write-Version"))
+	}
+	if u, p, _ := (&http.Request{Header: header}).BasicAuth(); u != "user" || p != "secret" {
+		t.Errorf("basic auth = %s:%s, want user:secret", u, p)
+	}
+
+	want := map[string]float64{
+		"__name__=scenario_success feature=loginPage job=uxperi module=default scenario_name=login target=https://example.com": 1,
+		"__name__=step_duration_seconds_bucket feature=loginPage job=uxperi le=1 module=default target=https://example.com":    1,
+		"__name__=step_duration_seconds_bucket feature=loginPage job=uxperi le=+Inf module=default target=https://example.com": 1,
+		"__name__=step_duration_seconds_sum feature=loginPage job=uxperi module=default target=https://example.com":            0.5,
+		"__name__=step_duration_seconds_count feature=loginPage job=uxperi module=default target=https://example.com":          1,
+	}
+	got := decodeWriteRequest(t, body)
+	if len(got) != len(want) {
+		t.Errorf("got %d series, want %d", len(got), len(want))
+	}
+	for _, s := range got {
+		if !sort.StringsAreSorted(s.labels) {
+			t.Errorf("labels %v are not sorted by name", s.labels)
+		}
+		key := strings.Join(s.labels, " ")
+		v, ok := want[key]
+		if !ok {
+			t.Errorf("unexpected series %s", key)
+			continue
+		}
+		if s.value != v {
+			t.Errorf("series %s = %g, want %g", key, s.value, v)
+		}
+		if s.timestamp <= 0 {
+			t.Errorf("series %s has no timestamp", key)
+		}
+	}
+}
+
+func TestPushError(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "out of order sample", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	registry := prometheus.NewRegistry()
+	g := prometheus.NewGauge(prometheus.GaugeOpts{Name: "scenario_success"})
+	registry.MustRegister(g)
+	w, err := NewRemoteWrite(srv.URL, "uxperi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Push(context.Background(), registry, nil)
+	if err == nil || !strings.Contains(err.Error(), "out of order sample") {
+		t.Errorf("Push = %v, want the error of the receiver", err)
+	}
+}
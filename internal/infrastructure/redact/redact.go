@@ -0,0 +1,196 @@
+// Package redact masks the secrets and the sensitive patterns in the text kept or shown by the exporter.
+package redact
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mask replaces the redacted text
+const Mask = "[REDACTED]"
+
+// minSecretLength skips the values so short that masking them would garble the whole text
+const minSecretLength = 4
+
+// SensitiveParams are the URL parameters carrying the OAuth2 codes and tokens
+var SensitiveParams = []string{"code", "access_token", "id_token", "refresh_token", "client_secret", "password"}
+
+// DefaultPatterns mask the bearer tokens, the JWTs and the OAuth2 codes and tokens sent in URLs
+var DefaultPatterns = []string{
+	`(?i)\bbearer\s+([A-Za-z0-9._~+/-]+=*)`,
+	`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`,
+	`(?i)[?&#](?:` + strings.Join(SensitiveParams, "|") + `)=([^&#\s"']+)`,
+}
+
+// Redactor masks the registered secrets and the text matching the registered patterns
+type Redactor struct {
+	mutex    sync.RWMutex
+	secrets  map[string]struct{}
+	replacer *strings.Replacer
+	patterns []*regexp.Regexp
+}
+
+// Default is the redactor shared by the whole process, it masks the default patterns
+var Default = newDefault()
+
+func New() *Redactor {
+
+	return &Redactor{
+		secrets:  make(map[string]struct{}),
+		replacer: strings.NewReplacer(),
+	}
+}
+
+func newDefault() *Redactor {
+
+	r := New()
+	for _, p := range DefaultPatterns {
+		r.patterns = append(r.patterns, regexp.MustCompile(p))
+	}
+
+	return r
+}
+
+// AddSecret masks the values from now on
+func (r *Redactor) AddSecret(values ...string) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	added := false
+	for _, v := range values {
+		if len(v) < minSecretLength {
+			continue
+		}
+		if _, ok := r.secrets[v]; !ok {
+			r.secrets[v] = struct{}{}
+			added = true
+		}
+	}
+	if !added {
+		return
+	}
+	secrets := make([]string, 0, len(r.secrets))
+	for s := range r.secrets {
+		secrets = append(secrets, s)
+	}
+	// Longest first, so a secret containing another one is masked as a whole
+	sort.Slice(secrets, func(i, j int) bool {
+		return len(secrets[i]) > len(secrets[j])
+	})
+	pairs := make([]string, 0, 2*len(secrets))
+	for _, s := range secrets {
+		pairs = append(pairs, s, Mask)
+	}
+	r.replacer = strings.NewReplacer(pairs...)
+}
+
+// AddPattern masks the text matching the regular expression from now on. When the expression has
+// groups only the text they capture is masked, otherwise the whole match.
+func (r *Redactor) AddPattern(expr string) error {
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.patterns = append(r.patterns, re)
+
+	return nil
+}
+
+// String returns the text with the secrets and the patterns masked
+func (r *Redactor) String(s string) string {
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	s = r.replacer.Replace(s)
+	for _, re := range r.patterns {
+		s = maskPattern(re, s)
+	}
+
+	return s
+}
+
+// Param returns the value of the URL parameter, masked as a whole for the sensitive parameters. The
+// patterns matching in URLs do not match the values once split from their names, e.g. in a HAR.
+func (r *Redactor) Param(name string, value string) string {
+
+	for _, p := range SensitiveParams {
+		if strings.EqualFold(name, p) {
+			return Mask
+		}
+	}
+
+	return r.String(value)
+}
+
+// Bytes returns the content with the secrets and the patterns masked. The content must not be
+// encoded, e.g. the escapes of JSON hide the text from the patterns and the secrets.
+func (r *Redactor) Bytes(b []byte) []byte {
+
+	return []byte(r.String(string(b)))
+}
+
+func maskPattern(re *regexp.Regexp, s string) string {
+
+	matches := re.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+	var sb strings.Builder
+	last := 0
+	for _, m := range matches {
+		spans := [][2]int{{m[0], m[1]}}
+		if len(m) > 2 {
+			spans = spans[:0]
+			for i := 2; i+1 < len(m); i += 2 {
+				if m[i] >= 0 && m[i] >= last {
+					spans = append(spans, [2]int{m[i], m[i+1]})
+				}
+			}
+		}
+		for _, sp := range spans {
+			sb.WriteString(s[last:sp[0]])
+			sb.WriteString(Mask)
+			last = sp[1]
+		}
+	}
+	sb.WriteString(s[last:])
+
+	return sb.String()
+}
+
+// AddSecret masks the values in the default redactor
+func AddSecret(values ...string) {
+
+	Default.AddSecret(values...)
+}
+
+// AddPattern adds the regular expression to the default redactor
+func AddPattern(expr string) error {
+
+	return Default.AddPattern(expr)
+}
+
+// String masks the text with the default redactor
+func String(s string) string {
+
+	return Default.String(s)
+}
+
+// Param masks the value of the URL parameter with the default redactor
+func Param(name string, value string) string {
+
+	return Default.Param(name, value)
+}
+
+// Bytes masks the content with the default redactor
+func Bytes(b []byte) []byte {
+
+	return Default.Bytes(b)
+}
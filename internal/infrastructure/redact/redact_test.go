@@ -0,0 +1,130 @@
+package redact
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRedactorSecrets(t *testing.T) {
+
+	r := New()
+	r.AddSecret("s3cr3t", "s3cr3t-and-more", "abc", "p&ss<w\"rd")
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "password is s3cr3t.", "password is " + Mask + "."},
+		{"longest first", "token s3cr3t-and-more", "token " + Mask},
+		{"too short", "abc is kept", "abc is kept"},
+		{"special characters", `login with p&ss<w"rd`, "login with " + Mask},
+		{"nothing", "nothing to mask", "nothing to mask"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.String(tt.in); got != tt.want {
+				t.Errorf("String(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactorPatterns(t *testing.T) {
+
+	r := newDefault()
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"bearer", "Authorization: Bearer abc.def-123", "Authorization: Bearer " + Mask},
+		{"jwt", "id eyJhbGciOi.eyJzdWIiOi.c2lnbmF0dXJl end", "id " + Mask + " end"},
+		{"code in query", "https://app/cb?state=xyz&code=0.AXk&session=1", "https://app/cb?state=xyz&code=" + Mask + "&session=1"},
+		{"token in fragment", "https://app/#access_token=abc&token_type=Bearer", "https://app/#access_token=" + Mask + "&token_type=Bearer"},
+		{"case insensitive", "https://app/?Client_Secret=xyz", "https://app/?Client_Secret=" + Mask},
+		{"other parameter", "https://app/?coder=xyz", "https://app/?coder=xyz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.String(tt.in); got != tt.want {
+				t.Errorf("String(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactorAddPattern(t *testing.T) {
+
+	r := New()
+	if err := r.AddPattern(`(`); err == nil {
+		t.Error("AddPattern accepted an invalid expression")
+	}
+	if err := r.AddPattern(`user=(\w+)`); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.AddPattern(`\d{4}-\d{4}`); err != nil {
+		t.Fatal(err)
+	}
+	in := "user=alice card 1234-5678"
+	want := "user=" + Mask + " card " + Mask
+	if got := r.String(in); got != want {
+		t.Errorf("String(%q) = %q, want %q", in, got, want)
+	}
+}
+
+func TestRedactorParam(t *testing.T) {
+
+	r := newDefault()
+	r.AddSecret("s3cr3t")
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"code", "0.AXkAbc", Mask},
+		{"ID_TOKEN", "abc", Mask},
+		{"state", "xyz", "xyz"},
+		{"login_hint", "user s3cr3t", "user " + Mask},
+	}
+	for _, tt := range tests {
+		if got := r.Param(tt.name, tt.value); got != tt.want {
+			t.Errorf("Param(%q, %q) = %q, want %q", tt.name, tt.value, got, tt.want)
+		}
+	}
+}
+
+// TestRedactorJSON checks the text is masked before being encoded, the escapes of JSON hide the
+// separators of the URL parameters and the characters of the secrets from the redactor
+func TestRedactorJSON(t *testing.T) {
+
+	r := newDefault()
+	r.AddSecret("p&ss<w\"rd")
+	entry := struct {
+		URL   string `json:"url"`
+		Value string `json:"value"`
+	}{
+		URL:   "https://app/cb?state=xyz&code=0.AXk",
+		Value: "p&ss<w\"rd",
+	}
+
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if masked := string(r.Bytes(encoded)); !strings.Contains(masked, "0.AXk") {
+		t.Fatalf("the encoded URL is expected to escape the redactor, got %s", masked)
+	}
+
+	entry.URL = r.String(entry.URL)
+	entry.Value = r.String(entry.Value)
+	encoded, err = json.Marshal(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"0.AXk", `p&ss`} {
+		if strings.Contains(string(encoded), secret) {
+			t.Errorf("%s leaked in %s", secret, encoded)
+		}
+	}
+}
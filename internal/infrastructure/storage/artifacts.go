@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+
+	"fry.org/cmo/cli/internal/application/artifacts"
+	"fry.org/cmo/cli/internal/infrastructure/storage/artifacts/local"
+	"fry.org/cmo/cli/internal/infrastructure/storage/artifacts/s3"
+	"github.com/speijnik/go-errortree"
+)
+
+// ParseArtifactStore creates an artifact store from an URI like
+//
+//	artifacts:local?path=<folder>[&max-size=<bytes>]
+//	artifacts:s3?endpoint=<url>&bucket=<name>[&region=<region>][&prefix=<prefix>][&path-style=<bool>]
+//
+// The S3 credentials are read from the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN envars.
+func ParseArtifactStore(URI string) (artifacts.ArtifactStore, error) {
+	var s artifacts.ArtifactStore
+	var rcerror error
+
+	u, err := url.Parse(URI)
+	if err != nil {
+		return nil, errortree.Add(rcerror, "ParseArtifactStore", err)
+	}
+	if u.Scheme != "artifacts" {
+		return nil, errortree.Add(rcerror, "ParseArtifactStore", fmt.Errorf("invalid scheme %s", URI))
+	}
+	q := u.Query()
+	switch u.Opaque {
+	case "local":
+		folder := q.Get("path")
+		if folder == "" {
+			return nil, errortree.Add(rcerror, "ParseArtifactStore", errors.New("missing path query argument"))
+		}
+		opts := []local.LocalStoreOption{
+			local.WithLocalStoreKeep(artifacts.BaselinesPrefix),
+		}
+		if v := q.Get("max-size"); v != "" {
+			size, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, errortree.Add(rcerror, "ParseArtifactStore", fmt.Errorf("invalid max-size %q", v))
+			}
+			opts = append(opts, local.WithLocalStoreMaxSize(size))
+		}
+		if s, err = local.NewLocalStore(folder, opts...); err != nil {
+			return nil, errortree.Add(rcerror, "ParseArtifactStore", err)
+		}
+	case "s3":
+		opts := []s3.S3StoreOption{
+			s3.WithS3StoreRegion(q.Get("region")),
+			s3.WithS3StorePrefix(q.Get("prefix")),
+			s3.WithS3StoreCredentials(os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_SESSION_TOKEN")),
+		}
+		if v := q.Get("path-style"); v != "" {
+			pathStyle, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, errortree.Add(rcerror, "ParseArtifactStore", fmt.Errorf("invalid path-style %q", v))
+			}
+			opts = append(opts, s3.WithS3StorePathStyle(pathStyle))
+		}
+		if s, err = s3.NewS3Store(q.Get("endpoint"), q.Get("bucket"), opts...); err != nil {
+			return nil, errortree.Add(rcerror, "ParseArtifactStore", err)
+		}
+	default:
+		return nil, errortree.Add(rcerror, "ParseArtifactStore", fmt.Errorf("unsupported artifact store implementation %q", u.Opaque))
+	}
+
+	return s, nil
+}
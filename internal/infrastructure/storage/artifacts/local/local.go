@@ -0,0 +1,280 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"fry.org/cmo/cli/internal/application/artifacts"
+	"github.com/speijnik/go-errortree"
+)
+
+// An LocalStoreOption applies optional changes to the local store.
+type LocalStoreOption interface {
+	Apply(l *LocalStore) error
+}
+
+// LocalStoreOptionFunc is function that adheres to the Option interface.
+type LocalStoreOptionFunc func(l *LocalStore) error
+
+func (o LocalStoreOptionFunc) Apply(l *LocalStore) error {
+
+	return o(l)
+}
+
+// LocalStore keeps the artifacts in a local directory
+type LocalStore struct {
+	mutex   sync.Mutex
+	root    string
+	maxSize int64
+	// size is the total size of the artifacts subject to the cap, the kept ones are left out
+	size int64
+	keep []string
+}
+
+// NewLocalStore creates the root directory, if needed, and accounts for the artifacts already stored in it
+func NewLocalStore(root string, opts ...LocalStoreOption) (*LocalStore, error) {
+	var rcerror error
+
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, errortree.Add(rcerror, "NewLocalStore", err)
+	}
+	l := LocalStore{
+		root: abs,
+	}
+	// Loop through each option
+	for _, option := range opts {
+		if err := option.Apply(&l); err != nil {
+			return nil, errortree.Add(rcerror, "NewLocalStore", err)
+		}
+	}
+	if err := os.MkdirAll(l.root, 0755); err != nil {
+		return nil, errortree.Add(rcerror, "NewLocalStore", err)
+	}
+	if files, err := l.files(); err != nil {
+		return nil, errortree.Add(rcerror, "NewLocalStore", err)
+	} else {
+		for _, f := range files {
+			if !l.kept(f.path) {
+				l.size += f.size
+			}
+		}
+	}
+
+	return &l, nil
+}
+
+// WithLocalStoreMaxSize caps the total size in bytes of the stored artifacts, 0 means unlimited
+func WithLocalStoreMaxSize(size int64) LocalStoreOption {
+
+	return LocalStoreOptionFunc(func(l *LocalStore) error {
+		var rcerror error
+
+		if size < 0 {
+			return errortree.Add(rcerror, "WithLocalStoreMaxSize", fmt.Errorf("invalid size %d", size))
+		}
+		l.maxSize = size
+
+		return nil
+	})
+}
+
+// WithLocalStoreKeep excludes the artifacts under the given key prefixes from the size cap, they
+// neither count toward it nor are evicted
+func WithLocalStoreKeep(prefixes ...string) LocalStoreOption {
+
+	return LocalStoreOptionFunc(func(l *LocalStore) error {
+
+		l.keep = append(l.keep, prefixes...)
+
+		return nil
+	})
+}
+
+func (l *LocalStore) kept(p string) bool {
+
+	for _, prefix := range l.keep {
+		if strings.HasPrefix(p, filepath.Join(l.root, filepath.FromSlash(prefix))+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (l *LocalStore) path(key string) (string, error) {
+	var rcerror error
+
+	p := filepath.Join(l.root, filepath.FromSlash(key))
+	if key == "" || !strings.HasPrefix(p, l.root+string(filepath.Separator)) {
+		return "", errortree.Add(rcerror, "path", fmt.Errorf("invalid key %q", key))
+	}
+
+	return p, nil
+}
+
+func (l *LocalStore) Put(ctx context.Context, key string, contentType string, content []byte) error {
+	var rcerror error
+
+	p, err := l.path(key)
+	if err != nil {
+		return errortree.Add(rcerror, "LocalStore.Put", err)
+	}
+	size := int64(len(content))
+	kept := l.kept(p)
+	if l.maxSize > 0 && !kept && size > l.maxSize {
+		return errortree.Add(rcerror, "LocalStore.Put", fmt.Errorf("artifact %q exceeds the store capacity of %d bytes", key, l.maxSize))
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// The replaced artifact makes room for the new one, it is not evicted to make room for itself
+	var replaced int64
+	if info, err := os.Stat(p); err == nil {
+		replaced = info.Size()
+	}
+	if !kept {
+		if err = l.reclaim(size-replaced, p); err != nil {
+			return errortree.Add(rcerror, "LocalStore.Put", err)
+		}
+	}
+	if err = os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return errortree.Add(rcerror, "LocalStore.Put", err)
+	}
+	if err = os.WriteFile(p, content, 0644); err != nil {
+		return errortree.Add(rcerror, "LocalStore.Put", err)
+	}
+	if !kept {
+		l.size += size - replaced
+	}
+
+	return nil
+}
+
+func (l *LocalStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	var rcerror error
+
+	p, err := l.path(key)
+	if err != nil {
+		return nil, errortree.Add(rcerror, "LocalStore.Get", err)
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, artifacts.ErrNotFound
+	} else if err != nil {
+		return nil, errortree.Add(rcerror, "LocalStore.Get", err)
+	}
+
+	return f, nil
+}
+
+func (l *LocalStore) Delete(ctx context.Context, key string) error {
+	var rcerror error
+
+	p, err := l.path(key)
+	if err != nil {
+		return errortree.Add(rcerror, "LocalStore.Delete", err)
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.remove(p)
+}
+
+// remove deletes the file and its parent folders up to the root when they become empty
+func (l *LocalStore) remove(p string) error {
+	var rcerror error
+
+	info, err := os.Stat(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return errortree.Add(rcerror, "remove", err)
+	}
+	if err = os.Remove(p); err != nil {
+		return errortree.Add(rcerror, "remove", err)
+	}
+	if !l.kept(p) {
+		l.size -= info.Size()
+	}
+	for dir := filepath.Dir(p); dir != l.root && strings.HasPrefix(dir, l.root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+
+	return nil
+}
+
+type localFile struct {
+	path  string
+	size  int64
+	mtime int64
+}
+
+func (l *LocalStore) files() ([]localFile, error) {
+	var files []localFile
+
+	err := filepath.WalkDir(l.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, localFile{
+			path:  p,
+			size:  info.Size(),
+			mtime: info.ModTime().UnixNano(),
+		})
+		return nil
+	})
+
+	return files, err
+}
+
+// reclaim deletes the oldest artifacts but the replaced one until there is room for the given amount of bytes
+func (l *LocalStore) reclaim(size int64, replaced string) error {
+	var rcerror error
+
+	if l.maxSize == 0 || l.size+size <= l.maxSize {
+		return nil
+	}
+	files, err := l.files()
+	if err != nil {
+		return errortree.Add(rcerror, "reclaim", err)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].mtime < files[j].mtime
+	})
+	for _, f := range files {
+		if l.size+size <= l.maxSize {
+			break
+		}
+		if f.path == replaced || l.kept(f.path) {
+			continue
+		}
+		if err = l.remove(f.path); err != nil {
+			return errortree.Add(rcerror, "reclaim", err)
+		}
+	}
+	if l.size+size > l.maxSize {
+		return errortree.Add(rcerror, "reclaim", fmt.Errorf("no room left for %d bytes", size))
+	}
+
+	return nil
+}
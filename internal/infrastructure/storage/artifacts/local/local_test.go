@@ -0,0 +1,219 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"fry.org/cmo/cli/internal/application/artifacts"
+)
+
+func newTestStore(t *testing.T, opts ...LocalStoreOption) *LocalStore {
+
+	l, err := NewLocalStore(t.TempDir(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return l
+}
+
+// put stores the content and backdates it, so the eviction order does not depend on the file system clock
+func put(t *testing.T, l *LocalStore, key string, size int, age time.Duration) {
+
+	if err := l.Put(context.Background(), key, "text/plain", bytes.Repeat([]byte("x"), size)); err != nil {
+		t.Fatalf("Put(%q): %v", key, err)
+	}
+	mtime := time.Now().Add(-age)
+	if err := os.Chtimes(filepath.Join(l.root, filepath.FromSlash(key)), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func exists(l *LocalStore, key string) bool {
+
+	r, err := l.Get(context.Background(), key)
+	if err != nil {
+		return false
+	}
+	r.Close()
+
+	return true
+}
+
+// diskSize returns the size of the files actually stored and subject to the cap
+func diskSize(t *testing.T, l *LocalStore) int64 {
+	var size int64
+
+	files, err := l.files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if !l.kept(f.path) {
+			size += f.size
+		}
+	}
+
+	return size
+}
+
+func TestLocalStorePutGetDelete(t *testing.T) {
+
+	ctx := context.Background()
+	l := newTestStore(t)
+	if err := l.Put(ctx, "runs/1/snapshot.png", "image/png", []byte("content")); err != nil {
+		t.Fatal(err)
+	}
+	r, err := l.Get(ctx, "runs/1/snapshot.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(r)
+	r.Close()
+	if string(b) != "content" {
+		t.Errorf("Get = %q, want %q", b, "content")
+	}
+	if err = l.Delete(ctx, "runs/1/snapshot.png"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = l.Get(ctx, "runs/1/snapshot.png"); !errors.Is(err, artifacts.ErrNotFound) {
+		t.Errorf("Get after Delete = %v, want ErrNotFound", err)
+	}
+	if _, err = os.Stat(filepath.Join(l.root, "runs")); !os.IsNotExist(err) {
+		t.Errorf("the empty folders are not removed: %v", err)
+	}
+	if err = l.Delete(ctx, "runs/1/snapshot.png"); err != nil {
+		t.Errorf("Delete of a missing key = %v, want nil", err)
+	}
+}
+
+func TestLocalStoreInvalidKeys(t *testing.T) {
+
+	l := newTestStore(t)
+	for _, key := range []string{"", "../outside", "a/../../outside", "."} {
+		if err := l.Put(context.Background(), key, "text/plain", []byte("x")); err == nil {
+			t.Errorf("Put(%q) accepted a key out of the root", key)
+		}
+	}
+}
+
+func TestLocalStoreEvictsOldest(t *testing.T) {
+
+	l := newTestStore(t, WithLocalStoreMaxSize(30))
+	put(t, l, "a", 10, 3*time.Hour)
+	put(t, l, "b", 10, 2*time.Hour)
+	put(t, l, "c", 10, time.Hour)
+	put(t, l, "d", 15, 0)
+
+	for key, want := range map[string]bool{"a": false, "b": false, "c": true, "d": true} {
+		if got := exists(l, key); got != want {
+			t.Errorf("%s stored = %v, want %v", key, got, want)
+		}
+	}
+	if l.size != 25 || diskSize(t, l) != 25 {
+		t.Errorf("size = %d, %d on disk, want 25", l.size, diskSize(t, l))
+	}
+}
+
+func TestLocalStoreOverwrite(t *testing.T) {
+
+	l := newTestStore(t, WithLocalStoreMaxSize(30))
+	// The replaced artifact is the oldest one, it must not be evicted to make room for itself
+	put(t, l, "key", 15, 2*time.Hour)
+	put(t, l, "other", 10, time.Hour)
+	put(t, l, "key", 25, 0)
+
+	if exists(l, "other") || !exists(l, "key") {
+		t.Errorf("other stored = %v, key stored = %v, want other evicted", exists(l, "other"), exists(l, "key"))
+	}
+	if l.size != 25 || diskSize(t, l) != 25 {
+		t.Errorf("size = %d, %d on disk, want 25", l.size, diskSize(t, l))
+	}
+	for i := 0; i < 5; i++ {
+		put(t, l, "key", 20+i, 0)
+		if l.size != diskSize(t, l) {
+			t.Fatalf("size = %d, want %d on disk", l.size, diskSize(t, l))
+		}
+	}
+}
+
+func TestLocalStoreKeep(t *testing.T) {
+
+	l := newTestStore(t, WithLocalStoreMaxSize(30), WithLocalStoreKeep(artifacts.BaselinesPrefix))
+	put(t, l, artifacts.BaselineKey("default", "app.example.com", "login", "home"), 20, 3*time.Hour)
+	put(t, l, "runs/1/a.png", 10, 2*time.Hour)
+	put(t, l, "runs/2/b.png", 10, time.Hour)
+	put(t, l, "runs/3/c.png", 10, 0)
+
+	// The baseline does not count toward the cap, the run artifacts fill it
+	if !exists(l, artifacts.BaselineKey("default", "app.example.com", "login", "home")) || !exists(l, "runs/1/a.png") {
+		t.Error("the baseline and the run artifacts are expected to fit")
+	}
+	put(t, l, "runs/4/d.png", 10, 0)
+	if !exists(l, artifacts.BaselineKey("default", "app.example.com", "login", "home")) || exists(l, "runs/1/a.png") {
+		t.Error("the baseline is expected to be kept and the oldest run artifact evicted")
+	}
+	if l.size != 30 || l.size != diskSize(t, l) {
+		t.Errorf("size = %d, %d on disk, want 30", l.size, diskSize(t, l))
+	}
+}
+
+func TestLocalStoreKeepAboveCap(t *testing.T) {
+
+	l := newTestStore(t, WithLocalStoreMaxSize(30), WithLocalStoreKeep(artifacts.BaselinesPrefix))
+	// The baselines alone exceed the cap, the new screenshots and HARs are stored all the same
+	put(t, l, artifacts.BaselineKey("default", "app.example.com", "login", "home"), 40, time.Hour)
+	put(t, l, artifacts.BaselineKey("default", "app.example.com", "login", "menu"), 20, time.Hour)
+	put(t, l, "runs/1/a.png", 20, time.Hour)
+	put(t, l, "runs/1/a.har", 10, 0)
+	if l.size != 30 || l.size != diskSize(t, l) {
+		t.Errorf("size = %d, %d on disk, want 30", l.size, diskSize(t, l))
+	}
+	// Deleting a baseline leaves the size subject to the cap unchanged
+	if err := l.Delete(context.Background(), artifacts.BaselineKey("default", "app.example.com", "login", "home")); err != nil {
+		t.Fatal(err)
+	}
+	if l.size != 30 {
+		t.Errorf("size after the baseline deletion = %d, want 30", l.size)
+	}
+	// The stored baselines are left out when the store is opened again
+	reopened, err := NewLocalStore(l.root, WithLocalStoreMaxSize(30), WithLocalStoreKeep(artifacts.BaselinesPrefix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.size != 30 {
+		t.Errorf("size of the reopened store = %d, want 30", reopened.size)
+	}
+}
+
+func TestLocalStoreTooLarge(t *testing.T) {
+
+	l := newTestStore(t, WithLocalStoreMaxSize(10))
+	if err := l.Put(context.Background(), "big", "text/plain", bytes.Repeat([]byte("x"), 11)); err == nil {
+		t.Error("Put accepted an artifact larger than the store")
+	}
+}
+
+func TestLocalStoreAccountsExisting(t *testing.T) {
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "existing"), bytes.Repeat([]byte("x"), 12), 0644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := NewLocalStore(root, WithLocalStoreMaxSize(20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.size != 12 {
+		t.Errorf("size = %d, want 12", l.size)
+	}
+	if _, err = NewLocalStore(root, WithLocalStoreMaxSize(-1)); err == nil {
+		t.Error("NewLocalStore accepted a negative size")
+	}
+}
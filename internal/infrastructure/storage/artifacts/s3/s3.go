@@ -0,0 +1,326 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"fry.org/cmo/cli/internal/application/artifacts"
+	"github.com/speijnik/go-errortree"
+)
+
+const (
+	signAlgorithm = "AWS4-HMAC-SHA256"
+	amzDateFormat = "20060102T150405Z"
+	emptyHash     = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// An S3StoreOption applies optional changes to the S3 store.
+type S3StoreOption interface {
+	Apply(s *S3Store) error
+}
+
+// S3StoreOptionFunc is function that adheres to the Option interface.
+type S3StoreOptionFunc func(s *S3Store) error
+
+func (o S3StoreOptionFunc) Apply(s *S3Store) error {
+
+	return o(s)
+}
+
+// S3Store keeps the artifacts in a bucket of an S3-compatible object storage, e.g. AWS S3 or MinIO
+type S3Store struct {
+	client       *http.Client
+	endpoint     *url.URL
+	bucket       string
+	region       string
+	prefix       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	pathStyle    bool
+	now          func() time.Time
+}
+
+// NewS3Store creates a store for the bucket served by the endpoint, e.g. https://s3.eu-west-1.amazonaws.com
+func NewS3Store(endpoint string, bucket string, opts ...S3StoreOption) (*S3Store, error) {
+	var rcerror error
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, errortree.Add(rcerror, "NewS3Store", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errortree.Add(rcerror, "NewS3Store", fmt.Errorf("invalid endpoint %q", endpoint))
+	}
+	if bucket == "" {
+		return nil, errortree.Add(rcerror, "NewS3Store", fmt.Errorf("missing bucket"))
+	}
+	s := S3Store{
+		client:    &http.Client{Timeout: 30 * time.Second},
+		endpoint:  u,
+		bucket:    bucket,
+		region:    "us-east-1",
+		pathStyle: true,
+		now:       time.Now,
+	}
+	// Loop through each option
+	for _, option := range opts {
+		if err := option.Apply(&s); err != nil {
+			return nil, errortree.Add(rcerror, "NewS3Store", err)
+		}
+	}
+
+	return &s, nil
+}
+
+func WithS3StoreRegion(region string) S3StoreOption {
+
+	return S3StoreOptionFunc(func(s *S3Store) error {
+
+		if region != "" {
+			s.region = region
+		}
+
+		return nil
+	})
+}
+
+// WithS3StorePrefix stores every artifact under the given key prefix
+func WithS3StorePrefix(prefix string) S3StoreOption {
+
+	return S3StoreOptionFunc(func(s *S3Store) error {
+
+		s.prefix = strings.Trim(prefix, "/")
+
+		return nil
+	})
+}
+
+func WithS3StoreCredentials(accessKey string, secretKey string, sessionToken string) S3StoreOption {
+
+	return S3StoreOptionFunc(func(s *S3Store) error {
+
+		s.accessKey = accessKey
+		s.secretKey = secretKey
+		s.sessionToken = sessionToken
+
+		return nil
+	})
+}
+
+// WithS3StorePathStyle selects path-style (endpoint/bucket/key) or virtual-hosted-style (bucket.endpoint/key) URLs
+func WithS3StorePathStyle(enabled bool) S3StoreOption {
+
+	return S3StoreOptionFunc(func(s *S3Store) error {
+
+		s.pathStyle = enabled
+
+		return nil
+	})
+}
+
+func (s *S3Store) objectURL(key string) (*url.URL, error) {
+	var rcerror error
+
+	key = strings.Trim(key, "/")
+	if key == "" {
+		return nil, errortree.Add(rcerror, "objectURL", fmt.Errorf("invalid key %q", key))
+	}
+	if s.prefix != "" {
+		key = s.prefix + "/" + key
+	}
+	u := *s.endpoint
+	if s.pathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.bucket + "/" + key
+	} else {
+		u.Host = s.bucket + "." + u.Host
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	}
+
+	return &u, nil
+}
+
+func (s *S3Store) do(ctx context.Context, method string, key string, contentType string, body []byte) (*http.Response, error) {
+	var rcerror error
+
+	u, err := s.objectURL(key)
+	if err != nil {
+		return nil, errortree.Add(rcerror, "do", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, errortree.Add(rcerror, "do", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.ContentLength = int64(len(body))
+	s.sign(req, body)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, errortree.Add(rcerror, "do", err)
+	}
+
+	return resp, nil
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, contentType string, content []byte) error {
+	var rcerror error
+
+	resp, err := s.do(ctx, http.MethodPut, key, contentType, content)
+	if err != nil {
+		return errortree.Add(rcerror, "S3Store.Put", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errortree.Add(rcerror, "S3Store.Put", responseError(resp))
+	}
+
+	return nil
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	var rcerror error
+
+	resp, err := s.do(ctx, http.MethodGet, key, "", nil)
+	if err != nil {
+		return nil, errortree.Add(rcerror, "S3Store.Get", err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, artifacts.ErrNotFound
+	default:
+		defer resp.Body.Close()
+		return nil, errortree.Add(rcerror, "S3Store.Get", responseError(resp))
+	}
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	var rcerror error
+
+	resp, err := s.do(ctx, http.MethodDelete, key, "", nil)
+	if err != nil {
+		return errortree.Add(rcerror, "S3Store.Delete", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return errortree.Add(rcerror, "S3Store.Delete", responseError(resp))
+	}
+
+	return nil
+}
+
+func responseError(resp *http.Response) error {
+
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(b)))
+}
+
+// sign adds the AWS Signature Version 4 headers to the request
+func (s *S3Store) sign(req *http.Request, body []byte) {
+
+	if s.accessKey == "" {
+		// anonymous access
+		return
+	}
+	now := s.now().UTC()
+	amzDate := now.Format(amzDateFormat)
+	payloadHash := emptyHash
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+
+	headers := map[string]string{
+		"host": req.URL.Host,
+	}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if strings.HasPrefix(lk, "x-amz-") || lk == "content-type" || lk == "range" {
+			headers[lk] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		encodePath(req.URL.Path),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	scope := strings.Join([]string{now.Format("20060102"), s.region, "s3", "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		signAlgorithm,
+		amzDate,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), now.Format("20060102"))
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signAlgorithm, s.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+
+	return h.Sum(nil)
+}
+
+// encodePath escapes every byte of the path but the unreserved characters and the slashes
+func encodePath(p string) string {
+	var b strings.Builder
+
+	if p == "" {
+		return "/"
+	}
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
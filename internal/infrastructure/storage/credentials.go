@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+
+	"fry.org/cmo/cli/internal/application/credentials"
+	"fry.org/cmo/cli/internal/infrastructure/storage/credentials/file"
+	"fry.org/cmo/cli/internal/infrastructure/storage/credentials/keystore"
+	"fry.org/cmo/cli/internal/infrastructure/storage/credentials/vault"
+	"github.com/speijnik/go-errortree"
+)
+
+// ParseCredentialStore creates a credential store from an URI like
+//
+//	credentials:file?path=<folder>
+//	credentials:keystore?path=<file>
+//	credentials:vault?[address=<url>][&mount=<path>][&prefix=<path>][&kv-version=<1|2>]
+//
+// The keystore is encrypted with a key derived from the passphrase. The Vault address, token and
+// namespace default to the VAULT_ADDR, VAULT_TOKEN and VAULT_NAMESPACE envars.
+func ParseCredentialStore(URI string, passphrase string) (credentials.CredentialStore, error) {
+	var s credentials.CredentialStore
+	var rcerror error
+
+	u, err := url.Parse(URI)
+	if err != nil {
+		return nil, errortree.Add(rcerror, "ParseCredentialStore", err)
+	}
+	if u.Scheme != "credentials" {
+		return nil, errortree.Add(rcerror, "ParseCredentialStore", fmt.Errorf("invalid scheme %s", URI))
+	}
+	q := u.Query()
+	switch u.Opaque {
+	case "file":
+		folder := q.Get("path")
+		if folder == "" {
+			return nil, errortree.Add(rcerror, "ParseCredentialStore", errors.New("missing path query argument"))
+		}
+		if s, err = file.NewFileStore(folder); err != nil {
+			return nil, errortree.Add(rcerror, "ParseCredentialStore", err)
+		}
+	case "keystore":
+		f := q.Get("path")
+		if f == "" {
+			return nil, errortree.Add(rcerror, "ParseCredentialStore", errors.New("missing path query argument"))
+		}
+		if s, err = keystore.NewKeystore(f, passphrase); err != nil {
+			return nil, errortree.Add(rcerror, "ParseCredentialStore", err)
+		}
+	case "vault":
+		address := q.Get("address")
+		if address == "" {
+			address = os.Getenv("VAULT_ADDR")
+		}
+		opts := []vault.VaultStoreOption{
+			vault.WithVaultStoreToken(os.Getenv("VAULT_TOKEN")),
+			vault.WithVaultStoreNamespace(os.Getenv("VAULT_NAMESPACE")),
+			vault.WithVaultStoreMount(q.Get("mount")),
+			vault.WithVaultStorePrefix(q.Get("prefix")),
+		}
+		if v := q.Get("kv-version"); v != "" {
+			version, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, errortree.Add(rcerror, "ParseCredentialStore", fmt.Errorf("invalid kv-version %q", v))
+			}
+			opts = append(opts, vault.WithVaultStoreKVVersion(version))
+		}
+		if s, err = vault.NewVaultStore(address, opts...); err != nil {
+			return nil, errortree.Add(rcerror, "ParseCredentialStore", err)
+		}
+	default:
+		return nil, errortree.Add(rcerror, "ParseCredentialStore", fmt.Errorf("unsupported credential store implementation %q", u.Opaque))
+	}
+
+	return s, nil
+}
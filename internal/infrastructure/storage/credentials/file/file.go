@@ -0,0 +1,80 @@
+// Package file reads the credentials from a folder laid out like the Kubernetes secret mounts,
+// one folder per credential with a file per field.
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"fry.org/cmo/cli/internal/application/credentials"
+	"github.com/speijnik/go-errortree"
+)
+
+var nameRe = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*$`)
+
+type FileStore struct {
+	root string
+}
+
+func NewFileStore(root string) (*FileStore, error) {
+	var rcerror error
+
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, errortree.Add(rcerror, "NewFileStore", err)
+	}
+	if info, err := os.Stat(abs); err != nil {
+		return nil, errortree.Add(rcerror, "NewFileStore", err)
+	} else if !info.IsDir() {
+		return nil, errortree.Add(rcerror, "NewFileStore", fmt.Errorf("%s is not a folder", abs))
+	}
+
+	return &FileStore{
+		root: abs,
+	}, nil
+}
+
+// Get reads <root>/<name>/username, password and the optional totp files. The files are read on
+// every call, so the rotated secrets are picked up without restarting.
+func (f *FileStore) Get(ctx context.Context, name string) (credentials.Credential, error) {
+	var rcerror error
+	var c credentials.Credential
+
+	if !nameRe.MatchString(name) {
+		return c, errortree.Add(rcerror, "Get", fmt.Errorf("invalid credential name %q", name))
+	}
+	dir := filepath.Join(f.root, name)
+	if _, err := os.Stat(dir); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return c, credentials.ErrNotFound
+		}
+		return c, errortree.Add(rcerror, "Get", err)
+	}
+	fields := []struct {
+		file     string
+		value    *string
+		optional bool
+	}{
+		{"username", &c.Username, false},
+		{"password", &c.Password, false},
+		{"totp", &c.TOTP, true},
+	}
+	for _, field := range fields {
+		b, err := os.ReadFile(filepath.Join(dir, field.file))
+		if err != nil {
+			if field.optional && errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return c, errortree.Add(rcerror, "Get", err)
+		}
+		// Secrets created with echo end with a new line
+		*field.value = strings.TrimRight(string(b), "\r\n")
+	}
+
+	return c, nil
+}
@@ -0,0 +1,78 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fry.org/cmo/cli/internal/application/credentials"
+)
+
+func writeSecret(t *testing.T, root string, name string, fields map[string]string) {
+
+	dir := filepath.Join(root, name)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range fields {
+		if err := os.WriteFile(filepath.Join(dir, k), []byte(v), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFileStoreGet(t *testing.T) {
+
+	root := t.TempDir()
+	writeSecret(t, root, "alice", map[string]string{"username": "alice\n", "password": "secret\r\n", "totp": "JBSWY3DPEHPK3PXP"})
+	writeSecret(t, root, "bob", map[string]string{"username": "bob", "password": "secret"})
+	writeSecret(t, root, "broken", map[string]string{"username": "broken"})
+	f, err := NewFileStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		want    credentials.Credential
+		wantErr bool
+	}{
+		{name: "alice", want: credentials.Credential{Username: "alice", Password: "secret", TOTP: "JBSWY3DPEHPK3PXP"}},
+		{name: "bob", want: credentials.Credential{Username: "bob", Password: "secret"}},
+		{name: "broken", wantErr: true},
+		{name: "../alice", wantErr: true},
+		{name: ".hidden", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := f.Get(context.Background(), tt.name)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Get(%q) succeeded", tt.name)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Get(%q) = %+v, %v, want %+v", tt.name, got, err, tt.want)
+		}
+	}
+	if _, err = f.Get(context.Background(), "nobody"); !errors.Is(err, credentials.ErrNotFound) {
+		t.Errorf("Get of a missing credential = %v, want ErrNotFound", err)
+	}
+}
+
+func TestNewFileStore(t *testing.T) {
+
+	root := t.TempDir()
+	if _, err := NewFileStore(filepath.Join(root, "missing")); err == nil {
+		t.Error("NewFileStore accepted a missing folder")
+	}
+	file := filepath.Join(root, "file")
+	if err := os.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileStore(file); err == nil {
+		t.Error("NewFileStore accepted a file")
+	}
+}
@@ -0,0 +1,175 @@
+// Package keystore keeps the credentials in a local file encrypted with AES-256-GCM, the key is
+// derived from the passphrase and a random salt stored in the header of the file.
+package keystore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"fry.org/cmo/cli/internal/application/credentials"
+	"fry.org/cmo/cli/internal/infrastructure/storage/sealed"
+	"github.com/speijnik/go-errortree"
+)
+
+// magic heads the keystore file, see sealed.Header
+const magic = "UXKS"
+
+var ad = []byte("uxperi-keystore")
+
+type Keystore struct {
+	mutex      sync.Mutex
+	file       string
+	passphrase string
+	// salt and box are the ones of the keystore file, nil until it is read or written
+	salt []byte
+	box  *sealed.Box
+}
+
+// NewKeystore opens the keystore file, which is created by the first Set
+func NewKeystore(file string, passphrase string) (*Keystore, error) {
+	var rcerror error
+
+	if passphrase == "" {
+		return nil, errortree.Add(rcerror, "NewKeystore", errors.New("empty passphrase"))
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, errortree.Add(rcerror, "NewKeystore", err)
+	}
+	k := &Keystore{
+		file:       abs,
+		passphrase: passphrase,
+	}
+	// Fail early on a wrong passphrase
+	if _, err = k.load(); err != nil {
+		return nil, errortree.Add(rcerror, "NewKeystore", err)
+	}
+
+	return k, nil
+}
+
+// additionalData authenticates the header along with the sealed content
+func additionalData(header []byte) []byte {
+
+	return append(append([]byte{}, ad...), header...)
+}
+
+// derive returns the box of the salt, the key is derived again only when the salt changes
+func (k *Keystore) derive(salt []byte) (*sealed.Box, error) {
+
+	if k.box != nil && bytes.Equal(k.salt, salt) {
+		return k.box, nil
+	}
+	box, err := sealed.NewBox(k.passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	k.salt = salt
+	k.box = box
+
+	return box, nil
+}
+
+func (k *Keystore) load() (map[string]credentials.Credential, error) {
+
+	creds := make(map[string]credentials.Credential)
+	content, err := os.ReadFile(k.file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return creds, nil
+		}
+		return nil, err
+	}
+	header, salt, err := sealed.ParseHeader(magic, content)
+	if err != nil {
+		return nil, err
+	}
+	box, err := k.derive(salt)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := box.Open(content[len(header):], additionalData(header))
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(plain, &creds); err != nil {
+		return nil, err
+	}
+
+	return creds, nil
+}
+
+func (k *Keystore) Get(ctx context.Context, name string) (credentials.Credential, error) {
+	var rcerror error
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	creds, err := k.load()
+	if err != nil {
+		return credentials.Credential{}, errortree.Add(rcerror, "Get", err)
+	}
+	c, ok := creds[name]
+	if !ok {
+		return c, credentials.ErrNotFound
+	}
+
+	return c, nil
+}
+
+func (k *Keystore) Set(ctx context.Context, name string, c credentials.Credential) error {
+	var rcerror error
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	creds, err := k.load()
+	if err != nil {
+		return errortree.Add(rcerror, "Set", err)
+	}
+	creds[name] = c
+	plain, err := json.Marshal(creds)
+	if err != nil {
+		return errortree.Add(rcerror, "Set", err)
+	}
+	// A new keystore gets a random salt
+	if k.box == nil {
+		salt, err := sealed.NewSalt()
+		if err != nil {
+			return errortree.Add(rcerror, "Set", err)
+		}
+		if _, err = k.derive(salt); err != nil {
+			return errortree.Add(rcerror, "Set", err)
+		}
+	}
+	h := sealed.Header(magic, k.salt)
+	content, err := k.box.Seal(plain, additionalData(h))
+	if err != nil {
+		return errortree.Add(rcerror, "Set", err)
+	}
+	content = append(h, content...)
+	if err = os.MkdirAll(filepath.Dir(k.file), 0700); err != nil {
+		return errortree.Add(rcerror, "Set", err)
+	}
+	// Write and rename, so the keystore is never left half written
+	tmp, err := os.CreateTemp(filepath.Dir(k.file), ".keystore-*")
+	if err != nil {
+		return errortree.Add(rcerror, "Set", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(content); err != nil {
+		tmp.Close()
+		return errortree.Add(rcerror, "Set", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return errortree.Add(rcerror, "Set", err)
+	}
+	if err = os.Rename(tmp.Name(), k.file); err != nil {
+		return errortree.Add(rcerror, "Set", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package keystore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fry.org/cmo/cli/internal/application/credentials"
+	"fry.org/cmo/cli/internal/infrastructure/storage/sealed"
+)
+
+var alice = credentials.Credential{
+	Username: "alice@example.com",
+	Password: "p&ss<w\"rd",
+	TOTP:     "JBSWY3DPEHPK3PXP",
+}
+
+func newTestKeystore(t *testing.T, file string, passphrase string) *Keystore {
+
+	k, err := NewKeystore(file, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return k
+}
+
+func TestKeystoreRoundTrip(t *testing.T) {
+
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "creds", "keystore")
+	k := newTestKeystore(t, file, "passphrase")
+	if _, err := k.Get(ctx, "alice"); !errors.Is(err, credentials.ErrNotFound) {
+		t.Errorf("Get on an empty keystore = %v, want ErrNotFound", err)
+	}
+	if err := k.Set(ctx, "alice", alice); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Set(ctx, "bob", credentials.Credential{Username: "bob", Password: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := newTestKeystore(t, file, "passphrase").Get(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != alice {
+		t.Errorf("Get = %+v, want %+v", got, alice)
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(content, []byte(alice.Username)) {
+		t.Error("the keystore is not encrypted")
+	}
+}
+
+func TestKeystoreWrongPassphrase(t *testing.T) {
+
+	file := filepath.Join(t.TempDir(), "keystore")
+	if err := newTestKeystore(t, file, "passphrase").Set(context.Background(), "alice", alice); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewKeystore(file, "wrong"); err == nil {
+		t.Error("NewKeystore opened the keystore with a wrong passphrase")
+	}
+	if _, err := NewKeystore(file, ""); err == nil {
+		t.Error("NewKeystore accepted an empty passphrase")
+	}
+}
+
+func TestKeystoreRandomSalt(t *testing.T) {
+
+	dir := t.TempDir()
+	var headers [][]byte
+	for _, name := range []string{"a", "b"} {
+		file := filepath.Join(dir, name)
+		if err := newTestKeystore(t, file, "passphrase").Set(context.Background(), "alice", alice); err != nil {
+			t.Fatal(err)
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(content, []byte{'U', 'X', 'K', 'S', sealed.Version}) {
+			t.Fatalf("missing header in %x", content[:sealed.HeaderSize])
+		}
+		headers = append(headers, content[:sealed.HeaderSize])
+	}
+	if bytes.Equal(headers[0], headers[1]) {
+		t.Error("two keystores share the same salt")
+	}
+}
+
+func TestKeystoreTamperedHeader(t *testing.T) {
+
+	file := filepath.Join(t.TempDir(), "keystore")
+	if err := newTestKeystore(t, file, "passphrase").Set(context.Background(), "alice", alice); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]func(b []byte) []byte{
+		"version": func(b []byte) []byte {
+			b[len(magic)] = sealed.Version + 1
+			return b
+		},
+		"salt": func(b []byte) []byte {
+			b[len(magic)+1] ^= 0xff
+			return b
+		},
+		"truncated": func(b []byte) []byte {
+			return b[:sealed.HeaderSize-1]
+		},
+	}
+	for name, tamper := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := os.WriteFile(file, tamper(append([]byte{}, content...)), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := NewKeystore(file, "passphrase"); err == nil {
+				t.Error("NewKeystore opened a tampered keystore")
+			}
+		})
+	}
+}
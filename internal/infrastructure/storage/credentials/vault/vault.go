@@ -0,0 +1,184 @@
+// Package vault reads the credentials from the key/value secrets engine of HashiCorp Vault.
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"fry.org/cmo/cli/internal/application/credentials"
+	"github.com/speijnik/go-errortree"
+)
+
+// An VaultStoreOption applies optional changes to the Vault store.
+type VaultStoreOption interface {
+	Apply(v *VaultStore) error
+}
+
+// VaultStoreOptionFunc is function that adheres to the Option interface.
+type VaultStoreOptionFunc func(v *VaultStore) error
+
+func (o VaultStoreOptionFunc) Apply(v *VaultStore) error {
+
+	return o(v)
+}
+
+type VaultStore struct {
+	address   string
+	token     string
+	namespace string
+	mount     string
+	prefix    string
+	version   int
+	client    *http.Client
+}
+
+func WithVaultStoreToken(token string) VaultStoreOption {
+
+	return VaultStoreOptionFunc(func(v *VaultStore) error {
+		v.token = token
+
+		return nil
+	})
+}
+
+func WithVaultStoreNamespace(namespace string) VaultStoreOption {
+
+	return VaultStoreOptionFunc(func(v *VaultStore) error {
+		v.namespace = namespace
+
+		return nil
+	})
+}
+
+// WithVaultStoreMount sets the path the secrets engine is mounted at, secret by default
+func WithVaultStoreMount(mount string) VaultStoreOption {
+
+	return VaultStoreOptionFunc(func(v *VaultStore) error {
+		if mount != "" {
+			v.mount = strings.Trim(mount, "/")
+		}
+
+		return nil
+	})
+}
+
+// WithVaultStorePrefix sets the path of the credentials inside the secrets engine
+func WithVaultStorePrefix(prefix string) VaultStoreOption {
+
+	return VaultStoreOptionFunc(func(v *VaultStore) error {
+		v.prefix = strings.Trim(prefix, "/")
+
+		return nil
+	})
+}
+
+// WithVaultStoreKVVersion sets the version, 1 or 2, of the key/value secrets engine
+func WithVaultStoreKVVersion(version int) VaultStoreOption {
+
+	return VaultStoreOptionFunc(func(v *VaultStore) error {
+		if version != 1 && version != 2 {
+			return fmt.Errorf("unsupported kv version %d", version)
+		}
+		v.version = version
+
+		return nil
+	})
+}
+
+func NewVaultStore(address string, opts ...VaultStoreOption) (*VaultStore, error) {
+	var rcerror error
+
+	if address == "" {
+		return nil, errortree.Add(rcerror, "NewVaultStore", errors.New("missing address"))
+	}
+	if _, err := url.Parse(address); err != nil {
+		return nil, errortree.Add(rcerror, "NewVaultStore", err)
+	}
+	v := &VaultStore{
+		address: strings.TrimRight(address, "/"),
+		mount:   "secret",
+		version: 2,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+	for _, opt := range opts {
+		if err := opt.Apply(v); err != nil {
+			return nil, errortree.Add(rcerror, "NewVaultStore", err)
+		}
+	}
+	if v.token == "" {
+		return nil, errortree.Add(rcerror, "NewVaultStore", errors.New("missing token"))
+	}
+
+	return v, nil
+}
+
+// Get reads the username, password and totp fields of the secret <prefix>/<name>
+func (v *VaultStore) Get(ctx context.Context, name string) (credentials.Credential, error) {
+	var rcerror error
+	var c credentials.Credential
+
+	if name == "" || strings.Contains(name, "..") {
+		return c, errortree.Add(rcerror, "Get", fmt.Errorf("invalid credential name %q", name))
+	}
+	p := path.Join(v.prefix, name)
+	if v.version == 2 {
+		p = path.Join(v.mount, "data", p)
+	} else {
+		p = path.Join(v.mount, p)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.address+"/v1/"+p, nil)
+	if err != nil {
+		return c, errortree.Add(rcerror, "Get", err)
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return c, errortree.Add(rcerror, "Get", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Errors []string        `json:"errors"`
+		Data   json.RawMessage `json:"data"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil && resp.StatusCode == http.StatusOK {
+		return c, errortree.Add(rcerror, "Get", err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return c, credentials.ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		return c, errortree.Add(rcerror, "Get", fmt.Errorf("vault returned %s: %s", resp.Status, strings.Join(body.Errors, ", ")))
+	}
+	data := body.Data
+	if v.version == 2 {
+		var kv struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err = json.Unmarshal(data, &kv); err != nil {
+			return c, errortree.Add(rcerror, "Get", err)
+		}
+		data = kv.Data
+	}
+	if err = json.Unmarshal(data, &c); err != nil {
+		return c, errortree.Add(rcerror, "Get", err)
+	}
+	// A deleted kv v2 secret still has a version, without data
+	if c.Username == "" && c.Password == "" {
+		return c, credentials.ErrNotFound
+	}
+
+	return c, nil
+}
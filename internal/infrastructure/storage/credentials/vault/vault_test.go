@@ -0,0 +1,160 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"fry.org/cmo/cli/internal/application/credentials"
+)
+
+// stubVault answers the reads of the secrets with the canned responses, keyed by path
+func stubVault(t *testing.T, responses map[string]struct {
+	status int
+	body   string
+}) *httptest.Server {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `{"errors":["permission denied"]}`)
+			return
+		}
+		if ns := r.Header.Get("X-Vault-Namespace"); ns != "" && ns != "team" {
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `{"errors":["unknown namespace"]}`)
+			return
+		}
+		resp, ok := responses[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"errors":[]}`)
+			return
+		}
+		w.WriteHeader(resp.status)
+		io.WriteString(w, resp.body)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+type response = struct {
+	status int
+	body   string
+}
+
+func TestVaultStoreKV(t *testing.T) {
+
+	srv := stubVault(t, map[string]response{
+		"/v1/secret/data/uxperi/alice": {http.StatusOK, `{"data":{"data":{"username":"alice","password":"secret","totp":"JBSWY3DPEHPK3PXP"},"metadata":{"version":3}}}`},
+		"/v1/kv/uxperi/alice":          {http.StatusOK, `{"data":{"username":"alice","password":"secret"}}`},
+		// A deleted kv v2 secret keeps its metadata, Vault answers 404 with a null data
+		"/v1/secret/data/uxperi/deleted": {http.StatusNotFound, `{"data":{"data":null,"metadata":{"deletion_time":"2023-05-01T00:00:00Z","version":2}}}`},
+		// A destroyed version may be answered with a null data too
+		"/v1/secret/data/uxperi/destroyed": {http.StatusOK, `{"data":{"data":null,"metadata":{"destroyed":true,"version":1}}}`},
+		"/v1/secret/data/uxperi/broken":    {http.StatusInternalServerError, `{"errors":["internal error"]}`},
+	})
+	tests := []struct {
+		name    string
+		opts    []VaultStoreOption
+		secret  string
+		want    credentials.Credential
+		wantErr error
+		errText string
+	}{
+		{
+			name:   "kv v2",
+			opts:   []VaultStoreOption{WithVaultStorePrefix("/uxperi/")},
+			secret: "alice",
+			want:   credentials.Credential{Username: "alice", Password: "secret", TOTP: "JBSWY3DPEHPK3PXP"},
+		},
+		{
+			name:   "kv v1",
+			opts:   []VaultStoreOption{WithVaultStoreMount("kv"), WithVaultStorePrefix("uxperi"), WithVaultStoreKVVersion(1)},
+			secret: "alice",
+			want:   credentials.Credential{Username: "alice", Password: "secret"},
+		},
+		{
+			name:   "namespace",
+			opts:   []VaultStoreOption{WithVaultStoreNamespace("team"), WithVaultStorePrefix("uxperi")},
+			secret: "alice",
+			want:   credentials.Credential{Username: "alice", Password: "secret", TOTP: "JBSWY3DPEHPK3PXP"},
+		},
+		{
+			name:    "not found",
+			opts:    []VaultStoreOption{WithVaultStorePrefix("uxperi")},
+			secret:  "nobody",
+			wantErr: credentials.ErrNotFound,
+		},
+		{
+			name:    "deleted",
+			opts:    []VaultStoreOption{WithVaultStorePrefix("uxperi")},
+			secret:  "deleted",
+			wantErr: credentials.ErrNotFound,
+		},
+		{
+			name:    "destroyed",
+			opts:    []VaultStoreOption{WithVaultStorePrefix("uxperi")},
+			secret:  "destroyed",
+			wantErr: credentials.ErrNotFound,
+		},
+		{
+			name:    "server error",
+			opts:    []VaultStoreOption{WithVaultStorePrefix("uxperi")},
+			secret:  "broken",
+			errText: "internal error",
+		},
+		{
+			name:    "forbidden",
+			opts:    []VaultStoreOption{WithVaultStoreToken("other"), WithVaultStorePrefix("uxperi")},
+			secret:  "alice",
+			errText: "permission denied",
+		},
+		{
+			name:    "invalid name",
+			secret:  "../sys/seal",
+			errText: "invalid credential name",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := NewVaultStore(srv.URL+"/", append([]VaultStoreOption{WithVaultStoreToken("token")}, tt.opts...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := v.Get(context.Background(), tt.secret)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Get = %v, want %v", err, tt.wantErr)
+				}
+			case tt.errText != "":
+				if err == nil || !strings.Contains(err.Error(), tt.errText) {
+					t.Errorf("Get = %v, want an error with %q", err, tt.errText)
+				}
+			case err != nil:
+				t.Errorf("Get = %v", err)
+			case got != tt.want:
+				t.Errorf("Get = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewVaultStore(t *testing.T) {
+
+	if _, err := NewVaultStore(""); err == nil {
+		t.Error("NewVaultStore accepted an empty address")
+	}
+	if _, err := NewVaultStore("http://vault:8200"); err == nil {
+		t.Error("NewVaultStore accepted a missing token")
+	}
+	if _, err := NewVaultStore("http://vault:8200", WithVaultStoreToken("token"), WithVaultStoreKVVersion(3)); err == nil {
+		t.Error("NewVaultStore accepted the kv version 3")
+	}
+}
@@ -0,0 +1,121 @@
+// Package sealed encrypts the content kept at rest with AES-256-GCM and a key derived from a passphrase.
+package sealed
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/speijnik/go-errortree"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// SaltSize is the size of the salts returned by NewSalt
+	SaltSize = 16
+	// MagicSize is the size of the magic heading a sealed file, it tells the kind of the file
+	MagicSize = 4
+	// Version is the format of the header of the sealed files
+	Version = 1
+	// HeaderSize is the size of the header of a sealed file: the magic, the version and the salt of the key
+	HeaderSize = MagicSize + 1 + SaltSize
+)
+
+type Box struct {
+	aead cipher.AEAD
+}
+
+// NewSalt returns a random salt, to be stored with the content it derives the key of
+func NewSalt() ([]byte, error) {
+
+	salt := make([]byte, SaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+
+	return salt, nil
+}
+
+// Header returns the header of a sealed file of the kind told by the magic, with the salt of its key
+func Header(magic string, salt []byte) []byte {
+
+	h := make([]byte, 0, HeaderSize)
+	h = append(h, magic...)
+	h = append(h, Version)
+
+	return append(h, salt...)
+}
+
+// ParseHeader returns the header of a sealed file and the salt of its key, the sealed content
+// follows the header
+func ParseHeader(magic string, content []byte) ([]byte, []byte, error) {
+
+	if !bytes.HasPrefix(content, []byte(magic)) {
+		return nil, nil, fmt.Errorf("missing %s header", magic)
+	}
+	if len(content) < HeaderSize {
+		return nil, nil, errors.New("truncated header")
+	}
+	if v := content[MagicSize]; v != Version {
+		return nil, nil, fmt.Errorf("unsupported %s version %d", magic, v)
+	}
+
+	return content[:HeaderSize], content[MagicSize+1 : HeaderSize], nil
+}
+
+// NewBox derives the key from the passphrase and the salt. A random salt, see NewSalt, prevents
+// precomputing the keys of the common passphrases.
+func NewBox(passphrase string, salt []byte) (*Box, error) {
+	var rcerror error
+
+	if passphrase == "" {
+		return nil, errortree.Add(rcerror, "NewBox", errors.New("empty passphrase"))
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, errortree.Add(rcerror, "NewBox", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errortree.Add(rcerror, "NewBox", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errortree.Add(rcerror, "NewBox", err)
+	}
+
+	return &Box{
+		aead: aead,
+	}, nil
+}
+
+// Seal encrypts the content with a random nonce, prepended to the result. The additional data
+// is authenticated but not stored, it must be given again to open the content.
+func (b *Box) Seal(plain []byte, ad []byte) ([]byte, error) {
+
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return b.aead.Seal(nonce, nonce, plain, ad), nil
+}
+
+// Open decrypts the content sealed with the same passphrase and additional data
+func (b *Box) Open(content []byte, ad []byte) ([]byte, error) {
+
+	n := b.aead.NonceSize()
+	if len(content) < n {
+		return nil, errors.New("truncated content")
+	}
+	plain, err := b.aead.Open(nil, content[:n], content[n:], ad)
+	if err != nil {
+		return nil, fmt.Errorf("can not decrypt content: %w", err)
+	}
+
+	return plain, nil
+}
@@ -0,0 +1,135 @@
+package sealed
+
+import (
+	"bytes"
+	"testing"
+)
+
+func newTestBox(t *testing.T, passphrase string, salt []byte) *Box {
+
+	b, err := NewBox(passphrase, salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func TestBoxRoundTrip(t *testing.T) {
+
+	salt, err := NewSalt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := newTestBox(t, "passphrase", salt)
+	content, err := b.Seal([]byte("secret"), []byte("ad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(content, []byte("secret")) {
+		t.Error("the content is not encrypted")
+	}
+	again, err := b.Seal([]byte("secret"), []byte("ad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(content, again) {
+		t.Error("two seals of the same content share the nonce")
+	}
+
+	plain, err := newTestBox(t, "passphrase", salt).Open(content, []byte("ad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plain) != "secret" {
+		t.Errorf("Open = %q, want %q", plain, "secret")
+	}
+}
+
+func TestBoxOpenFailures(t *testing.T) {
+
+	salt, err := NewSalt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := newTestBox(t, "passphrase", salt).Seal([]byte("secret"), []byte("ad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewSalt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := append([]byte{}, content...)
+	tampered[len(tampered)-1] ^= 0xff
+
+	tests := []struct {
+		name    string
+		box     *Box
+		content []byte
+		ad      string
+	}{
+		{"wrong passphrase", newTestBox(t, "wrong", salt), content, "ad"},
+		{"wrong salt", newTestBox(t, "passphrase", other), content, "ad"},
+		{"wrong additional data", newTestBox(t, "passphrase", salt), content, "other"},
+		{"tampered", newTestBox(t, "passphrase", salt), tampered, "ad"},
+		{"truncated", newTestBox(t, "passphrase", salt), content[:4], "ad"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.box.Open(tt.content, []byte(tt.ad)); err == nil {
+				t.Error("Open succeeded")
+			}
+		})
+	}
+}
+
+func TestNewSalt(t *testing.T) {
+
+	a, err := NewSalt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewSalt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a) != SaltSize || bytes.Equal(a, b) {
+		t.Errorf("NewSalt = %x, %x, want two random salts of %d bytes", a, b, SaltSize)
+	}
+	if _, err = NewBox("", a); err == nil {
+		t.Error("NewBox accepted an empty passphrase")
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+
+	salt, err := NewSalt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := append(Header("UXTS", salt), "sealed"...)
+	header, got, err := ParseHeader("UXTS", content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(header) != HeaderSize || !bytes.Equal(got, salt) {
+		t.Errorf("ParseHeader = %x, %x, want the header with the salt %x", header, got, salt)
+	}
+
+	newer := append([]byte{}, content...)
+	newer[MagicSize] = Version + 1
+	for name, c := range map[string][]byte{
+		"other kind": content,
+		"version":    newer,
+		"truncated":  content[:HeaderSize-1],
+	} {
+		magic := "UXTS"
+		if name == "other kind" {
+			magic = "UXKS"
+		}
+		if _, _, err := ParseHeader(magic, c); err == nil {
+			t.Errorf("%s: ParseHeader succeeded", name)
+		}
+	}
+}
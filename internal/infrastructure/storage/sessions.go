@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"fry.org/cmo/cli/internal/application/sessions"
+	"fry.org/cmo/cli/internal/infrastructure/storage/sessions/file"
+	"github.com/speijnik/go-errortree"
+)
+
+// ParseSessionStore creates a browser session store from an URI like
+//
+//	sessions:file?path=<folder>
+//
+// The sessions are encrypted with a key derived from the passphrase.
+func ParseSessionStore(URI string, passphrase string) (sessions.SessionStore, error) {
+	var s sessions.SessionStore
+	var rcerror error
+
+	u, err := url.Parse(URI)
+	if err != nil {
+		return nil, errortree.Add(rcerror, "ParseSessionStore", err)
+	}
+	if u.Scheme != "sessions" {
+		return nil, errortree.Add(rcerror, "ParseSessionStore", fmt.Errorf("invalid scheme %s", URI))
+	}
+	q := u.Query()
+	switch u.Opaque {
+	case "file":
+		folder := q.Get("path")
+		if folder == "" {
+			return nil, errortree.Add(rcerror, "ParseSessionStore", errors.New("missing path query argument"))
+		}
+		if s, err = file.NewFileStore(folder, passphrase); err != nil {
+			return nil, errortree.Add(rcerror, "ParseSessionStore", err)
+		}
+	default:
+		return nil, errortree.Add(rcerror, "ParseSessionStore", fmt.Errorf("unsupported session store implementation %q", u.Opaque))
+	}
+
+	return s, nil
+}
@@ -0,0 +1,173 @@
+// Package file keeps the browser sessions in a folder, encrypted with AES-256-GCM. The key is derived
+// from the passphrase and a random salt stored in the header of each file.
+package file
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"fry.org/cmo/cli/internal/application/sessions"
+	"fry.org/cmo/cli/internal/infrastructure/storage/sealed"
+	"github.com/speijnik/go-errortree"
+)
+
+const (
+	// magic heads the session files, see sealed.Header
+	magic = "UXSS"
+	// maxCachedBoxes bounds the keys kept to open the files again without deriving them
+	maxCachedBoxes = 64
+)
+
+type FileStore struct {
+	root       string
+	passphrase string
+	mutex      sync.Mutex
+	// boxes are the keys derived so far, by salt
+	boxes map[string]*sealed.Box
+}
+
+// NewFileStore creates a store in the folder, the content is encrypted with a key derived from the passphrase
+func NewFileStore(root string, passphrase string) (*FileStore, error) {
+	var rcerror error
+
+	if passphrase == "" {
+		return nil, errortree.Add(rcerror, "NewFileStore", errors.New("empty passphrase"))
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, errortree.Add(rcerror, "NewFileStore", err)
+	}
+	if err = os.MkdirAll(abs, 0700); err != nil {
+		return nil, errortree.Add(rcerror, "NewFileStore", err)
+	}
+
+	return &FileStore{
+		root:       abs,
+		passphrase: passphrase,
+		boxes:      make(map[string]*sealed.Box),
+	}, nil
+}
+
+// derive returns the box of the salt, the key is derived only once per salt
+func (f *FileStore) derive(salt []byte) (*sealed.Box, error) {
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if box, ok := f.boxes[string(salt)]; ok {
+		return box, nil
+	}
+	box, err := sealed.NewBox(f.passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(f.boxes) >= maxCachedBoxes {
+		f.boxes = make(map[string]*sealed.Box)
+	}
+	f.boxes[string(salt)] = box
+
+	return box, nil
+}
+
+// additionalData authenticates the header and the key along with the sealed session, a session can
+// not be swapped with another one
+func additionalData(header []byte, key string) []byte {
+
+	return append(append([]byte{}, header...), key...)
+}
+
+// path hashes the key, so it never leaks the user or the target in the file name
+func (f *FileStore) path(key string) string {
+
+	sum := sha256.Sum256([]byte(key))
+
+	return filepath.Join(f.root, hex.EncodeToString(sum[:])+".session")
+}
+
+func (f *FileStore) Load(ctx context.Context, key string) (sessions.Session, error) {
+	var rcerror error
+	var s sessions.Session
+
+	content, err := os.ReadFile(f.path(key))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return s, sessions.ErrNotFound
+		}
+		return s, errortree.Add(rcerror, "Load", err)
+	}
+	header, salt, err := sealed.ParseHeader(magic, content)
+	if err != nil {
+		return s, errortree.Add(rcerror, "Load", err)
+	}
+	box, err := f.derive(salt)
+	if err != nil {
+		return s, errortree.Add(rcerror, "Load", err)
+	}
+	plain, err := box.Open(content[len(header):], additionalData(header, key))
+	if err != nil {
+		return s, errortree.Add(rcerror, "Load", err)
+	}
+	if err = json.Unmarshal(plain, &s); err != nil {
+		return s, errortree.Add(rcerror, "Load", err)
+	}
+
+	return s, nil
+}
+
+func (f *FileStore) Save(ctx context.Context, key string, s sessions.Session) error {
+	var rcerror error
+
+	plain, err := json.Marshal(s)
+	if err != nil {
+		return errortree.Add(rcerror, "Save", err)
+	}
+	// Every session file gets its own salt
+	salt, err := sealed.NewSalt()
+	if err != nil {
+		return errortree.Add(rcerror, "Save", err)
+	}
+	box, err := f.derive(salt)
+	if err != nil {
+		return errortree.Add(rcerror, "Save", err)
+	}
+	header := sealed.Header(magic, salt)
+	content, err := box.Seal(plain, additionalData(header, key))
+	if err != nil {
+		return errortree.Add(rcerror, "Save", err)
+	}
+	content = append(header, content...)
+	// Write and rename, so a concurrent Load never reads a partial file
+	tmp, err := os.CreateTemp(f.root, ".session-*")
+	if err != nil {
+		return errortree.Add(rcerror, "Save", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(content); err != nil {
+		tmp.Close()
+		return errortree.Add(rcerror, "Save", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return errortree.Add(rcerror, "Save", err)
+	}
+	if err = os.Rename(tmp.Name(), f.path(key)); err != nil {
+		return errortree.Add(rcerror, "Save", err)
+	}
+
+	return nil
+}
+
+func (f *FileStore) Delete(ctx context.Context, key string) error {
+	var rcerror error
+
+	if err := os.Remove(f.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errortree.Add(rcerror, "Delete", err)
+	}
+
+	return nil
+}
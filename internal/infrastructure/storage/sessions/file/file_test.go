@@ -0,0 +1,160 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"fry.org/cmo/cli/internal/application/sessions"
+	"fry.org/cmo/cli/internal/infrastructure/storage/sealed"
+)
+
+var session = sessions.Session{
+	Created: time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC),
+	Cookies: []sessions.Cookie{
+		{Name: "ESTSAUTH", Value: "secret-cookie", Domain: ".login.example.com", Path: "/", HTTPOnly: true, Secure: true, SameSite: "None"},
+		{Name: "lang", Value: "en", Domain: "app.example.com", Path: "/", Expires: 1700000000.5},
+	},
+	LocalStorage: map[string]map[string]string{
+		"https://app.example.com": {"msal.token": "secret-token"},
+	},
+}
+
+func newTestStore(t *testing.T, root string, passphrase string) *FileStore {
+
+	f, err := NewFileStore(root, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return f
+}
+
+func TestFileStoreRoundTrip(t *testing.T) {
+
+	ctx := context.Background()
+	root := t.TempDir()
+	f := newTestStore(t, root, "passphrase")
+	if _, err := f.Load(ctx, "app.example.com|alice"); !errors.Is(err, sessions.ErrNotFound) {
+		t.Errorf("Load of a missing session = %v, want ErrNotFound", err)
+	}
+	if err := f.Save(ctx, "app.example.com|alice", session); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := newTestStore(t, root, "passphrase").Load(ctx, "app.example.com|alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, session) {
+		t.Errorf("Load = %+v, want %+v", got, session)
+	}
+	files, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("%d files, want 1", len(files))
+	}
+	content, err := os.ReadFile(filepath.Join(root, files[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"alice", "app.example.com", "secret-cookie", "secret-token"} {
+		if bytes.Contains(content, []byte(secret)) || bytes.Contains([]byte(files[0].Name()), []byte(secret)) {
+			t.Errorf("%s is readable in the session file", secret)
+		}
+	}
+
+	if err = f.Delete(ctx, "app.example.com|alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Load(ctx, "app.example.com|alice"); !errors.Is(err, sessions.ErrNotFound) {
+		t.Errorf("Load of a deleted session = %v, want ErrNotFound", err)
+	}
+	if err = f.Delete(ctx, "app.example.com|alice"); err != nil {
+		t.Errorf("Delete of a missing session = %v", err)
+	}
+}
+
+func TestFileStoreRandomSalt(t *testing.T) {
+
+	ctx := context.Background()
+	root := t.TempDir()
+	f := newTestStore(t, root, "passphrase")
+	var salts [][]byte
+	for _, key := range []string{"app.example.com|alice", "app.example.com|bob"} {
+		if err := f.Save(ctx, key, session); err != nil {
+			t.Fatal(err)
+		}
+		content, err := os.ReadFile(f.path(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(content, []byte{'U', 'X', 'S', 'S', sealed.Version}) {
+			t.Fatalf("missing header in %x", content[:sealed.HeaderSize])
+		}
+		_, salt, err := sealed.ParseHeader(magic, content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		salts = append(salts, salt)
+	}
+	if bytes.Equal(salts[0], salts[1]) {
+		t.Error("two session files share the same salt")
+	}
+}
+
+func TestFileStoreLoadFailures(t *testing.T) {
+
+	ctx := context.Background()
+	root := t.TempDir()
+	f := newTestStore(t, root, "passphrase")
+	if err := f.Save(ctx, "app.example.com|alice", session); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(f.path("app.example.com|alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = newTestStore(t, root, "wrong").Load(ctx, "app.example.com|alice"); err == nil {
+		t.Error("Load succeeded with a wrong passphrase")
+	}
+	// A session moved to the file of another key is refused
+	if err = os.WriteFile(f.path("app.example.com|bob"), content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Load(ctx, "app.example.com|bob"); err == nil {
+		t.Error("Load succeeded with the session of another key")
+	}
+	tests := map[string]func(b []byte) []byte{
+		"salt": func(b []byte) []byte {
+			b[sealed.MagicSize+1] ^= 0xff
+			return b
+		},
+		"version": func(b []byte) []byte {
+			b[sealed.MagicSize] = sealed.Version + 1
+			return b
+		},
+		"without header": func(b []byte) []byte {
+			return b[sealed.HeaderSize:]
+		},
+	}
+	for name, tamper := range tests {
+		if err = os.WriteFile(f.path("app.example.com|alice"), tamper(append([]byte{}, content...)), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = f.Load(ctx, "app.example.com|alice"); err == nil {
+			t.Errorf("%s: Load succeeded", name)
+		}
+	}
+	if _, err = NewFileStore(root, ""); err == nil {
+		t.Error("NewFileStore accepted an empty passphrase")
+	}
+}
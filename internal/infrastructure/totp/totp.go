@@ -0,0 +1,119 @@
+// Package totp generates the time-based one-time passwords of RFC 6238.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Key holds the parameters shared with the authenticator
+type Key struct {
+	Secret    []byte
+	Digits    int
+	Period    time.Duration
+	Algorithm func() hash.Hash
+}
+
+// Parse reads a key given as a base32 secret, as shown by the identity providers next to the QR
+// code, or as an otpauth://totp URI. The defaults are 6 digits, 30 seconds and SHA1.
+func Parse(s string) (Key, error) {
+
+	k := Key{
+		Digits:    6,
+		Period:    30 * time.Second,
+		Algorithm: sha1.New,
+	}
+	secret := s
+	if strings.HasPrefix(s, "otpauth://") {
+		u, err := url.Parse(s)
+		if err != nil {
+			return k, err
+		}
+		if u.Host != "totp" {
+			return k, fmt.Errorf("unsupported otpauth type %q", u.Host)
+		}
+		q := u.Query()
+		secret = q.Get("secret")
+		if v := q.Get("digits"); v != "" {
+			if k.Digits, err = strconv.Atoi(v); err != nil || k.Digits < 6 || k.Digits > 10 {
+				return k, fmt.Errorf("invalid digits %q", v)
+			}
+		}
+		if v := q.Get("period"); v != "" {
+			p, err := strconv.Atoi(v)
+			if err != nil || p <= 0 {
+				return k, fmt.Errorf("invalid period %q", v)
+			}
+			k.Period = time.Duration(p) * time.Second
+		}
+		switch strings.ToUpper(q.Get("algorithm")) {
+		case "", "SHA1":
+		case "SHA256":
+			k.Algorithm = sha256.New
+		case "SHA512":
+			k.Algorithm = sha512.New
+		default:
+			return k, fmt.Errorf("unsupported algorithm %q", q.Get("algorithm"))
+		}
+	}
+	// Secrets are usually shown in groups of four lowercase characters
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	if secret == "" {
+		return k, errors.New("empty secret")
+	}
+	b, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+	if err != nil {
+		return k, fmt.Errorf("invalid base32 secret: %w", err)
+	}
+	k.Secret = b
+
+	return k, nil
+}
+
+// Step returns the time step of t
+func (k Key) Step(t time.Time) uint64 {
+
+	return uint64(t.Unix() / int64(k.Period/time.Second))
+}
+
+// Start returns the time the code of the time step becomes valid
+func (k Key) Start(step uint64) time.Time {
+
+	return time.Unix(int64(step)*int64(k.Period/time.Second), 0)
+}
+
+// Remaining returns how long the code of t is still valid
+func (k Key) Remaining(t time.Time) time.Duration {
+
+	return k.Start(k.Step(t) + 1).Sub(t)
+}
+
+// Code returns the one-time password of the time step
+func (k Key) Code(step uint64) string {
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], step)
+	mac := hmac.New(k.Algorithm, k.Secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	// Dynamic truncation of RFC 4226
+	offset := sum[len(sum)-1] & 0x0f
+	v := uint64(binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff)
+	mod := uint64(1)
+	for i := 0; i < k.Digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", k.Digits, v%mod)
+}
@@ -0,0 +1,132 @@
+package totp
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"hash"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The seeds of the test vectors of RFC 6238, Appendix B
+var (
+	seedSHA1   = []byte("12345678901234567890")
+	seedSHA256 = []byte("12345678901234567890123456789012")
+	seedSHA512 = []byte("1234567890123456789012345678901234567890123456789012345678901234")
+)
+
+func TestCodeRFC6238Vectors(t *testing.T) {
+
+	tests := []struct {
+		time   int64
+		sha1   string
+		sha256 string
+		sha512 string
+	}{
+		{59, "94287082", "46119246", "90693936"},
+		{1111111109, "07081804", "68084774", "25091201"},
+		{1111111111, "14050471", "67062674", "99943326"},
+		{1234567890, "89005924", "91819424", "93441116"},
+		{2000000000, "69279037", "90698825", "38618901"},
+		{20000000000, "65353130", "77737706", "47863826"},
+	}
+	algorithms := []struct {
+		name      string
+		seed      []byte
+		algorithm func() hash.Hash
+		code      func(i int) string
+	}{
+		{"SHA1", seedSHA1, sha1.New, func(i int) string { return tests[i].sha1 }},
+		{"SHA256", seedSHA256, sha256.New, func(i int) string { return tests[i].sha256 }},
+		{"SHA512", seedSHA512, sha512.New, func(i int) string { return tests[i].sha512 }},
+	}
+	for _, a := range algorithms {
+		t.Run(a.name, func(t *testing.T) {
+			k := Key{
+				Secret:    a.seed,
+				Digits:    8,
+				Period:    30 * time.Second,
+				Algorithm: a.algorithm,
+			}
+			for i, tt := range tests {
+				if got := k.Code(k.Step(time.Unix(tt.time, 0))); got != a.code(i) {
+					t.Errorf("code at %d = %s, want %s", tt.time, got, a.code(i))
+				}
+			}
+		})
+	}
+}
+
+func TestParseURI(t *testing.T) {
+
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(seedSHA256)
+	q := url.Values{
+		"secret":    {secret},
+		"algorithm": {"SHA256"},
+		"digits":    {"8"},
+		"period":    {"30"},
+	}
+	k, err := Parse("otpauth://totp/Example:alice@example.com?" + q.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := k.Code(k.Step(time.Unix(1111111109, 0))); got != "68084774" {
+		t.Errorf("code = %s, want 68084774", got)
+	}
+}
+
+func TestParseSecret(t *testing.T) {
+
+	secret := base32.StdEncoding.EncodeToString(seedSHA1)
+	// The providers show the secret in lowercase groups of four characters
+	var groups []string
+	for i := 0; i < len(secret); i += 4 {
+		groups = append(groups, strings.ToLower(secret[i:i+4]))
+	}
+	k, err := Parse(strings.Join(groups, " "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.Digits != 6 || k.Period != 30*time.Second {
+		t.Errorf("defaults = %d digits every %s, want 6 digits every 30s", k.Digits, k.Period)
+	}
+	// The 6 digits code is the last 6 digits of the 8 digits one
+	if got := k.Code(k.Step(time.Unix(59, 0))); got != "287082" {
+		t.Errorf("code = %s, want 287082", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+
+	for _, s := range []string{
+		"",
+		"not base32!",
+		"otpauth://hotp/x?secret=JBSWY3DPEHPK3PXP",
+		"otpauth://totp/x?secret=JBSWY3DPEHPK3PXP&digits=4",
+		"otpauth://totp/x?secret=JBSWY3DPEHPK3PXP&period=0",
+		"otpauth://totp/x?secret=JBSWY3DPEHPK3PXP&algorithm=MD5",
+	} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("Parse(%q) succeeded", s)
+		}
+	}
+}
+
+func TestStepTiming(t *testing.T) {
+
+	k := Key{Period: 30 * time.Second}
+	now := time.Unix(1111111109, 0)
+	if step := k.Step(now); step != 37037036 {
+		t.Errorf("step = %d, want 37037036", step)
+	}
+	if r := k.Remaining(now); r != 1*time.Second {
+		t.Errorf("remaining = %s, want 1s", r)
+	}
+	if s := k.Start(37037037); !s.Equal(time.Unix(1111111110, 0)) {
+		t.Errorf("start = %s, want 1111111110", s)
+	}
+}
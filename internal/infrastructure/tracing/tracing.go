@@ -0,0 +1,95 @@
+// Package tracing exports the traces of the probe runs with the OpenTelemetry protocol over HTTP.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/speijnik/go-errortree"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+// ServiceName is the service.name of the traces unless OTEL_SERVICE_NAME sets another one
+const ServiceName = "uxperi"
+
+// Enabled tells whether the OTEL envars ask for the traces to be exported: an OTLP endpoint is set,
+// and neither the SDK nor the traces exporter are disabled.
+func Enabled() bool {
+
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") || strings.EqualFold(os.Getenv("OTEL_TRACES_EXPORTER"), "none") {
+		return false
+	}
+
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Setup installs the process-wide tracer provider exporting to the OTLP endpoint of the envars, the
+// default no-op provider is kept when tracing is not enabled. The endpoint, headers, timeout,
+// compression and certificates are read by the exporter from the standard OTEL_EXPORTER_OTLP_* envars,
+// the sampler from OTEL_TRACES_SAMPLER and the resource from OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES.
+func Setup(ctx context.Context) error {
+	var rcerror error
+
+	if !Enabled() {
+		return nil
+	}
+	protocol := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL")
+	if protocol == "" {
+		protocol = os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+	}
+	if protocol != "" && protocol != "http/protobuf" {
+		return errortree.Add(rcerror, "Setup", fmt.Errorf("unsupported OTLP protocol %q, only http/protobuf is", protocol))
+	}
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return errortree.Add(rcerror, "Setup", err)
+	}
+	// The envars override the service name
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(ServiceName)))
+	if err != nil {
+		return errortree.Add(rcerror, "Setup", err)
+	}
+	if res, err = resource.Merge(res, resource.Environment()); err != nil {
+		return errortree.Add(rcerror, "Setup", err)
+	}
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	))
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return nil
+}
+
+// Shutdown exports the pending spans, it is a no-op when tracing is not enabled
+func Shutdown(ctx context.Context) error {
+	var rcerror error
+
+	if tp, ok := otel.GetTracerProvider().(*sdktrace.TracerProvider); ok {
+		if err := tp.Shutdown(ctx); err != nil {
+			return errortree.Add(rcerror, "Shutdown", err)
+		}
+	}
+
+	return nil
+}
+
+// Flush exports the pending spans without waiting for the batch timeout
+func Flush(ctx context.Context) error {
+	var rcerror error
+
+	if tp, ok := otel.GetTracerProvider().(*sdktrace.TracerProvider); ok {
+		if err := tp.ForceFlush(ctx); err != nil {
+			return errortree.Add(rcerror, "Flush", err)
+		}
+	}
+
+	return nil
+}
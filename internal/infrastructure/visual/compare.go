@@ -0,0 +1,125 @@
+// Package visual compares screenshots against baseline images.
+package visual
+
+import (
+	"image"
+	"image/color"
+)
+
+// Options tunes the comparison of two images
+type Options struct {
+	// Threshold is the color distance, in the [0..1] range, above which two pixels are considered different
+	Threshold float64
+	// Ignore are the regions excluded from the comparison, e.g. clocks or carousels
+	Ignore []image.Rectangle
+}
+
+// Result holds the outcome of a comparison
+type Result struct {
+	// DiffPixels is the number of pixels that differ
+	DiffPixels int
+	// ComparedPixels is the number of pixels compared, i.e. not ignored
+	ComparedPixels int
+	// Ratio is DiffPixels over ComparedPixels
+	Ratio float64
+	// Diff highlights in red the pixels that differ
+	Diff *image.RGBA
+}
+
+var (
+	diffColor   = color.RGBA{R: 0xff, A: 0xff}
+	ignoreColor = color.RGBA{R: 0x9e, G: 0xc5, B: 0xfe, A: 0xff}
+)
+
+// Compare checks the actual image against the baseline pixel by pixel. When the sizes differ,
+// the pixels that are only present in one of the images are counted as different.
+func Compare(baseline image.Image, actual image.Image, opts Options) Result {
+
+	bb := baseline.Bounds()
+	ab := actual.Bounds()
+	width := bb.Dx()
+	if ab.Dx() > width {
+		width = ab.Dx()
+	}
+	height := bb.Dy()
+	if ab.Dy() > height {
+		height = ab.Dy()
+	}
+	res := Result{
+		Diff: image.NewRGBA(image.Rect(0, 0, width, height)),
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			p := image.Pt(x, y)
+			if ignored(p, opts.Ignore) {
+				res.Diff.SetRGBA(x, y, ignoreColor)
+				continue
+			}
+			res.ComparedPixels++
+			bp := p.Add(bb.Min)
+			ap := p.Add(ab.Min)
+			if !bp.In(bb) || !ap.In(ab) {
+				res.DiffPixels++
+				res.Diff.SetRGBA(x, y, diffColor)
+				continue
+			}
+			ac := actual.At(ap.X, ap.Y)
+			if distance(baseline.At(bp.X, bp.Y), ac) > opts.Threshold {
+				res.DiffPixels++
+				res.Diff.SetRGBA(x, y, diffColor)
+				continue
+			}
+			res.Diff.SetRGBA(x, y, faded(ac))
+		}
+	}
+	if res.ComparedPixels > 0 {
+		res.Ratio = float64(res.DiffPixels) / float64(res.ComparedPixels)
+	}
+
+	return res
+}
+
+func ignored(p image.Point, regions []image.Rectangle) bool {
+
+	for _, r := range regions {
+		if p.In(r) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// distance returns the largest difference among the channels, normalized to the [0..1] range
+func distance(a color.Color, b color.Color) float64 {
+
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+	d := diff(ar, br)
+	for _, v := range []uint32{diff(ag, bg), diff(ab, bb), diff(aa, ba)} {
+		if v > d {
+			d = v
+		}
+	}
+
+	return float64(d) / 0xffff
+}
+
+func diff(a uint32, b uint32) uint32 {
+
+	if a > b {
+		return a - b
+	}
+
+	return b - a
+}
+
+// faded renders the matching pixels as a light gray so the differences stand out
+func faded(c color.Color) color.RGBA {
+
+	g := color.GrayModel.Convert(c).(color.Gray).Y
+	g = 0xff - (0xff-g)/4
+
+	return color.RGBA{R: g, G: g, B: g, A: 0xff}
+}
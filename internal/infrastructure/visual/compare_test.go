@@ -0,0 +1,151 @@
+package visual
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// uniform returns a w x h image of the given gray level, with the region painted in the other one
+func uniform(w int, h int, y uint8, region image.Rectangle, other uint8) *image.RGBA {
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for py := 0; py < h; py++ {
+		for px := 0; px < w; px++ {
+			g := y
+			if image.Pt(px, py).In(region) {
+				g = other
+			}
+			img.SetRGBA(px, py, color.RGBA{R: g, G: g, B: g, A: 0xff})
+		}
+	}
+
+	return img
+}
+
+func TestCompare(t *testing.T) {
+
+	none := image.Rectangle{}
+	// A gray level 10 above the baseline is 10/255 ~ 0.0392 away
+	tests := []struct {
+		name     string
+		baseline image.Image
+		actual   image.Image
+		opts     Options
+		diff     int
+		compared int
+	}{
+		{
+			name:     "identical",
+			baseline: uniform(10, 10, 100, none, 0),
+			actual:   uniform(10, 10, 100, none, 0),
+			diff:     0,
+			compared: 100,
+		},
+		{
+			name:     "different pixels",
+			baseline: uniform(10, 10, 100, none, 0),
+			actual:   uniform(10, 10, 100, image.Rect(0, 0, 5, 2), 200),
+			diff:     10,
+			compared: 100,
+		},
+		{
+			name:     "just under the threshold",
+			baseline: uniform(10, 10, 100, none, 0),
+			actual:   uniform(10, 10, 110, none, 0),
+			opts:     Options{Threshold: 0.04},
+			diff:     0,
+			compared: 100,
+		},
+		{
+			name:     "just over the threshold",
+			baseline: uniform(10, 10, 100, none, 0),
+			actual:   uniform(10, 10, 110, none, 0),
+			opts:     Options{Threshold: 0.039},
+			diff:     100,
+			compared: 100,
+		},
+		{
+			name:     "wider actual",
+			baseline: uniform(10, 10, 100, none, 0),
+			actual:   uniform(12, 10, 100, none, 0),
+			diff:     20,
+			compared: 120,
+		},
+		{
+			name:     "taller baseline",
+			baseline: uniform(10, 12, 100, none, 0),
+			actual:   uniform(10, 10, 100, none, 0),
+			diff:     20,
+			compared: 120,
+		},
+		{
+			name:     "ignored region",
+			baseline: uniform(10, 10, 100, none, 0),
+			actual:   uniform(10, 10, 100, image.Rect(0, 0, 5, 2), 200),
+			opts:     Options{Ignore: []image.Rectangle{image.Rect(0, 0, 5, 2)}},
+			diff:     0,
+			compared: 90,
+		},
+		{
+			name:     "partly ignored region",
+			baseline: uniform(10, 10, 100, none, 0),
+			actual:   uniform(10, 10, 100, image.Rect(0, 0, 5, 2), 200),
+			opts:     Options{Ignore: []image.Rectangle{image.Rect(0, 0, 5, 1)}},
+			diff:     5,
+			compared: 95,
+		},
+		{
+			name:     "everything ignored",
+			baseline: uniform(10, 10, 100, none, 0),
+			actual:   uniform(10, 10, 200, none, 0),
+			opts:     Options{Ignore: []image.Rectangle{image.Rect(0, 0, 10, 10)}},
+			diff:     0,
+			compared: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := Compare(tt.baseline, tt.actual, tt.opts)
+			if res.DiffPixels != tt.diff || res.ComparedPixels != tt.compared {
+				t.Errorf("DiffPixels = %d, ComparedPixels = %d, want %d, %d", res.DiffPixels, res.ComparedPixels, tt.diff, tt.compared)
+			}
+			var ratio float64
+			if tt.compared > 0 {
+				ratio = float64(tt.diff) / float64(tt.compared)
+			}
+			if res.Ratio != ratio {
+				t.Errorf("Ratio = %f, want %f", res.Ratio, ratio)
+			}
+		})
+	}
+}
+
+func TestCompareDiffImage(t *testing.T) {
+
+	baseline := uniform(4, 4, 100, image.Rectangle{}, 0)
+	actual := uniform(4, 4, 100, image.Rect(0, 0, 1, 1), 200)
+	// The actual image does not start at the origin, the pixels are matched from the top left corners
+	shifted := image.NewRGBA(image.Rect(10, 10, 14, 14))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			shifted.Set(x+10, y+10, actual.At(x, y))
+		}
+	}
+	res := Compare(baseline, shifted, Options{Ignore: []image.Rectangle{image.Rect(3, 3, 4, 4)}})
+	if res.DiffPixels != 1 {
+		t.Fatalf("DiffPixels = %d, want 1", res.DiffPixels)
+	}
+	if res.Diff.Bounds() != image.Rect(0, 0, 4, 4) {
+		t.Errorf("diff bounds = %v", res.Diff.Bounds())
+	}
+	if got := res.Diff.RGBAAt(0, 0); got != diffColor {
+		t.Errorf("different pixel = %v, want %v", got, diffColor)
+	}
+	if got := res.Diff.RGBAAt(3, 3); got != ignoreColor {
+		t.Errorf("ignored pixel = %v, want %v", got, ignoreColor)
+	}
+	if got := res.Diff.RGBAAt(1, 1); got == diffColor || got.R != got.G || got.G != got.B {
+		t.Errorf("matching pixel = %v, want a gray", got)
+	}
+}